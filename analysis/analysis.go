@@ -1,17 +1,21 @@
 package analysis
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
 	"go/build"
 	"go/types"
 	"io"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/ofabry/go-callvis/pkg/dot"
 	"github.com/ofabry/go-callvis/pkg/logger"
 	"github.com/ofabry/go-callvis/pkg/output"
 	"golang.org/x/tools/go/callgraph"
@@ -33,18 +37,6 @@ const (
 )
 
 // ==[ type def/func: analysis   ]===============================================
-type renderOpts struct {
-	cacheDir string
-	focus    string
-	group    []string
-	ignore   []string
-	include  []string
-	limit    []string
-	nointer  bool
-	refresh  bool
-	nostd    bool
-	algo     CallGraphType
-}
 
 // mainPackages returns the main packages to analyze.
 // Each resulting package is named "main" and has a main function.
@@ -56,345 +48,463 @@ func mainPackages(pkgs []*ssa.Package) ([]*ssa.Package, error) {
 		}
 	}
 	if len(mains) == 0 {
-		return nil, fmt.Errorf("no main packages")
+		return nil, ErrNoMainPackages
 	}
 	return mains, nil
 }
 
 // ==[ type def/func: Analysis   ]===============================================
 type Analysis struct {
-	opts         *renderOpts
-	prog         *ssa.Program
-	pkgs         []*ssa.Package
+	opts     *Options
+	prog     *ssa.Program
+	pkgs     []*ssa.Package
+	docLinks map[string]string
+	modules  map[string]string
+	sources  []string
+	// sourceStates is the state of the sources when they were loaded, see
+	// SaveSnapshot
+	sourceStates map[string]string
+	// sourceKey hashes the analyzed sources, see graphCacheKey
+	sourceKey string
+	// codeErrors are the errors of the packages analyzed with AllowErrors
+	codeErrors   []output.CodeError
 	mainPkg      *ssa.Package
 	callgraph    *callgraph.Graph
+	index        *output.GraphIndex
 	outputFormat string
 	Minlen       uint
 	PrintOptions map[string]string
+	Theme        *output.Theme
+	// FullLoad requests all metadata of the packages, like their export
+	// data and embedded files, instead of only what the analysis needs.
+	FullLoad bool
+	// AllowErrors analyzes packages with errors as far as they were
+	// type-checked, rather than failing, marking them in the output.
+	AllowErrors bool
+	// Driver is the go/packages driver program loading the packages, like
+	// the gopackagesdriver of rules_go for Bazel, overriding the
+	// GOPACKAGESDRIVER environment variable.
+	Driver string
+	// Env are variables overriding the environment of the package loader,
+	// like GOOS=windows to analyze the build of another platform.
+	Env []string
+	// Overlay replaces the contents of source files by absolute path, like
+	// the unsaved buffers of an editor, see LoadOverlay.
+	Overlay map[string][]byte
+	// Images caches the rendered images, if set.
+	Images *ImageCache
+	// NodeFilters and EdgeFilters are custom filters applied on top of the
+	// filter option.
+	NodeFilters []output.NodeFilter
+	EdgeFilters []output.EdgeFilter
+	// NodeDecorators and EdgeDecorators add attributes to the nodes and
+	// edges of the rendered graphs.
+	NodeDecorators []output.NodeDecorator
+	EdgeDecorators []output.EdgeDecorator
+	// Changed reports whether a function changed, like those of a pull
+	// request, if set.
+	Changed func(fn *ssa.Function) bool
 }
 
 func NewAnalysis(outputFormat string) *Analysis {
+	opts := DefaultOptions()
 	return &Analysis{
+		opts:         &opts,
 		outputFormat: outputFormat,
 	}
 }
 
-func (a *Analysis) DoAnalysis(
-	algo CallGraphType,
-	dir string,
-	tests bool,
-	args []string,
-) error {
-	allPackages := packages.NeedName |
+// Modes of loading the packages. The fast mode requests only what building
+// the SSA program needs, plus the files and modules of the packages used
+// by caching, watching and documentation links.
+const (
+	loadModeFast = packages.NeedName |
 		packages.NeedFiles |
 		packages.NeedCompiledGoFiles |
 		packages.NeedImports |
 		packages.NeedDeps |
-		packages.NeedExportFile |
 		packages.NeedTypes |
 		packages.NeedSyntax |
 		packages.NeedTypesInfo |
 		packages.NeedTypesSizes |
-		packages.NeedModule | packages.NeedEmbedFiles | packages.NeedEmbedPatterns
+		packages.NeedModule
+	loadModeFull = loadModeFast |
+		packages.NeedExportFile |
+		packages.NeedEmbedFiles | packages.NeedEmbedPatterns
+)
+
+// DoAnalysis loads the packages matching args and builds their call graph
+// with the algorithm of the options, or takes it from the cache directory
+// of the options. Loading is aborted when ctx is done.
+func (a *Analysis) DoAnalysis(
+	ctx context.Context,
+	dir string,
+	tests bool,
+	args []string,
+) error {
+	a.opts.Tests = tests
+	if err := a.opts.Validate(); err != nil {
+		return err
+	}
+	algo := a.opts.Algo
+	mode := loadModeFast
+	if a.FullLoad {
+		mode = loadModeFull
+	}
 
 	cfg := &packages.Config{
-		Mode:       packages.LoadMode(allPackages),
+		Context:    ctx,
+		Mode:       mode,
 		Tests:      tests,
 		Dir:        dir,
 		BuildFlags: getBuildFlags(),
+		Env:        a.loadEnv(),
+		Overlay:    a.Overlay,
 	}
 
+	driver := packagesDriver(cfg.Env)
+	if driver != "" {
+		logger.LogDebug("loading packages with driver %s", driver)
+	}
 	initial, err := packages.Load(cfg, args...)
 	if err != nil {
-		return err
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &LoadError{Patterns: args, Err: err}
 	}
-
-	if packages.PrintErrors(initial) > 0 {
-		return fmt.Errorf("packages contain errors")
+	if driver != "" {
+		markStdPackages(initial)
+	}
+	// the state of the sources as loaded, for the snapshots of the graph
+	sources := sourceFiles(initial)
+	var sourceStates map[string]string
+	if a.opts.CacheDir != "" {
+		sourceStates = fileStates(sources)
 	}
 
 	// Create and build SSA-form program representation.
-	prog, pkgs := ssautil.AllPackages(initial, 0)
-	prog.Build()
+	var prog *ssa.Program
+	var pkgs []*ssa.Package
+	var codeErrors []output.CodeError
+	if errs := packageErrors(initial); len(errs) > 0 {
+		if !a.AllowErrors {
+			return &LoadError{Patterns: args, Errors: errs}
+		}
+		logger.LogWarn("packages contain %d errors, analyzing them as far as possible", len(errs))
+		for _, e := range errs {
+			codeErrors = append(codeErrors, output.CodeError{Pos: e.Pos, Msg: e.Msg})
+		}
+		prog, pkgs = buildAllowingErrors(initial)
+	} else {
+		prog, pkgs = ssautil.AllPackages(initial, 0)
+		prog.Build()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	var graph *callgraph.Graph
 	var mainPkg *ssa.Package
 
+	// the cached images are keyed by the sources as well
+	var cacheKey string
+	if a.opts.CacheDir != "" || a.Images != nil {
+		if cacheKey, err = graphCacheKey(algo, cfg, args, initial); err != nil {
+			return err
+		}
+		if a.opts.CacheDir != "" && !a.opts.Refresh {
+			if graph, err = loadGraph(a.opts.CacheDir, cacheKey, prog); err != nil {
+				logger.LogWarn("%v", err)
+			} else if graph != nil {
+				logger.LogDebug("using cached call graph %s", cacheKey)
+			}
+		}
+	}
+
+	cached := graph != nil
+	if cached {
+		if algo == CallGraphTypeRta {
+			mains, err := mainPackages(prog.AllPackages())
+			if err != nil {
+				return err
+			}
+			mainPkg = mains[0]
+		}
+	} else if graph, mainPkg, err = buildCallGraph(algo, prog); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// done once here, so rendering never modifies the shared graph
+	graph.DeleteSyntheticNodes()
+
+	if a.opts.CacheDir != "" && !cached {
+		if err := saveGraph(a.opts.CacheDir, cacheKey, prog, graph); err != nil {
+			logger.LogWarn("caching call graph: %v", err)
+		}
+	}
+
+	a.prog = prog
+	a.pkgs = pkgs
+	a.docLinks = docLinks(initial)
+	a.modules = modulePaths(initial)
+	a.sources = sources
+	a.sourceStates = sourceStates
+	a.mainPkg = mainPkg
+	a.callgraph = graph
+	a.sourceKey = cacheKey
+	a.codeErrors = codeErrors
+	a.index = output.NewGraphIndex(graph, pkgs)
+	return nil
+}
+
+// buildCallGraph constructs the call graph of prog using algo. With RTA
+// it also returns the main package whose main functions are the roots.
+func buildCallGraph(algo CallGraphType, prog *ssa.Program) (*callgraph.Graph, *ssa.Package, error) {
 	switch algo {
 	case CallGraphTypeStatic:
-		graph = static.CallGraph(prog)
+		return static.CallGraph(prog), nil, nil
 	case CallGraphTypeCha:
-		graph = cha.CallGraph(prog)
+		return cha.CallGraph(prog), nil, nil
 	case CallGraphTypeRta:
 		mains, err := mainPackages(prog.AllPackages())
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
 		var roots []*ssa.Function
-		mainPkg = mains[0]
 		for _, main := range mains {
 			roots = append(roots, main.Func("main"))
 		}
-		graph = rta.Analyze(roots, true).CallGraph
-	default:
-		return fmt.Errorf("invalid call graph type: %s", a.opts.algo)
+		return rta.Analyze(roots, true).CallGraph, mains[0], nil
 	}
+	return nil, nil, fmt.Errorf("invalid call graph type: %s", algo)
+}
 
-	//cg.DeleteSyntheticNodes()
+// Index returns the index of the analyzed call graph.
+func (a *Analysis) Index() *output.GraphIndex {
+	return a.index
+}
 
-	a.prog = prog
-	a.pkgs = pkgs
-	a.mainPkg = mainPkg
-	a.callgraph = graph
-	return nil
+// Packages returns the analyzed packages, without their dependencies.
+func (a *Analysis) Packages() []*ssa.Package {
+	return a.pkgs
 }
 
-func (a *Analysis) OptsSetup(cacheDir string,
-	focus string,
-	group string,
-	ignore string,
-	include string,
-	limit string,
-	nointer bool,
-	refresh bool,
-	nostd bool,
-	algo CallGraphType,
-) {
-	a.opts = &renderOpts{
-		cacheDir: cacheDir,
-		focus:    focus,
-		group:    []string{group},
-		ignore:   []string{ignore},
-		include:  []string{include},
-		limit:    []string{limit},
-		nointer:  nointer,
-		nostd:    nostd,
-	}
+// MainPackages returns the analyzed main packages, sorted by path.
+func (a *Analysis) MainPackages() []*ssa.Package {
+	mains, _ := mainPackages(a.pkgs)
+	slices.SortFunc(mains, func(x, y *ssa.Package) int {
+		return strings.Compare(x.Pkg.Path(), y.Pkg.Path())
+	})
+	return mains
 }
 
-func (a *Analysis) ProcessListArgs() (e error) {
-	var groupBy []string
-	var ignorePaths []string
-	var includePaths []string
-	var limitPaths []string
+// Clone returns a copy of a with its own options, sharing the analyzed
+// program and call graph. It allows rendering with per-request options
+// without rebuilding or affecting the analysis.
+func (a *Analysis) Clone() *Analysis {
+	c := *a
+	c.opts = a.opts.clone()
+	c.PrintOptions = maps.Clone(a.PrintOptions)
+	return &c
+}
 
-	for _, g := range strings.Split(a.opts.group[0], ",") {
-		g := strings.TrimSpace(g)
-		if g == "" {
-			continue
-		}
-		if g != "pkg" && g != "type" {
-			e = errors.New("invalid group option")
-			return
-		}
-		groupBy = append(groupBy, g)
-		a.opts.group = groupBy
+// OverrideByHTTP overrides the options by the HTTP params of r, returning
+// an error if the resulting options are invalid.
+func (a *Analysis) OverrideByHTTP(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
 	}
+	return a.OverrideByParams(r.Form)
+}
 
-	if len(a.opts.ignore) > 0 {
-		for _, p := range strings.Split(a.opts.ignore[0], ",") {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				ignorePaths = append(ignorePaths, p)
-			}
-		}
-		a.opts.ignore = ignorePaths
+// findFocusPackage resolves a single focus option given either as import
+// path, as package name or as absolute directory of the package.
+func (a *Analysis) findFocusPackage(focus string) (*types.Package, error) {
+	if filepath.IsAbs(focus) {
+		return a.packageInDir(focus)
 	}
-
-	if len(a.opts.include) > 0 {
-		for _, p := range strings.Split(a.opts.include[0], ",") {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				includePaths = append(includePaths, p)
+	ssaPkg := a.prog.ImportedPackage(focus)
+	if ssaPkg == nil {
+		if strings.Contains(focus, "/") {
+			return nil, &FocusError{Focus: focus}
+		}
+		// try to find package by name
+		foundPaths := a.index.PackagePaths(focus)
+		if len(foundPaths) == 0 {
+			return nil, &FocusError{Focus: focus}
+		} else if len(foundPaths) > 1 {
+			for _, p := range foundPaths {
+				fmt.Fprintf(os.Stderr, " - %s\n", p)
 			}
+			return nil, &FocusError{Focus: focus, Paths: foundPaths}
+		}
+		// found single package
+		if ssaPkg = a.prog.ImportedPackage(foundPaths[0]); ssaPkg == nil {
+			return nil, &FocusError{Focus: foundPaths[0]}
 		}
-		a.opts.include = includePaths
 	}
+	return ssaPkg.Pkg, nil
+}
 
-	if len(a.opts.limit) > 0 {
-		for _, p := range strings.Split(a.opts.limit[0], ",") {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				limitPaths = append(limitPaths, p)
+// packageInDir returns the package with sources in dir.
+func (a *Analysis) packageInDir(dir string) (*types.Package, error) {
+	for _, p := range a.prog.AllPackages() {
+		for _, m := range p.Members {
+			if !m.Pos().IsValid() {
+				continue
+			}
+			if filepath.Dir(a.prog.Fset.File(m.Pos()).Name()) == dir {
+				return p.Pkg, nil
 			}
+			break
 		}
-		a.opts.limit = limitPaths
 	}
-
-	return
+	return nil, &FocusError{Focus: dir}
 }
 
-func (a *Analysis) OverrideByHTTP(r *http.Request) {
-	if f := r.FormValue("f"); f == "all" {
-		a.opts.focus = ""
-	} else if f != "" {
-		a.opts.focus = f
-	}
-	if std := r.FormValue("std"); std != "" {
-		a.opts.nostd = false
-	}
-	if inter := r.FormValue("nointer"); inter != "" {
-		a.opts.nointer = true
-	}
-	if refresh := r.FormValue("refresh"); refresh != "" {
-		a.opts.refresh = true
-	}
-	if g := r.FormValue("group"); g != "" {
-		a.opts.group[0] = g
-	}
-	if l := r.FormValue("limit"); l != "" {
-		a.opts.limit[0] = l
-	}
-	if ign := r.FormValue("ignore"); ign != "" {
-		a.opts.ignore[0] = ign
-	}
-	if inc := r.FormValue("include"); inc != "" {
-		a.opts.include[0] = inc
+// basically do printOutput() with previously checking
+// focus option and respective package
+func (a *Analysis) Render(ctx context.Context, minlen uint, options map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := a.RenderTo(ctx, &buf, minlen, options); err != nil {
+		return nil, err
 	}
+	return buf.Bytes(), nil
 }
 
-// basically do printOutput() with previously checking
-// focus option and respective package
-func (a *Analysis) Render(minlen uint, options map[string]string) ([]byte, error) {
-	var (
-		err      error
-		ssaPkg   *ssa.Package
-		focusPkg *types.Package
-	)
+// RenderTo is like Render, streaming the DOT output to w.
+func (a *Analysis) RenderTo(ctx context.Context, w io.Writer, minlen uint, options map[string]string) error {
+	g, err := a.BuildGraph(ctx, minlen, options)
+	if err != nil {
+		return err
+	}
+	return g.WriteDot(w)
+}
 
-	if a.opts.focus != "" {
-		if ssaPkg = a.prog.ImportedPackage(a.opts.focus); ssaPkg == nil {
-			if strings.Contains(a.opts.focus, "/") {
-				return nil, fmt.Errorf("focus failed: %v", err)
-			}
-			// try to find package by name
-			var foundPaths []string
-			for _, p := range a.pkgs {
-				if p.Pkg.Name() == a.opts.focus {
-					foundPaths = append(foundPaths, p.Pkg.Path())
-				}
-			}
-			if len(foundPaths) == 0 {
-				return nil, fmt.Errorf("focus failed, could not find package: %v", a.opts.focus)
-			} else if len(foundPaths) > 1 {
-				for _, p := range foundPaths {
-					fmt.Fprintf(os.Stderr, " - %s\n", p)
-				}
-				return nil, fmt.Errorf("focus failed, found multiple packages with name: %v", a.opts.focus)
-			}
-			// found single package
-			if ssaPkg = a.prog.ImportedPackage(foundPaths[0]); ssaPkg == nil {
-				return nil, fmt.Errorf("focus failed: %v", err)
-			}
+// BuildGraph builds the DOT graph rendered by Render, aborting when ctx is
+// done.
+func (a *Analysis) BuildGraph(ctx context.Context, minlen uint, options map[string]string) (*dot.DotGraph, error) {
+	var focusPkgs []*types.Package
+	for _, f := range a.opts.Focus {
+		focusPkg, err := a.findFocusPackage(f)
+		if err != nil {
+			return nil, err
 		}
-		focusPkg = ssaPkg.Pkg
+		focusPkgs = append(focusPkgs, focusPkg)
 		logger.LogDebug("focusing: %v", focusPkg.Path())
 	}
 
-	dot, err := output.PrintOutput(
+	ignorePaths, err := presetPaths(a.opts.Presets)
+	if err != nil {
+		return nil, err
+	}
+	ignorePaths = append(ignorePaths, a.opts.Ignore...)
+
+	nodeFilters := slices.Clone(a.NodeFilters)
+	edgeFilters := slices.Clone(a.EdgeFilters)
+	if a.opts.Filter != "" {
+		nf, ef, err := output.ParseFilter(a.opts.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if nf != nil {
+			nodeFilters = append(nodeFilters, nf)
+		}
+		if ef != nil {
+			edgeFilters = append(edgeFilters, ef)
+		}
+	}
+
+	var unused []*ssa.Function
+	if options["unused"] == "true" {
+		unused = a.UnusedExported()
+	}
+
+	g, err := output.BuildGraph(
+		ctx,
 		a.prog,
 		a.mainPkg,
 		a.callgraph,
-		focusPkg,
-		a.opts.limit,
-		a.opts.ignore,
-		a.opts.include,
-		a.opts.group,
-		a.opts.nostd,
-		a.opts.nointer,
-		minlen,
-		options,
+		focusPkgs,
+		&output.Options{
+			LimitPaths:     a.opts.Limit,
+			IgnorePaths:    ignorePaths,
+			IncludePaths:   a.opts.Include,
+			GroupBy:        a.opts.Group,
+			NoStd:          a.opts.NoStd,
+			NoInter:        a.opts.NoInter,
+			ExportedOnly:   a.opts.ExportedOnly,
+			NoTestHelpers:  a.opts.NoTestHelpers,
+			TestCluster:    a.opts.TestCluster,
+			CrossPkgOnly:   a.opts.CrossPkg,
+			MinWeight:      a.opts.MinWeight,
+			UnfocusPaths:   a.opts.Unfocus,
+			EdgeKinds:      a.opts.Edges,
+			MaxNodes:       a.opts.MaxNodes,
+			RankBy:         a.opts.RankBy,
+			NodeFilters:    nodeFilters,
+			EdgeFilters:    edgeFilters,
+			NodeDecorators: a.NodeDecorators,
+			EdgeDecorators: a.EdgeDecorators,
+			Changed:        a.Changed,
+			Unused:         unused,
+			Errors:         a.codeErrors,
+			Theme:          a.Theme,
+			DocLinks:       a.docLinks,
+			Modules:        a.modules,
+			Index:          a.index,
+			Minlen:         minlen,
+			PrintOptions:   options,
+		},
 	)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
 		return nil, fmt.Errorf("processing failed: %v", err)
 	}
 
-	return dot, nil
+	return g, nil
 }
 
+// FindCachedImg returns the path of the cached image rendered with the
+// current sources and options, or "" if there is none.
 func (a *Analysis) FindCachedImg() string {
-	if a.opts.cacheDir == "" || a.opts.refresh {
+	if a.Images == nil || a.opts.Refresh {
 		return ""
 	}
-
-	focus := a.opts.focus
-	if focus == "" {
-		focus = "all"
-	}
-	focusFilePath := focus + "." + a.outputFormat
-	absFilePath := filepath.Join(a.opts.cacheDir, focusFilePath)
-
-	if exists, err := pathExists(absFilePath); err != nil || !exists {
-		log.Println("not cached img:", absFilePath)
+	img := a.Images.Get(a.imageKey())
+	if img == "" {
+		log.Println("not cached img")
 		return ""
 	}
-
-	log.Println("hit cached img")
-	return absFilePath
+	log.Println("hit cached img:", img)
+	return img
 }
 
-func (a *Analysis) CacheImg(img string) error {
-	if a.opts.cacheDir == "" || img == "" {
+// CacheImg adds the rendered image data to the image cache.
+func (a *Analysis) CacheImg(data []byte) error {
+	if a.Images == nil {
 		return nil
 	}
-
-	focus := a.opts.focus
-	if focus == "" {
-		focus = "all"
-	}
-	absCacheDirPrefix := filepath.Join(a.opts.cacheDir, focus)
-	absCacheDirPath := strings.TrimRightFunc(absCacheDirPrefix, func(r rune) bool {
-		return r != '\\' && r != '/'
-	})
-	err := os.MkdirAll(absCacheDirPath, os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	absFilePath := absCacheDirPrefix + "." + a.outputFormat
-	_, err = copyFile(img, absFilePath)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return a.Images.Put(a.imageKey(), data)
 }
 
-func pathExists(path string) (bool, error) {
-	_, err := os.Stat(path)
-	if err == nil {
-		return true, nil
-	}
-	if os.IsNotExist(err) {
-		return false, nil
-	}
-	return false, err
-}
-
-func copyFile(src, dst string) (int64, error) {
-	sourceFileStat, err := os.Stat(src)
-
-	if err != nil {
-		return 0, err
-	}
-
-	if !sourceFileStat.Mode().IsRegular() {
-		return 0, fmt.Errorf("%s is not a regular file", src)
-	}
-
-	source, err := os.Open(src)
-	if err != nil {
-		return 0, err
-	}
-	defer source.Close()
-
-	destination, err := os.Create(dst)
-	if err != nil {
-		return 0, err
+// SplitList splits a comma-separated option into its trimmed, non-empty
+// elements.
+func SplitList(s string) []string {
+	var list []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			list = append(list, p)
+		}
 	}
-	defer destination.Close()
-	nBytes, err := io.Copy(destination, source)
-	return nBytes, err
+	return list
 }
 
 func getBuildFlags() []string {
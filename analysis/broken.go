@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// buildAllowingErrors creates the SSA program of initial and their
+// dependencies like ssautil.AllPackages, but keeps the packages with
+// errors, as far as they were type-checked, instead of leaving them and
+// all packages importing them out. The code with errors is left out, see
+// stripErrors, and the packages are built one at a time, so a package
+// whose invalid code the SSA builder still chokes on only loses its
+// remaining functions.
+func buildAllowingErrors(initial []*packages.Package) (*ssa.Program, []*ssa.Package) {
+	var prog *ssa.Program
+	if len(initial) > 0 {
+		prog = ssa.NewProgram(initial[0].Fset, 0)
+	}
+	created := make(map[*packages.Package]*ssa.Package)
+	var order []*packages.Package
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			logger.LogWarn("%v", err)
+		}
+		if p.Types == nil || p.TypesInfo == nil {
+			return
+		}
+		stripErrors(p)
+		created[p] = prog.CreatePackage(p.Types, p.Syntax, p.TypesInfo, true)
+		order = append(order, p)
+	})
+
+	for _, p := range order {
+		if err := buildPackage(created[p]); err != nil {
+			logger.LogWarn("building %s: %v", p.PkgPath, err)
+		}
+	}
+
+	var pkgs []*ssa.Package
+	for _, p := range initial {
+		pkgs = append(pkgs, created[p]) // nil if not type-checked
+	}
+	return prog, pkgs
+}
+
+// buildPackage builds pkg, turning panics of the SSA builder on invalid
+// code into errors.
+func buildPackage(pkg *ssa.Package) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	pkg.Build()
+	return nil
+}
+
+// stripErrors empties the bodies of the functions of p containing errors,
+// keeping their positions, and drops the package variable initializers
+// containing errors, as the SSA builder expects well-typed code. The
+// functions are kept without their calls.
+func stripErrors(p *packages.Package) {
+	lines := make(map[string][]int)
+	for _, err := range p.Errors {
+		if file, line, ok := splitErrorPos(err.Pos); ok {
+			lines[file] = append(lines[file], line)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	hasError := func(n ast.Node) bool {
+		start, end := p.Fset.Position(n.Pos()), p.Fset.Position(n.End())
+		for _, line := range lines[start.Filename] {
+			if start.Line <= line && line <= end.Line {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range p.Syntax {
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil && hasError(fd.Body) {
+				logger.LogDebug("leaving out the body of %s with errors", fd.Name.Name)
+				fd.Body = &ast.BlockStmt{Lbrace: fd.Body.Lbrace, Rbrace: fd.Body.Rbrace}
+			}
+		}
+	}
+	p.TypesInfo.InitOrder = slices.DeleteFunc(p.TypesInfo.InitOrder, func(init *types.Initializer) bool {
+		return hasError(init.Rhs)
+	})
+}
+
+// errorPos matches the position of a package error, like file.go:12:5 or
+// file.go:12.
+var errorPos = regexp.MustCompile(`^(.+?):(\d+)(?::\d+)?$`)
+
+// splitErrorPos splits the position of a package error into its file and
+// line.
+func splitErrorPos(pos string) (string, int, bool) {
+	m := errorPos.FindStringSubmatch(pos)
+	if m == nil {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(m[2])
+	return m[1], line, err == nil
+}
@@ -0,0 +1,313 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+// graphCacheVersion is part of every cache key, bump it when the format of
+// cachedGraph changes.
+const graphCacheVersion = "1"
+
+// cachedGraph is the on-disk form of a call graph: a symbol table of the
+// functions and the edges between them, identified by index.
+type cachedGraph struct {
+	Funcs []string
+	Edges []cachedEdge
+}
+
+type cachedEdge struct {
+	Caller, Callee int
+	// Site is the position of the call instruction, empty for edges
+	// without a call site.
+	Site string
+}
+
+// graphCacheKey hashes everything the call graph depends on: the algorithm,
+// the load configuration, the module files and the source of every loaded
+// package.
+func graphCacheKey(algo CallGraphType, cfg *packages.Config, args []string, initial []*packages.Package) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, graphCacheVersion, runtime.Version(), algo, cfg.Tests, cfg.BuildFlags, args, packagesDriver(cfg.Env))
+	// the build environment selects the files, also of the standard library
+	for _, key := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT"} {
+		fmt.Fprintln(h, key, getenv(cfg.Env, key))
+	}
+
+	var files []string
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		// the standard library is covered by the Go version
+		if p.Module == nil && isStdPath(p.PkgPath) {
+			return
+		}
+		files = append(files, p.CompiledGoFiles...)
+		if p.Module != nil && p.Module.GoMod != "" {
+			files = append(files, p.Module.GoMod, strings.TrimSuffix(p.Module.GoMod, ".mod")+".sum")
+		}
+	})
+	sort.Strings(files)
+
+	seen := make(map[string]bool)
+	for _, name := range files {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if data, ok := cfg.Overlay[name]; ok {
+			fmt.Fprintln(h, name)
+			h.Write(data)
+			continue
+		}
+		f, err := os.Open(name)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintln(h, name)
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// funcKey identifies fn across runs by its name and position. Instances
+// of generic functions, and the closures in them, are named after their
+// origin and type arguments, as their names spell type arguments with
+// whichever alias was seen first.
+func funcKey(prog *ssa.Program, fn *ssa.Function) string {
+	if parent := fn.Parent(); parent != nil && len(parent.TypeArgs()) > 0 {
+		name := fn.Name()
+		return funcKey(prog, parent) + name[strings.LastIndex(name, "$"):]
+	}
+	if args := fn.TypeArgs(); len(args) > 0 && fn.Origin() != nil {
+		var list []string
+		for _, t := range args {
+			list = append(list, typeKey(t))
+		}
+		return funcKey(prog, fn.Origin()) + "[" + strings.Join(list, ",") + "]"
+	}
+	return fn.String() + "@" + prog.Fset.Position(fn.Pos()).String()
+}
+
+// typeKey spells t without aliases. Types it does not look into fall back
+// to types.TypeString.
+func typeKey(t types.Type) string {
+	switch t := types.Unalias(t).(type) {
+	case *types.Pointer:
+		return "*" + typeKey(t.Elem())
+	case *types.Slice:
+		return "[]" + typeKey(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeKey(t.Elem()))
+	case *types.Map:
+		return "map[" + typeKey(t.Key()) + "]" + typeKey(t.Elem())
+	case *types.Chan:
+		return fmt.Sprintf("chan(%d) %s", t.Dir(), typeKey(t.Elem()))
+	case *types.Named:
+		name := t.Obj().Name()
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			name = pkg.Path() + "." + name
+		}
+		if args := t.TypeArgs(); args.Len() > 0 {
+			var list []string
+			for i := 0; i < args.Len(); i++ {
+				list = append(list, typeKey(args.At(i)))
+			}
+			name += "[" + strings.Join(list, ",") + "]"
+		}
+		return name
+	default:
+		return types.TypeString(t, nil)
+	}
+}
+
+// DefaultCacheDir returns the cache directory of the module containing
+// dir, in the user cache directory, e.g. $XDG_CACHE_HOME/go-callvis on
+// Linux. Each module gets its own directory, named after its root and a
+// hash of its path, so the caches of modules do not mix. Outside modules,
+// dir is taken as root.
+func DefaultCacheDir(dir string) (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for d := root; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			root = d
+			break
+		}
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	sum := sha256.Sum256([]byte(root))
+	name := filepath.Base(root) + "-" + hex.EncodeToString(sum[:6])
+	return filepath.Join(base, "go-callvis", name), nil
+}
+
+func graphCachePath(cacheDir, key string) string {
+	return filepath.Join(cacheDir, "callgraph-"+key+".gob")
+}
+
+// saveGraph writes g to the cache directory under key.
+func saveGraph(cacheDir, key string, prog *ssa.Program, g *callgraph.Graph) error {
+	var cg cachedGraph
+	index := make(map[*ssa.Function]int)
+	for fn := range g.Nodes {
+		if fn == nil {
+			continue
+		}
+		index[fn] = len(cg.Funcs)
+		cg.Funcs = append(cg.Funcs, funcKey(prog, fn))
+	}
+	for fn, n := range g.Nodes {
+		if fn == nil {
+			continue
+		}
+		for _, e := range n.Out {
+			ce := cachedEdge{Caller: index[e.Caller.Func], Callee: index[e.Callee.Func]}
+			if e.Site != nil {
+				ce.Site = prog.Fset.Position(e.Site.Pos()).String()
+			}
+			cg.Edges = append(cg.Edges, ce)
+		}
+	}
+
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return err
+	}
+	// write to a temporary file first, so readers never see partial files
+	f, err := os.CreateTemp(cacheDir, "callgraph-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := gob.NewEncoder(f).Encode(&cg); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), graphCachePath(cacheDir, key))
+}
+
+// loadGraph reads the call graph cached under key and resolves it against
+// prog. It returns nil if there is no usable cached graph. As rendering
+// needs the functions of the graph in SSA form, prog must be built: the
+// cache saves running the call graph algorithm, not loading the program.
+func loadGraph(cacheDir, key string, prog *ssa.Program) (*callgraph.Graph, error) {
+	f, err := os.Open(graphCachePath(cacheDir, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cg cachedGraph
+	if err := gob.NewDecoder(f).Decode(&cg); err != nil {
+		return nil, fmt.Errorf("invalid cached call graph: %v", err)
+	}
+
+	funcs := make(map[string]*ssa.Function)
+	for fn := range allFunctions(prog) {
+		funcs[funcKey(prog, fn)] = fn
+	}
+	resolved := make([]*ssa.Function, len(cg.Funcs))
+	for i, key := range cg.Funcs {
+		fn, ok := funcs[key]
+		if !ok {
+			logger.LogDebug("cached call graph: unknown function %s", key)
+			return nil, nil
+		}
+		resolved[i] = fn
+	}
+
+	g := callgraph.New(nil)
+	for _, fn := range resolved {
+		g.CreateNode(fn)
+	}
+	for _, ce := range cg.Edges {
+		caller, callee := resolved[ce.Caller], resolved[ce.Callee]
+		var site ssa.CallInstruction
+		if ce.Site != "" {
+			if site = findCallSite(prog, caller, callee, ce.Site); site == nil {
+				logger.LogDebug("cached call graph: unknown call site %s in %s", ce.Site, caller)
+				return nil, nil
+			}
+		}
+		callgraph.AddEdge(g.CreateNode(caller), site, g.CreateNode(callee))
+	}
+	return g, nil
+}
+
+// allFunctions returns ssautil.AllFunctions plus the functions only
+// referenced from function bodies, like instances of generic functions.
+func allFunctions(prog *ssa.Program) map[*ssa.Function]bool {
+	all := ssautil.AllFunctions(prog)
+	var queue []*ssa.Function
+	for fn := range all {
+		queue = append(queue, fn)
+	}
+	for len(queue) > 0 {
+		fn := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				for _, op := range instr.Operands(nil) {
+					if f, ok := (*op).(*ssa.Function); ok && !all[f] {
+						all[f] = true
+						queue = append(queue, f)
+					}
+				}
+			}
+		}
+	}
+	return all
+}
+
+// findCallSite returns the call instruction of fn at the given position,
+// preferring the one statically calling callee, as implicit calls, like
+// those to package initializers, share the same missing position.
+func findCallSite(prog *ssa.Program, fn, callee *ssa.Function, pos string) ssa.CallInstruction {
+	var found ssa.CallInstruction
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			site, ok := instr.(ssa.CallInstruction)
+			if !ok || prog.Fset.Position(site.Pos()).String() != pos {
+				continue
+			}
+			if site.Common().StaticCallee() == callee {
+				return site
+			}
+			if found == nil {
+				found = site
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/packages"
+)
+
+const driverEnv = "GOPACKAGESDRIVER"
+
+// loadEnv returns the environment of the package loader, selecting the
+// Driver program if set and overridden by Env.
+func (a *Analysis) loadEnv() []string {
+	env := os.Environ()
+	if a.Driver != "" {
+		env = append(env, driverEnv+"="+a.Driver)
+	}
+	return append(env, a.Env...)
+}
+
+// getenv returns the value of the variable key in env, the last one if
+// set several times, as in os/exec.
+func getenv(env []string, key string) string {
+	for i := len(env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(env[i], "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// packagesDriver returns the external driver go/packages uses in env, like
+// the gopackagesdriver of rules_go for Bazel, or "" for go list. As in
+// go/packages, GOPACKAGESDRIVER names the driver, off disabling it, and
+// otherwise a gopackagesdriver program on the PATH is used.
+func packagesDriver(env []string) string {
+	driver := getenv(env, driverEnv)
+	if driver == "off" {
+		return ""
+	}
+	if driver == "" {
+		driver, _ = exec.LookPath("gopackagesdriver")
+	}
+	return driver
+}
+
+// markStdPackages tells the renderer which packages belong to the standard
+// library. Build systems like Bazel build it from their own SDK, out of the
+// GOROOT the renderer looks packages up in, and provide no modules: the
+// standard library is then recognized by its import paths.
+func markStdPackages(initial []*packages.Package) {
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		output.MarkStd(p.PkgPath, p.Module == nil && isStdPath(p.PkgPath))
+	})
+}
@@ -0,0 +1,84 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// stubDriver writes a packages driver to dir answering every request with
+// the package of file, as example.com/stub.
+func stubDriver(t *testing.T, dir, file string) string {
+	t.Helper()
+	response := fmt.Sprintf(`{
+  "Compiler": "gc",
+  "Arch": %q,
+  "Roots": ["example.com/stub"],
+  "Packages": [{
+    "ID": "example.com/stub",
+    "Name": "main",
+    "PkgPath": "example.com/stub",
+    "GoFiles": [%[2]q],
+    "CompiledGoFiles": [%[2]q]
+  }]
+}`, runtime.GOARCH, file)
+	driver := filepath.Join(dir, "driver.sh")
+	script := "#!/bin/sh\ncat >/dev/null\ncat <<'EOF'\n" + response + "\nEOF\n"
+	if err := os.WriteFile(driver, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return driver
+}
+
+func TestDoAnalysisWithDriver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub driver is a shell script")
+	}
+	if err := logger.InitializeLogger(logger.ErrorLevel); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc main() { helper() }\n\nfunc helper() {}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAnalysis("")
+	if err := a.SetOptions(DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	a.Driver = stubDriver(t, dir, file)
+	// patterns go list cannot resolve, only the driver knows them
+	if err := a.DoAnalysis(context.Background(), dir, false, []string{"//stub:target"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, pkg := range a.Packages() {
+		if pkg != nil {
+			paths = append(paths, pkg.Pkg.Path())
+		}
+	}
+	if len(paths) != 1 || paths[0] != "example.com/stub" {
+		t.Fatalf("analyzed packages %v, want the one of the driver", paths)
+	}
+	if a.MainPackages()[0].Func("helper") == nil {
+		t.Errorf("the package of the driver lacks helper")
+	}
+	if packagesDriver(a.loadEnv()) != a.Driver {
+		t.Errorf("packagesDriver = %q, want %q", packagesDriver(a.loadEnv()), a.Driver)
+	}
+}
+
+func TestPackagesDriverOff(t *testing.T) {
+	env := []string{driverEnv + "=/usr/bin/driver", driverEnv + "=off"}
+	if driver := packagesDriver(env); driver != "" {
+		t.Errorf("packagesDriver = %q with the driver off, want none", driver)
+	}
+}
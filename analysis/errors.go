@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// A LoadError reports that the packages to analyze could not be loaded or
+// type-checked.
+type LoadError struct {
+	// Patterns are the package patterns loaded.
+	Patterns []string
+	// Errors are the errors of the loaded packages, if any.
+	Errors []packages.Error
+	// Err is the error of loading, if the packages were not loaded.
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	if e.Err != nil {
+		if len(e.Patterns) == 0 {
+			return fmt.Sprintf("loading packages: %v", e.Err)
+		}
+		return fmt.Sprintf("loading %s: %v", strings.Join(e.Patterns, " "), e.Err)
+	}
+	if len(e.Errors) == 1 {
+		return fmt.Sprintf("packages contain errors: %v", e.Errors[0])
+	}
+	return fmt.Sprintf("packages contain errors: %v (and %d more)", e.Errors[0], len(e.Errors)-1)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// ErrNoMainPackages reports that the analyzed packages have no main
+// package, which the rta algorithm needs for its roots.
+var ErrNoMainPackages = errors.New("no main packages")
+
+// A FocusError reports that a focused package was not found, or is
+// ambiguous.
+type FocusError struct {
+	// Focus is the focused package as given.
+	Focus string
+	// Paths are the import paths of the packages named Focus, if several.
+	Paths []string
+}
+
+func (e *FocusError) Error() string {
+	if len(e.Paths) > 1 {
+		return fmt.Sprintf("focus failed, found multiple packages with name: %v", e.Focus)
+	}
+	return fmt.Sprintf("focus failed, could not find package: %v", e.Focus)
+}
+
+// packageErrors returns the errors of pkgs and their dependencies.
+func packageErrors(pkgs []*packages.Package) []packages.Error {
+	var errs []packages.Error
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		errs = append(errs, p.Errors...)
+	})
+	return errs
+}
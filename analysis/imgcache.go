@@ -0,0 +1,264 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"github.com/ofabry/go-callvis/pkg/output"
+)
+
+// imgCachePrefix prefixes the names of cached images, which share the
+// cache directory with the cached call graphs.
+const imgCachePrefix = "img-"
+
+// An ImageCache keeps rendered images in a directory, keyed by everything
+// they depend on. The least recently used images are evicted when the
+// cache grows beyond its maximum size, and images expire after the TTL.
+type ImageCache struct {
+	dir     string
+	maxSize int64
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*imgEntry
+	size      int64
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type imgEntry struct {
+	path    string
+	size    int64
+	created time.Time
+	used    time.Time
+}
+
+// ImageCacheStats describes the state of an ImageCache.
+type ImageCacheStats struct {
+	Dir       string `json:"dir"`
+	Entries   int    `json:"entries"`
+	Size      int64  `json:"size"`
+	MaxSize   int64  `json:"maxSize"`
+	TTL       string `json:"ttl"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+}
+
+// NewImageCache returns the image cache in dir, picking up the images
+// cached before. A maxSize in bytes or ttl of zero means no limit.
+func NewImageCache(dir string, maxSize int64, ttl time.Duration) *ImageCache {
+	c := &ImageCache{
+		dir:     dir,
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[string]*imgEntry),
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, imgCachePrefix+"*"))
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		key := strings.TrimPrefix(filepath.Base(f), imgCachePrefix)
+		c.entries[key] = &imgEntry{path: f, size: fi.Size(), created: fi.ModTime(), used: fi.ModTime()}
+		c.size += fi.Size()
+	}
+	c.mu.Lock()
+	c.evict(time.Now())
+	c.mu.Unlock()
+	return c
+}
+
+// Get returns the path of the image cached under key, or "" if there is
+// none.
+func (c *ImageCache) Get(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	e, ok := c.entries[key]
+	if ok && c.ttl > 0 && now.Sub(e.created) > c.ttl {
+		c.remove(key)
+		c.evictions++
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return ""
+	}
+	e.used = now
+	c.hits++
+	return e.path
+}
+
+// Put adds the image data to the cache under key, evicting images as
+// needed to stay within the limits.
+func (c *ImageCache) Put(key string, data []byte) error {
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return err
+	}
+	path := filepath.Join(c.dir, imgCachePrefix+key)
+	// write to a temporary file first, so readers never see partial files
+	tmp, err := os.CreateTemp(c.dir, "img-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	if old, ok := c.entries[key]; ok {
+		c.size -= old.size
+	}
+	now := time.Now()
+	c.entries[key] = &imgEntry{path: path, size: int64(len(data)), created: now, used: now}
+	c.size += int64(len(data))
+	c.evict(now)
+	return nil
+}
+
+// Purge removes all cached images.
+func (c *ImageCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		c.remove(key)
+	}
+}
+
+// Stats returns the current state of the cache.
+func (c *ImageCache) Stats() ImageCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ImageCacheStats{
+		Dir:       c.dir,
+		Entries:   len(c.entries),
+		Size:      c.size,
+		MaxSize:   c.maxSize,
+		TTL:       c.ttl.String(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+// evict removes the expired images and then the least recently used ones
+// until the cache fits its maximum size. c.mu must be held.
+func (c *ImageCache) evict(now time.Time) {
+	var keys []string
+	for key, e := range c.entries {
+		if c.ttl > 0 && now.Sub(e.created) > c.ttl {
+			c.remove(key)
+			c.evictions++
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if c.maxSize <= 0 || c.size <= c.maxSize {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].used.Before(c.entries[keys[j]].used)
+	})
+	for _, key := range keys {
+		if c.size <= c.maxSize {
+			break
+		}
+		c.remove(key)
+		c.evictions++
+	}
+}
+
+// remove deletes the image cached under key. c.mu must be held.
+func (c *ImageCache) remove(key string) {
+	e := c.entries[key]
+	if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+		logger.LogWarn("removing cached image: %v", err)
+	}
+	c.size -= e.size
+	delete(c.entries, key)
+}
+
+// dotKey hashes the DOT source of an image. The lines are hashed in sorted
+// order, as the order of nodes and edges varies between renders of the
+// same graph, and without the time of the footer, which differs on every
+// render.
+func (a *Analysis) dotKey(dot []byte) string {
+	lines := bytes.Split(output.StripFooterTime(dot), []byte("\n"))
+	slices.SortFunc(lines, bytes.Compare)
+	h := sha256.New()
+	for _, l := range lines {
+		h.Write(l)
+		h.Write([]byte("\n"))
+	}
+	return "dot-" + hex.EncodeToString(h.Sum(nil)) + "." + a.outputFormat
+}
+
+// FindDotImg returns the path of a cached image rendered from the same DOT
+// source, whichever options produced it, or "" if there is none.
+func (a *Analysis) FindDotImg(dot []byte) string {
+	if a.Images == nil || a.opts.Refresh {
+		return ""
+	}
+	img := a.Images.Get(a.dotKey(dot))
+	if img != "" {
+		log.Println("hit cached img of same dot:", img)
+	}
+	return img
+}
+
+// CacheDotImg adds the image data rendered from dot to the image cache.
+func (a *Analysis) CacheDotImg(dot []byte, data []byte) error {
+	if a.Images == nil {
+		return nil
+	}
+	return a.Images.Put(a.dotKey(dot), data)
+}
+
+// imageKey hashes everything a rendered image depends on: the analyzed
+// sources, the options, the theme and the output format.
+func (a *Analysis) imageKey() string {
+	h := sha256.New()
+	fmt.Fprintln(h, a.sourceKey, a.outputFormat, a.Minlen)
+
+	opts := *a.opts
+	opts.Refresh = false
+	fmt.Fprintf(h, "%+v\n", opts)
+
+	keys := make([]string, 0, len(a.PrintOptions))
+	for k := range a.PrintOptions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%q\n", k, a.PrintOptions[k])
+	}
+
+	if err := json.NewEncoder(h).Encode(a.Theme); err != nil {
+		logger.LogWarn("hashing theme: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)) + "." + a.outputFormat
+}
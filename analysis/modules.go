@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const docSite = "https://pkg.go.dev/"
+
+// docLinks maps the paths of packages of the standard library and of
+// published modules to their documentation page, pinned to the module
+// version in use. Packages of the main module and of modules replaced by
+// local directories have no published documentation.
+func docLinks(initial []*packages.Package) map[string]string {
+	links := make(map[string]string)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if link := docLink(p); link != "" {
+			links[p.PkgPath] = link
+		}
+	})
+	return links
+}
+
+func docLink(p *packages.Package) string {
+	if p.Module == nil {
+		if isStdPath(p.PkgPath) {
+			return docSite + p.PkgPath
+		}
+		return ""
+	}
+	if p.Module.Main {
+		return ""
+	}
+	mod := p.Module
+	if mod.Replace != nil {
+		mod = mod.Replace
+	}
+	if mod.Version == "" {
+		return ""
+	}
+	sub := strings.TrimPrefix(p.PkgPath, p.Module.Path)
+	return docSite + mod.Path + "@" + mod.Version + sub
+}
+
+// isStdPath reports whether path belongs to the standard library, whose
+// import paths have no dot in their first element.
+func isStdPath(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".") && path != "command-line-arguments"
+}
+
+// modulePaths maps package paths to the path of the module they belong to.
+func modulePaths(initial []*packages.Package) map[string]string {
+	mods := make(map[string]string)
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if p.Module != nil {
+			mods[p.PkgPath] = p.Module.Path
+		}
+	})
+	return mods
+}
@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ofabry/go-callvis/pkg/output"
+)
+
+// Options configure the analysis and which part of the call graph is
+// rendered. Lists are given element-wise, see SplitList for parsing
+// comma-separated options.
+type Options struct {
+	// Algo is the algorithm constructing the call graph, CHA by default.
+	Algo CallGraphType
+	// CacheDir enables caching the call graph and snapshots of rendered
+	// graphs in the directory. A cached call graph skips the call graph
+	// algorithm, a snapshot the whole analysis, see LoadSnapshot.
+	CacheDir string
+	// Refresh ignores the cached call graph and images.
+	Refresh bool
+
+	// Focus lists the packages in focus, by import path or name.
+	Focus []string
+	// Group groups the functions by "pkg" and/or "type".
+	Group []string
+	// Limit, Ignore and Include filter the packages by path prefix.
+	Limit   []string
+	Ignore  []string
+	Include []string
+	// Unfocus removes packages and everything only reachable through them.
+	Unfocus []string
+	// Presets ignore well-known noise packages, see PresetNames.
+	Presets []string
+	// Edges keeps only the given kinds of calls, see output.EdgeKinds.
+	Edges []string
+
+	// Tests includes test code, which NoTestHelpers and TestCluster
+	// require. DoAnalysis sets it to whether it loads test code.
+	Tests         bool
+	NoStd         bool
+	NoInter       bool
+	ExportedOnly  bool
+	NoTestHelpers bool
+	TestCluster   bool
+	CrossPkg      bool
+	MinWeight     uint
+	// MaxNodes keeps the nodes ranked highest by RankBy, PageRank by
+	// default.
+	MaxNodes uint
+	RankBy   string
+	// Filter is a filter expression, see output.ParseFilter.
+	Filter string
+}
+
+// DefaultOptions returns the options of go-callvis without flags.
+func DefaultOptions() Options {
+	return Options{
+		Algo:   CallGraphTypeCha,
+		Focus:  []string{"main"},
+		Group:  []string{"pkg"},
+		RankBy: output.RankPageRank,
+	}
+}
+
+// Validate checks the options, returning the first invalid one.
+func (o *Options) Validate() error {
+	switch o.Algo {
+	case CallGraphTypeStatic, CallGraphTypeCha, CallGraphTypeRta:
+	default:
+		return fmt.Errorf("invalid call graph type: %s", o.Algo)
+	}
+	for _, g := range o.Group {
+		if g != "pkg" && g != "type" {
+			return errors.New("invalid group option")
+		}
+	}
+	for _, k := range o.Edges {
+		if !slices.Contains(output.EdgeKinds, k) {
+			return fmt.Errorf("invalid edges option %q", k)
+		}
+	}
+	if o.RankBy != "" && !slices.Contains(output.RankMetrics, o.RankBy) {
+		return fmt.Errorf("invalid rankby option %q", o.RankBy)
+	}
+	if o.NoTestHelpers && !o.Tests {
+		return errors.New("-notesthelpers requires -tests")
+	}
+	if o.TestCluster && !o.Tests {
+		return errors.New("-testcluster requires -tests")
+	}
+	if _, err := presetPaths(o.Presets); err != nil {
+		return err
+	}
+	if o.Filter != "" {
+		if _, _, err := output.ParseFilter(o.Filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone returns a copy of o with its own lists.
+func (o *Options) clone() *Options {
+	c := *o
+	c.Focus = slices.Clone(o.Focus)
+	c.Group = slices.Clone(o.Group)
+	c.Limit = slices.Clone(o.Limit)
+	c.Ignore = slices.Clone(o.Ignore)
+	c.Include = slices.Clone(o.Include)
+	c.Unfocus = slices.Clone(o.Unfocus)
+	c.Presets = slices.Clone(o.Presets)
+	c.Edges = slices.Clone(o.Edges)
+	return &c
+}
+
+// SetOptions validates and sets the options of a, defaulting the
+// algorithm to CHA and the rank metric to PageRank.
+func (a *Analysis) SetOptions(opts Options) error {
+	if opts.Algo == "" {
+		opts.Algo = CallGraphTypeCha
+	}
+	if opts.RankBy == "" {
+		opts.RankBy = output.RankPageRank
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	a.opts = opts.clone()
+	return nil
+}
+
+// Options returns a copy of the options of a.
+func (a *Analysis) Options() Options {
+	return *a.opts.clone()
+}
@@ -0,0 +1,40 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LoadOverlay reads an overlay file in the format of go build -overlay, a
+// JSON object whose Replace field maps source files to the files replacing
+// them, and returns the overlay of the package loader: the contents of the
+// replacements by absolute path of the replaced files. Editors use it to
+// analyze unsaved buffers.
+func LoadOverlay(path string) (map[string][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ov struct {
+		Replace map[string]string
+	}
+	if err := json.Unmarshal(data, &ov); err != nil {
+		return nil, fmt.Errorf("invalid overlay %s: %v", path, err)
+	}
+	overlay := make(map[string][]byte, len(ov.Replace))
+	for from, to := range ov.Replace {
+		if to == "" {
+			return nil, fmt.Errorf("invalid overlay %s: removing %s is not supported", path, from)
+		}
+		abs, err := filepath.Abs(from)
+		if err != nil {
+			return nil, err
+		}
+		if overlay[abs], err = os.ReadFile(to); err != nil {
+			return nil, err
+		}
+	}
+	return overlay, nil
+}
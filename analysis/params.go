@@ -0,0 +1,233 @@
+package analysis
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/output"
+)
+
+// A paramKind parses and validates the values of a param.
+type paramKind func(value string) (string, error)
+
+// boolParam takes true or false, normalized for the print options.
+func boolParam(value string) (string, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", fmt.Errorf("want true or false")
+	}
+	return strconv.FormatBool(b), nil
+}
+
+func uintParam(value string) (string, error) {
+	if _, err := strconv.ParseUint(value, 10, 0); err != nil {
+		return "", fmt.Errorf("want a non-negative integer")
+	}
+	return value, nil
+}
+
+func floatParam(value string) (string, error) {
+	if f, err := strconv.ParseFloat(value, 64); err != nil || f < 0 {
+		return "", fmt.Errorf("want a non-negative number")
+	}
+	return value, nil
+}
+
+// stringParam takes any value, for options which are only written to DOT
+// quoted, such as label templates.
+func stringParam(value string) (string, error) {
+	return value, nil
+}
+
+// dotParam takes the values of the graph option kind.
+func dotParam(kind dot.OptionKind) paramKind {
+	return func(value string) (string, error) {
+		if err := kind(value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+}
+
+// textParam takes free text without quotes, backslashes or control
+// characters.
+var textParam = dotParam(dot.TextOption)
+
+// oneOf takes one of values.
+func oneOf(values ...string) paramKind {
+	return func(value string) (string, error) {
+		if !slices.Contains(values, value) {
+			return "", fmt.Errorf("want one of %v", values)
+		}
+		return value, nil
+	}
+}
+
+// ratioParam takes a ratio mode or a number.
+func ratioParam(value string) (string, error) {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value, nil
+	}
+	if _, err := oneOf("fill", "compress", "expand", "auto")(value); err != nil {
+		return "", fmt.Errorf("want fill, compress, expand, auto or a number")
+	}
+	return value, nil
+}
+
+// httpPrintOptions are the print options which can also be set by params,
+// by name, with their kind of value. Options naming files on the host are
+// left out.
+var httpPrintOptions = map[string]paramKind{
+	"minlen":           uintParam,
+	"nodesep":          floatParam,
+	"nodeshape":        dotParam(dot.ShapeOption),
+	"nodestyle":        dotParam(dot.StyleOption),
+	"rankdir":          oneOf("LR", "RL", "TB", "BT"),
+	"nodelabel":        stringParam,
+	"edgelabel":        oneOf(output.EdgeLabelNone, output.EdgeLabelFirst, output.EdgeLabelAll),
+	"nodefontname":     textParam,
+	"nodefontsize":     floatParam,
+	"edgefontname":     textParam,
+	"edgefontsize":     floatParam,
+	"clusterfontname":  textParam,
+	"clusterfontsize":  floatParam,
+	"clusterlabel":     stringParam,
+	"clusterlabelloc":  oneOf("t", "b"),
+	"clusterlabeljust": oneOf("l", "c", "r"),
+	"focuspenwidth":    floatParam,
+	"dim":              boolParam,
+	"highlight":        textParam,
+	"highlightpaths":   boolParam,
+	"trace":            textParam,
+	"samerank":         oneOf(output.SameRankNone, output.SameRankExported, output.SameRankEntry),
+	"nodesizeby":       oneOf(output.SizeMetrics...),
+	"heatby":           oneOf(output.HeatMetrics...),
+	"recursion":        boolParam,
+	"unused":           boolParam,
+	"panics":           boolParam,
+	"title":            textParam,
+	"bgcolor":          dotParam(dot.ColorOption),
+	"dpi":              floatParam,
+	"size":             dotParam(dot.SizeOption),
+	"ratio":            ratioParam,
+	"splines":          oneOf(output.SplinesSpline, output.SplinesOrtho, output.SplinesPolyline, output.SplinesCurved),
+	"concentrate":      boolParam,
+	"imports":          boolParam,
+	"expand":           textParam,
+	"granularity":      oneOf(output.Granularities...),
+	"interfaces":       boolParam,
+	"interfacecalls":   boolParam,
+}
+
+// optionParams parse the params setting the options, by name.
+var optionParams = map[string]func(o *Options, value string) error{
+	"f": func(o *Options, value string) error {
+		if value == "all" {
+			o.Focus = nil
+		} else {
+			o.Focus = SplitList(value)
+		}
+		return nil
+	},
+	"algo":    func(o *Options, value string) error { o.Algo = CallGraphType(value); return nil },
+	"group":   func(o *Options, value string) error { o.Group = SplitList(value); return nil },
+	"limit":   func(o *Options, value string) error { o.Limit = SplitList(value); return nil },
+	"ignore":  func(o *Options, value string) error { o.Ignore = SplitList(value); return nil },
+	"include": func(o *Options, value string) error { o.Include = SplitList(value); return nil },
+	"unfocus": func(o *Options, value string) error { o.Unfocus = SplitList(value); return nil },
+	"preset":  func(o *Options, value string) error { o.Presets = SplitList(value); return nil },
+	"edges":   func(o *Options, value string) error { o.Edges = SplitList(value); return nil },
+	"rankby":  func(o *Options, value string) error { o.RankBy = value; return nil },
+	"filter":  func(o *Options, value string) error { o.Filter = value; return nil },
+
+	"nostd": boolOption(func(o *Options, b bool) { o.NoStd = b }),
+	// std is the inverse of nostd, kept for older viewer links
+	"std":           boolOption(func(o *Options, b bool) { o.NoStd = !b }),
+	"nointer":       boolOption(func(o *Options, b bool) { o.NoInter = b }),
+	"exportedonly":  boolOption(func(o *Options, b bool) { o.ExportedOnly = b }),
+	"notesthelpers": boolOption(func(o *Options, b bool) { o.NoTestHelpers = b }),
+	"testcluster":   boolOption(func(o *Options, b bool) { o.TestCluster = b }),
+	"crosspkg":      boolOption(func(o *Options, b bool) { o.CrossPkg = b }),
+	"refresh":       boolOption(func(o *Options, b bool) { o.Refresh = b }),
+
+	"minweight": uintOption(func(o *Options, n uint) { o.MinWeight = n }),
+	"maxnodes":  uintOption(func(o *Options, n uint) { o.MaxNodes = n }),
+}
+
+func boolOption(set func(o *Options, b bool)) func(o *Options, value string) error {
+	return func(o *Options, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("want true or false")
+		}
+		set(o, b)
+		return nil
+	}
+}
+
+func uintOption(set func(o *Options, n uint)) func(o *Options, value string) error {
+	return func(o *Options, value string) error {
+		n, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return fmt.Errorf("want a non-negative integer")
+		}
+		set(o, uint(n))
+		return nil
+	}
+}
+
+// OverrideByParams overrides the options by params named like the flags,
+// as used by the interactive viewer, e.g. nostd=false or rankdir=TB. Empty
+// params are ignored, the other values are validated, returning an error
+// naming the first invalid param. Switching the algorithm builds the call
+// graph again from the analyzed program.
+func (a *Analysis) OverrideByParams(params url.Values) error {
+	algo := a.opts.Algo
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+		var err error
+		if parse, ok := optionParams[name]; ok {
+			err = parse(a.opts, value)
+		} else if kind, ok := httpPrintOptions[name]; ok {
+			var v string
+			if v, err = kind(value); err == nil {
+				a.PrintOptions[name] = v
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid param %s=%q: %v", name, value, err)
+		}
+	}
+	if minlen := params.Get("minlen"); minlen != "" {
+		n, _ := strconv.ParseUint(minlen, 10, 0)
+		a.Minlen = uint(n)
+	}
+	if err := a.opts.Validate(); err != nil {
+		return err
+	}
+	if a.opts.Algo != algo && a.prog != nil {
+		return a.rebuildCallGraph()
+	}
+	return nil
+}
+
+// rebuildCallGraph builds the call graph of the analyzed program with the
+// algorithm of the options, replacing the shared one of a only.
+func (a *Analysis) rebuildCallGraph() error {
+	graph, mainPkg, err := buildCallGraph(a.opts.Algo, a.prog)
+	if err != nil {
+		return err
+	}
+	graph.DeleteSyntheticNodes()
+	a.callgraph = graph
+	a.mainPkg = mainPkg
+	a.index = output.NewGraphIndex(graph, a.pkgs)
+	return nil
+}
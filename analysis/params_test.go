@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// paramAnalysis returns an analysis with the default options, as the
+// viewer overrides them.
+func paramAnalysis(t *testing.T) *Analysis {
+	t.Helper()
+	a := NewAnalysis("svg")
+	if err := a.SetOptions(DefaultOptions()); err != nil {
+		t.Fatal(err)
+	}
+	a.Minlen = 2
+	a.PrintOptions = map[string]string{"nodeshape": "box", "heatfile": "profile.out"}
+	return a
+}
+
+func TestOverrideByParams(t *testing.T) {
+	a := paramAnalysis(t)
+	params := url.Values{
+		"f":         {"all"},
+		"nostd":     {"1"},
+		"group":     {"pkg,type"},
+		"minlen":    {"4"},
+		"nodeshape": {"ellipse"},
+		"bgcolor":   {"#ffffff"},
+		"dim":       {"T"},
+		"heatfile":  {"/etc/passwd"},
+		"rankdir":   {""},
+	}
+	if err := a.OverrideByParams(params); err != nil {
+		t.Fatal(err)
+	}
+	if a.opts.Focus != nil || !a.opts.NoStd || !slices.Equal(a.opts.Group, []string{"pkg", "type"}) {
+		t.Errorf("options are %+v", a.opts)
+	}
+	if a.Minlen != 4 {
+		t.Errorf("minlen = %d, want 4", a.Minlen)
+	}
+	for name, want := range map[string]string{
+		"nodeshape": "ellipse",
+		"bgcolor":   "#ffffff",
+		"dim":       "true",
+		"heatfile":  "profile.out",
+	} {
+		if got := a.PrintOptions[name]; got != want {
+			t.Errorf("print option %s = %q, want %q", name, got, want)
+		}
+	}
+	if _, ok := a.PrintOptions["rankdir"]; ok {
+		t.Errorf("the empty rankdir param set the print option")
+	}
+}
+
+func TestOverrideByParamsRejectsInvalid(t *testing.T) {
+	for _, param := range []string{
+		"nostd=maybe",
+		"minweight=-1",
+		"rankdir=XY",
+		`nodeshape=box" image="/etc/passwd`,
+		`bgcolor=red"; node [image="/etc/passwd"]`,
+		`title=a"b`,
+		"nodesep=-1",
+		"algo=magic",
+	} {
+		name, value, _ := strings.Cut(param, "=")
+		a := paramAnalysis(t)
+		err := a.OverrideByParams(url.Values{name: {value}})
+		if err == nil {
+			t.Errorf("OverrideByParams accepted %s", param)
+		} else if name != "algo" && !strings.Contains(err.Error(), "invalid param "+name) {
+			t.Errorf("OverrideByParams(%s) = %v, want an error naming the param", param, err)
+		}
+	}
+}
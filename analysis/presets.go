@@ -0,0 +1,104 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// presets maps a preset name to package path prefixes that are ignored
+// when the preset is selected. The curated list can be extended or
+// overridden with LoadPresets.
+var presets = map[string][]string{
+	"logging": {
+		"log",
+		"github.com/charmbracelet/log",
+		"github.com/go-logr/logr",
+		"github.com/rs/zerolog",
+		"github.com/sirupsen/logrus",
+		"go.uber.org/zap",
+		"k8s.io/klog",
+	},
+	"errors": {
+		"errors",
+		"fmt",
+		"github.com/pkg/errors",
+		"go.uber.org/multierr",
+		"golang.org/x/xerrors",
+	},
+	"metrics": {
+		"expvar",
+		"github.com/DataDog/datadog-go",
+		"github.com/armon/go-metrics",
+		"github.com/prometheus/client_golang",
+		"go.opentelemetry.io/otel/metric",
+	},
+}
+
+// noise is a preset combining all of the other presets.
+const noisePreset = "noise"
+
+func init() {
+	presets[noisePreset] = noisePaths()
+}
+
+// noisePaths returns the sorted package path prefixes of all presets but
+// noise itself.
+func noisePaths() []string {
+	var all []string
+	for name, paths := range presets {
+		if name != noisePreset {
+			all = append(all, paths...)
+		}
+	}
+	sort.Strings(all)
+	return slices.Compact(all)
+}
+
+// LoadPresets reads a JSON object mapping preset names to lists of package
+// path prefixes and merges it over the curated presets. Unless the file
+// defines noise itself, noise then combines the merged presets.
+func LoadPresets(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	custom := make(map[string][]string)
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return fmt.Errorf("invalid preset file %s: %v", path, err)
+	}
+	for name, paths := range custom {
+		presets[name] = paths
+	}
+	if _, ok := custom[noisePreset]; !ok {
+		presets[noisePreset] = noisePaths()
+	}
+	return nil
+}
+
+// PresetNames returns the names of all known presets.
+func PresetNames() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// presetPaths resolves a comma-separated list of preset names into the
+// package path prefixes they ignore.
+func presetPaths(names []string) ([]string, error) {
+	var paths []string
+	for _, name := range names {
+		p, ok := presets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q, valid presets: %s", name, strings.Join(PresetNames(), ", "))
+		}
+		paths = append(paths, p...)
+	}
+	return paths, nil
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// loadPresets loads the presets of the JSON file content, restoring the
+// curated presets after the test.
+func loadPresets(t *testing.T, content string) {
+	t.Helper()
+	saved := maps.Clone(presets)
+	t.Cleanup(func() { presets = saved })
+	path := filepath.Join(t.TempDir(), "presets.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPresets(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPresetsUpdatesNoise(t *testing.T) {
+	loadPresets(t, `{"logging": ["example.com/log"], "tracing": ["example.com/trace"]}`)
+	noise, err := presetPaths([]string{noisePreset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"example.com/log", "example.com/trace", "fmt"} {
+		if !slices.Contains(noise, path) {
+			t.Errorf("noise lacks %s: %v", path, noise)
+		}
+	}
+	if slices.Contains(noise, "go.uber.org/zap") {
+		t.Errorf("noise has the overridden logging preset: %v", noise)
+	}
+}
+
+func TestLoadPresetsKeepsDefinedNoise(t *testing.T) {
+	loadPresets(t, `{"noise": ["example.com/noise"], "tracing": ["example.com/trace"]}`)
+	noise, err := presetPaths([]string{noisePreset})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(noise, []string{"example.com/noise"}) {
+		t.Errorf("noise = %v, want the defined one", noise)
+	}
+}
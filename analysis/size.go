@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// maxHeaviest is the number of heaviest packages named when a graph is too
+// large to render, and maxIgnored the number of them suggested to ignore.
+const (
+	maxHeaviest = 5
+	maxIgnored  = 3
+)
+
+// CheckRenderSize returns a dot.GraphTooLargeError if g, built by
+// BuildGraph, exceeds limits, naming its heaviest packages and suggesting
+// the options reducing it.
+func (a *Analysis) CheckRenderSize(g *dot.DotGraph, limits dot.SizeLimits) error {
+	tooLarge := dot.CheckSize(g, limits)
+	if tooLarge == nil {
+		return nil
+	}
+
+	// the analyzed and focused packages are never suggested to ignore
+	analyzed := make(map[string]bool)
+	mods := make(map[string]bool)
+	for _, pkg := range a.pkgs {
+		if pkg == nil {
+			continue
+		}
+		path := pkg.Pkg.Path()
+		analyzed[path] = true
+		if mod := a.modules[path]; mod != "" {
+			mods[mod] = true
+		}
+	}
+	for _, f := range a.opts.Focus {
+		if pkg, err := a.findFocusPackage(f); err == nil {
+			analyzed[pkg.Path()] = true
+		}
+	}
+
+	counts := make(map[string]int)
+	var std, outside int
+	for _, n := range g.AllNodes() {
+		fns := a.index.Lookup(n.ID)
+		if len(fns) == 0 || fns[0].Pkg == nil {
+			continue // like the summary nodes of MaxNodes
+		}
+		path := fns[0].Pkg.Pkg.Path()
+		counts[path]++
+		if isStdPath(path) {
+			std++
+		}
+		if !mods[a.modules[path]] {
+			outside++
+		}
+	}
+	for _, path := range slices.SortedFunc(maps.Keys(counts), func(x, y string) int {
+		return cmp.Or(cmp.Compare(counts[y], counts[x]), cmp.Compare(x, y))
+	}) {
+		tooLarge.Heaviest = append(tooLarge.Heaviest, dot.PackageSize{Path: path, Nodes: counts[path]})
+	}
+
+	var ignore []string
+	for _, p := range tooLarge.Heaviest {
+		if len(ignore) < maxIgnored && !analyzed[p.Path] && !isStdPath(p.Path) {
+			ignore = append(ignore, p.Path)
+		}
+	}
+	tooLarge.Heaviest = tooLarge.Heaviest[:min(len(tooLarge.Heaviest), maxHeaviest)]
+
+	if std > 0 && !a.opts.NoStd {
+		tooLarge.Suggestions = append(tooLarge.Suggestions, "-nostd")
+	}
+	if len(ignore) > 0 {
+		tooLarge.Suggestions = append(tooLarge.Suggestions, "-ignore="+strings.Join(ignore, ","))
+	}
+	if outside > 0 && len(mods) > 0 && len(a.opts.Limit) == 0 {
+		tooLarge.Suggestions = append(tooLarge.Suggestions, "-limit="+strings.Join(slices.Sorted(maps.Keys(mods)), ","))
+	}
+	if a.opts.MaxNodes == 0 && limits.Nodes > 0 {
+		tooLarge.Suggestions = append(tooLarge.Suggestions, fmt.Sprintf("-maxnodes=%d", limits.Nodes))
+	}
+	return tooLarge
+}
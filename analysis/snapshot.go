@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// snapshotVersion is part of every snapshot key, bump it when the format
+// of snapshot changes.
+const snapshotVersion = "1"
+
+// A snapshot is a rendered graph cached along with the state of the files
+// it was built from, so that rendering it again with the same options
+// skips loading the packages as long as none of the files changed.
+type snapshot struct {
+	// Files maps the analyzed sources, their directories and the files
+	// read by the options to their state, see snapshotState.
+	Files map[string]string
+	Graph *dot.DotGraph
+}
+
+// SnapshotKey hashes what the rendered graph depends on besides the files
+// of the snapshot: the tool and Go versions, the packages, the options,
+// given as the values of all flags, and the build environment.
+func (a *Analysis) SnapshotKey(version, dir string, args, options []string) string {
+	h := sha256.New()
+	fmt.Fprintln(h, snapshotVersion, version, runtime.Version(), dir, args, options)
+	env := a.loadEnv()
+	for _, key := range []string{"GOOS", "GOARCH", "GOFLAGS", "CGO_ENABLED", "GOEXPERIMENT", "GOWORK", "GOROOT", "GOTOOLCHAIN"} {
+		fmt.Fprintln(h, key, getenv(env, key))
+	}
+	fmt.Fprintln(h, packagesDriver(env))
+	for _, name := range slices.Sorted(maps.Keys(a.Overlay)) {
+		fmt.Fprintln(h, name)
+		h.Write(a.Overlay[name])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func snapshotPath(cacheDir, key string) string {
+	return filepath.Join(cacheDir, "snapshot-"+key+".gob")
+}
+
+// LoadSnapshot returns the graph rendered before under key, or nil if
+// there is none, caching is disabled or refreshed, or one of the files of
+// the snapshot changed since. It needs no analysis, only the options.
+func (a *Analysis) LoadSnapshot(key string) *dot.DotGraph {
+	if a.opts.CacheDir == "" || a.opts.Refresh {
+		return nil
+	}
+	f, err := os.Open(snapshotPath(a.opts.CacheDir, key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.LogWarn("%v", err)
+		}
+		return nil
+	}
+	defer f.Close()
+
+	var s snapshot
+	if err := gob.NewDecoder(f).Decode(&s); err != nil {
+		logger.LogWarn("invalid snapshot %s: %v", f.Name(), err)
+		return nil
+	}
+	for name, state := range s.Files {
+		if snapshotState(name) != state {
+			logger.LogDebug("snapshot %s: %s changed", key, name)
+			return nil
+		}
+	}
+	return s.Graph
+}
+
+// SaveSnapshot caches g, built from the analysis, under key with the state
+// of the analyzed sources taken when they were loaded, and the current
+// state of files, like the profiles or themes read by the options.
+func (a *Analysis) SaveSnapshot(key string, g *dot.DotGraph, files []string) error {
+	if a.opts.CacheDir == "" {
+		return nil
+	}
+	s := snapshot{Files: maps.Clone(a.sourceStates), Graph: g}
+	if s.Files == nil {
+		s.Files = make(map[string]string)
+	}
+	for _, name := range files {
+		s.Files[name] = snapshotState(name)
+	}
+
+	if err := os.MkdirAll(a.opts.CacheDir, os.ModePerm); err != nil {
+		return err
+	}
+	// write to a temporary file first, so readers never see partial files
+	f, err := os.CreateTemp(a.opts.CacheDir, "snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := gob.NewEncoder(f).Encode(&s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), snapshotPath(a.opts.CacheDir, key))
+}
+
+// fileStates returns the state of every file, see snapshotState.
+func fileStates(files []string) map[string]string {
+	states := make(map[string]string, len(files))
+	for _, name := range files {
+		states[name] = snapshotState(name)
+	}
+	return states
+}
+
+// snapshotState returns the state of a file, see fileState, or of a
+// directory its Go files, so that writing the output next to the sources
+// keeps the snapshot.
+func snapshotState(name string) string {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return fileState(name)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".go") {
+			names = append(names, e.Name())
+		}
+	}
+	return strings.Join(names, "\n")
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+func TestSnapshot(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub driver is a shell script")
+	}
+	if err := logger.InitializeLogger(logger.ErrorLevel); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	src := "package main\n\nfunc main() { helper() }\n\nfunc helper() {}\n"
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.CacheDir = filepath.Join(dir, "cache")
+	a := NewAnalysis("")
+	if err := a.SetOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	a.Driver = stubDriver(t, dir, file)
+	args := []string{"//stub:target"}
+	key := a.SnapshotKey("test", dir, args, nil)
+	if g := a.LoadSnapshot(key); g != nil {
+		t.Fatal("LoadSnapshot found a snapshot in an empty cache")
+	}
+	if err := a.DoAnalysis(context.Background(), dir, false, args); err != nil {
+		t.Fatal(err)
+	}
+	g, err := a.BuildGraph(context.Background(), 2, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SaveSnapshot(key, g, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a fresh analysis, as of the next run, finds it without loading
+	b := NewAnalysis("")
+	if err := b.SetOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	b.Driver = a.Driver
+	if b.SnapshotKey("test", dir, args, nil) != key {
+		t.Fatal("SnapshotKey differs for the same options")
+	}
+	cached := b.LoadSnapshot(key)
+	if cached == nil {
+		t.Fatal("LoadSnapshot found no snapshot")
+	}
+	if len(cached.Edges) != len(g.Edges) || len(cached.Edges) == 0 {
+		t.Errorf("snapshot has %d edges, want %d", len(cached.Edges), len(g.Edges))
+	}
+
+	// output written next to the sources keeps it
+	if err := os.WriteFile(filepath.Join(dir, "output.svg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b.LoadSnapshot(key) == nil {
+		t.Error("LoadSnapshot found no snapshot after writing the output")
+	}
+	if err := os.WriteFile(file, []byte(src+"\nfunc other() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if b.LoadSnapshot(key) != nil {
+		t.Error("LoadSnapshot returned the snapshot of changed sources")
+	}
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/ssa"
+)
+
+// DoAnalysisSources analyzes packages given as in-memory sources, for
+// environments without the go command like WebAssembly. The files map
+// slash-separated file paths to their contents, with the directory of a
+// file being the import path of its package. Imports of other packages are
+// resolved by the default importer where available, so in the browser the
+// sources must be self-contained. Analyzing is aborted when ctx is done.
+func (a *Analysis) DoAnalysisSources(ctx context.Context, files map[string][]byte) error {
+	fset := token.NewFileSet()
+	imp := &sourceImporter{
+		fset:     fset,
+		files:    make(map[string][]*ast.File),
+		infos:    make(map[string]*types.Info),
+		checked:  make(map[string]*types.Package),
+		checking: make(map[string]bool),
+		fallback: importer.Default(),
+	}
+
+	// parse in a stable order, so positions do not change between runs
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, files[name], parser.SkipObjectResolution)
+		if err != nil {
+			return &LoadError{Patterns: []string{path.Dir(name)}, Err: err}
+		}
+		dir := path.Dir(name)
+		imp.files[dir] = append(imp.files[dir], f)
+	}
+	if len(imp.files) == 0 {
+		return &LoadError{Err: errors.New("no Go source files")}
+	}
+
+	var paths []string
+	for _, p := range slices.Sorted(maps.Keys(imp.files)) {
+		if _, err := imp.Import(p); err != nil {
+			return &LoadError{Patterns: []string{p}, Err: err}
+		}
+		paths = append(paths, p)
+	}
+
+	prog := ssa.NewProgram(fset, 0)
+	created := make(map[*types.Package]bool)
+	var createAll func(pkgs []*types.Package)
+	createAll = func(pkgs []*types.Package) {
+		for _, p := range pkgs {
+			if created[p] {
+				continue
+			}
+			created[p] = true
+			// only the sources have syntax, other packages are external
+			prog.CreatePackage(p, imp.files[p.Path()], imp.infos[p.Path()], true)
+			createAll(p.Imports())
+		}
+	}
+	var pkgs []*ssa.Package
+	for _, p := range paths {
+		createAll([]*types.Package{imp.checked[p]})
+		pkgs = append(pkgs, prog.Package(imp.checked[p]))
+	}
+	prog.Build()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	graph, mainPkg, err := buildCallGraph(a.opts.Algo, prog)
+	if err != nil {
+		return err
+	}
+	graph.DeleteSyntheticNodes()
+
+	a.prog = prog
+	a.pkgs = pkgs
+	a.mainPkg = mainPkg
+	a.callgraph = graph
+	a.index = output.NewGraphIndex(graph, pkgs)
+	return nil
+}
+
+// sourceImporter type-checks the packages of the sources on demand, in the
+// order they import each other.
+type sourceImporter struct {
+	fset     *token.FileSet
+	files    map[string][]*ast.File
+	infos    map[string]*types.Info
+	checked  map[string]*types.Package
+	checking map[string]bool
+	fallback types.Importer
+}
+
+func (imp *sourceImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.checked[path]; ok {
+		return pkg, nil
+	}
+	files, ok := imp.files[path]
+	if !ok {
+		if pkg, err := imp.fallback.Import(path); err == nil {
+			imp.checked[path] = pkg
+			return pkg, nil
+		}
+		return nil, fmt.Errorf("package %s is not among the sources", path)
+	}
+	if imp.checking[path] {
+		return nil, fmt.Errorf("import cycle through %s", path)
+	}
+	imp.checking[path] = true
+	defer delete(imp.checking, path)
+
+	info := &types.Info{
+		Types:        make(map[ast.Expr]types.TypeAndValue),
+		Defs:         make(map[*ast.Ident]types.Object),
+		Uses:         make(map[*ast.Ident]types.Object),
+		Implicits:    make(map[ast.Node]types.Object),
+		Instances:    make(map[*ast.Ident]types.Instance),
+		Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		FileVersions: make(map[*ast.File]string),
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(path, imp.fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+	imp.checked[path] = pkg
+	imp.infos[path] = info
+	return pkg, nil
+}
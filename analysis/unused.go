@@ -0,0 +1,39 @@
+package analysis
+
+import (
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/ssa"
+)
+
+// UnusedExported returns the exported API of the modules of the analyzed
+// packages never called from within them, see output.UnusedExported.
+// Without module information, the analyzed packages and the packages
+// below them stand for their module.
+func (a *Analysis) UnusedExported() []*ssa.Function {
+	mods := make(map[string]bool)
+	var roots []string
+	for _, pkg := range a.pkgs {
+		if pkg == nil {
+			continue
+		}
+		path := pkg.Pkg.Path()
+		if mod := a.modules[path]; mod != "" {
+			mods[mod] = true
+		} else {
+			roots = append(roots, path)
+		}
+	}
+	return output.UnusedExported(a.prog, a.callgraph, func(path string) bool {
+		if mods[a.modules[path]] {
+			return true
+		}
+		for _, root := range roots {
+			if path == root || strings.HasPrefix(path, root+"/") {
+				return true
+			}
+		}
+		return false
+	})
+}
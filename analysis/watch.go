@@ -0,0 +1,132 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// sourceFiles returns the files whose changes invalidate the analysis: the
+// sources of the packages outside the standard library and the module
+// cache, their directories, to notice added and removed files, and the
+// go.mod and go.sum files of their modules.
+func sourceFiles(initial []*packages.Package) []string {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	packages.Visit(initial, nil, func(p *packages.Package) {
+		if p.Module == nil && isStdPath(p.PkgPath) {
+			return
+		}
+		// dependencies, unless replaced by a local directory
+		if p.Module != nil && !p.Module.Main && (p.Module.Replace == nil || p.Module.Replace.Version != "") {
+			return
+		}
+		for _, f := range p.GoFiles {
+			add(f)
+			add(filepath.Dir(f))
+		}
+		if p.Module != nil && p.Module.GoMod != "" {
+			add(p.Module.GoMod)
+			add(strings.TrimSuffix(p.Module.GoMod, ".mod") + ".sum")
+		}
+	})
+	return files
+}
+
+// SourceFiles returns the files watched for changes of the analyzed code.
+func (a *Analysis) SourceFiles() []string {
+	return a.sources
+}
+
+// Reanalyze returns a copy of a analyzing the current state of the sources.
+// Images cached before are no longer served, as they are keyed by the
+// state of the sources.
+func (a *Analysis) Reanalyze(ctx context.Context, dir string, tests bool, args []string) (*Analysis, error) {
+	next := a.Clone()
+	if err := next.DoAnalysis(ctx, dir, tests, args); err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// A SourceWatcher detects changes of files by polling their modification
+// time and size.
+type SourceWatcher struct {
+	state map[string]string
+}
+
+// NewSourceWatcher returns a watcher of files, taking their current state
+// as baseline.
+func NewSourceWatcher(files []string) *SourceWatcher {
+	w := &SourceWatcher{state: make(map[string]string)}
+	w.Watch(files)
+	return w
+}
+
+// Watch replaces the watched files. Files already watched keep their
+// baseline, so changes made meanwhile are not missed.
+func (w *SourceWatcher) Watch(files []string) {
+	state := make(map[string]string, len(files))
+	for _, f := range files {
+		if s, ok := w.state[f]; ok {
+			state[f] = s
+		} else {
+			state[f] = fileState(f)
+		}
+	}
+	w.state = state
+}
+
+// Wait blocks until a watched file changed and then stayed unchanged for
+// interval, so that a burst of writes results in a single change, or until
+// ctx is done, returning its error. The changed state becomes the new
+// baseline.
+func (w *SourceWatcher) Wait(ctx context.Context, interval time.Duration) ([]string, error) {
+	var changed []string
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+		now := w.Changed()
+		changed = append(changed, now...)
+		if len(changed) > 0 && len(now) == 0 {
+			return changed, nil
+		}
+	}
+}
+
+// Changed returns the watched files changed since the baseline, without
+// waiting. The changed state becomes the new baseline.
+func (w *SourceWatcher) Changed() []string {
+	var changed []string
+	for f, s := range w.state {
+		if cur := fileState(f); cur != s {
+			w.state[f] = cur
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+func fileState(name string) string {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d", fi.ModTime().UnixNano(), fi.Size())
+}
@@ -0,0 +1,35 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSourceWatcherWait(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := NewSourceWatcher([]string{file})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if changed, err := w.Wait(ctx, 10*time.Millisecond); err != context.DeadlineExceeded {
+		t.Fatalf("Wait = %v, %v without changes, want the error of the context", changed, err)
+	}
+
+	if err := os.WriteFile(file, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := w.Wait(context.Background(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{file}) {
+		t.Errorf("Wait = %v, want %s", changed, file)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// cmdBatch renders one graph per main package found by the patterns, like
+// the binaries of a monorepo, loading the packages only once.
+const cmdBatch = "batch"
+
+// batchGraph is a graph rendered by batch, listed on the index page.
+type batchGraph struct {
+	Name  string
+	Pkg   string
+	File  string
+	Nodes int
+	Edges int
+}
+
+var batchIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-callvis: {{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<table>
+<tr><th>Binary</th><th>Package</th><th>Nodes</th><th>Edges</th></tr>
+{{- range .Graphs}}
+<tr><td><a href="{{.File}}">{{.Name}}</a></td><td>{{.Pkg}}</td><td class="num">{{.Nodes}}</td><td class="num">{{.Edges}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// runBatch writes the graph of each main package of a, focused on it, and
+// an index.html page linking them into the directory -file.
+func runBatch(ctx context.Context, a *analysis.Analysis, patterns []string) error {
+	mains := a.MainPackages()
+	if len(mains) == 0 {
+		return fmt.Errorf("%w in %s", analysis.ErrNoMainPackages, strings.Join(patterns, " "))
+	}
+	dir := *outputFile
+	if dir == "" {
+		dir = "callvis"
+	}
+	if dir == "-" {
+		return fmt.Errorf("%s writes one file per binary, set -file to a directory", cmdBatch)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	paths := make([]string, len(mains))
+	for i, p := range mains {
+		paths[i] = p.Pkg.Path()
+	}
+	prefix := commonDir(paths)
+
+	var graphs []batchGraph
+	for _, p := range mains {
+		c := a.Clone()
+		if err := c.OverrideByParams(url.Values{"f": {p.Pkg.Path()}}); err != nil {
+			return err
+		}
+		g, err := c.BuildGraph(ctx, c.Minlen, c.PrintOptions)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Pkg.Path(), err)
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(p.Pkg.Path(), prefix), "/")
+		fname := strings.ReplaceAll(name, "/", "-")
+		log.Printf("writing graph of %s", p.Pkg.Path())
+		if err := writeGraph(ctx, c, g, filepath.Join(dir, fname), *outputFormat); err != nil {
+			return fmt.Errorf("%s: %w", p.Pkg.Path(), err)
+		}
+		graphs = append(graphs, batchGraph{
+			Name:  name,
+			Pkg:   p.Pkg.Path(),
+			File:  fname + "." + *outputFormat,
+			Nodes: countNodes(g),
+			Edges: len(g.Edges),
+		})
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return err
+	}
+	err = batchIndex.Execute(f, struct {
+		Title  string
+		Graphs []batchGraph
+	}{strings.Join(patterns, " "), graphs})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("wrote %d graphs to %s", len(graphs), dir)
+	return nil
+}
+
+// commonDir returns the longest import path prefix shared by paths, made
+// of whole elements, excluding the last element of a single path.
+func commonDir(paths []string) string {
+	prefix := path.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for prefix != "." && !strings.HasPrefix(p, prefix+"/") {
+			prefix = path.Dir(prefix)
+		}
+	}
+	if prefix == "." {
+		return ""
+	}
+	return prefix
+}
+
+// countNodes returns the number of nodes of g, in clusters or not.
+func countNodes(g *dot.DotGraph) int {
+	n := 0
+	walkNodes(g, func(*dot.DotNode) { n++ })
+	return n
+}
+
+// walkNodes calls f for each node of g, in clusters or not.
+func walkNodes(g *dot.DotGraph, f func(n *dot.DotNode)) {
+	for _, n := range g.Nodes {
+		f(n)
+	}
+	var walk func(c *dot.DotCluster)
+	walk = func(c *dot.DotCluster) {
+		for _, n := range c.Nodes {
+			f(n)
+		}
+		for _, sub := range c.Clusters {
+			walk(sub)
+		}
+	}
+	if g.Cluster != nil {
+		walk(g.Cluster)
+	}
+}
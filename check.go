@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/policy"
+	"github.com/ofabry/go-callvis/pkg/reach"
+	"github.com/ofabry/go-callvis/pkg/sarif"
+)
+
+// cmdCheck checks the call graph against policy rules instead of
+// rendering it, for use in CI.
+const cmdCheck = "check"
+
+var (
+	rulesFlag = flag.String("rules", "", "YAML file with the call rules verified by go-callvis check (see README).")
+	sarifFlag = flag.String("sarif", "", "Also write the violations found by go-callvis check, the unused exported API and the calls using reflection as SARIF to this file, - for stdout.")
+)
+
+// violationsError reports that the call graph violates the policy.
+type violationsError struct {
+	count int
+}
+
+func (e *violationsError) Error() string {
+	return fmt.Sprintf("%d forbidden calls", e.count)
+}
+
+// loadPolicy reads the rules of -rules, which check requires.
+func loadPolicy() (*policy.Policy, error) {
+	if *rulesFlag == "" {
+		return nil, fmt.Errorf("%s requires -rules", cmdCheck)
+	}
+	return policy.Load(*rulesFlag)
+}
+
+// runCheck prints the calls of the analysis violating p, one per line
+// with the position of the call site, and writes them to the -sarif file
+// along with the unused exported API and the calls using reflection.
+// Only the violations fail the check.
+func runCheck(a *analysis.Analysis, p *policy.Policy) error {
+	violations := p.Check(a.Index().Graph())
+	wd, _ := os.Getwd()
+	if *sarifFlag != "" {
+		sets, err := reach.Lookup([]string{"reflect"})
+		if err != nil {
+			return err
+		}
+		findings := append(
+			sarif.UnusedResults(a.UnusedExported()),
+			sarif.ReflectionResults(a.Index().Graph(), a.Packages(), sets[0].Match)...,
+		)
+		if err := writeSARIF(*sarifFlag, wd, violations, findings); err != nil {
+			return err
+		}
+	}
+	if *sarifFlag == "-" {
+		// keep stdout valid SARIF
+		return violationsResult(violations)
+	}
+	for _, v := range violations {
+		if rel, err := filepath.Rel(wd, v.Pos.Filename); err == nil && filepath.IsLocal(rel) {
+			v.Pos.Filename = rel
+		}
+		fmt.Println(v)
+	}
+	return violationsResult(violations)
+}
+
+// violationsResult returns the error reporting violations, if any.
+func violationsResult(violations []policy.Violation) error {
+	if len(violations) > 0 {
+		return &violationsError{count: len(violations)}
+	}
+	return nil
+}
+
+// writeSARIF writes the violations and the other findings as SARIF to
+// path, or stdout for -, with file paths relative to root.
+func writeSARIF(path, root string, violations []policy.Violation, findings []sarif.Result) error {
+	results := make([]sarif.Result, len(violations))
+	for i, v := range violations {
+		results[i] = sarif.Result{
+			RuleID:  v.Rule,
+			Message: fmt.Sprintf("%s calls %s", v.Caller, v.Callee),
+			Pos:     v.Pos,
+		}
+	}
+	results = append(results, findings...)
+	if path == "-" {
+		return sarif.Write(os.Stdout, version, root, results)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := sarif.Write(f, version, root, results); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// Subcommands splitting the modes of the tool, each taking the flags
+// shared by all commands analyzing a package and its own.
+const (
+	cmdServe  = "serve"
+	cmdExport = "export"
+	cmdStats  = "stats"
+)
+
+var (
+	// serveFlags are the flags of the interactive viewer.
+	serveFlags = []string{"http", "skipbrowser", "watch", "cache-size", "cache-ttl", "pprof", "render-max-nodes", "render-max-edges", "force"}
+	// outputFlags are the flags of the commands writing output files.
+	outputFlags = []string{"file", "format", "graphviz", "render-timeout", "render-max-nodes", "render-max-edges", "force", "postcmd", "upload", "webhook", "webhook-template", "headless"}
+)
+
+// commandFlags are the flags only taken by some subcommands, by subcommand.
+var commandFlags = map[string][]string{
+	cmdServe:  serveFlags,
+	cmdDaemon: slices.Concat(serveFlags, []string{"socket"}),
+	cmdRender: slices.Concat(outputFlags, []string{"import", "socket", "watch", "open"}),
+	cmdExport: {"file", "format", "callhierarchy", "recursion-report", "unused-report", "postcmd", "upload", "webhook", "webhook-template", "headless", "watch"},
+	cmdDiff:   slices.Concat(outputFlags, []string{"summary"}),
+	cmdBatch:  outputFlags,
+	cmdCheck:  {"rules", "sarif"},
+	cmdReach:  slices.Concat(outputFlags, []string{"sinks"}),
+	cmdStats:  {"format", "file"},
+	cmdTUI:    {"file", "format", "graphviz", "render-timeout", "render-max-nodes", "render-max-edges", "force"},
+}
+
+// topLevelFlags are the flags only taken without subcommand.
+var topLevelFlags = []string{"version", "stdio", "stats"}
+
+// commandUsage are the arguments of the subcommands in their usage.
+var commandUsage = map[string]string{
+	cmdServe:  "package",
+	cmdDaemon: "package",
+	cmdRender: "package",
+	cmdExport: "package",
+	cmdDiff:   "BASE..HEAD package",
+	cmdBatch:  "packages...",
+	cmdCheck:  "-rules rules.yaml package",
+	cmdReach:  "[-sinks sets] package",
+	cmdStats:  "package",
+	cmdTUI:    "package",
+}
+
+// useCommandFlags replaces the flags of the command line by those of the
+// subcommand cmd, which must be called once all flags are defined. The
+// flags keep their variables, so the subcommands share the code reading
+// them.
+func useCommandFlags(cmd string) {
+	specific := make(map[string]bool)
+	for _, names := range commandFlags {
+		for _, name := range names {
+			specific[name] = true
+		}
+	}
+	for _, name := range topLevelFlags {
+		specific[name] = true
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !specific[f.Name] || slices.Contains(commandFlags[cmd], f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go-callvis %s [flags] %s\n\nFlags:\n", cmd, commandUsage[cmd])
+		fs.PrintDefaults()
+	}
+	flag.CommandLine = fs
+}
+
+// isClientRender tells if the args of render, parsed by its flags, ask a
+// running daemon to render: render with a package analyzes it, render
+// with viewer params, which no import path can be, asks the daemon.
+// Without args, render prints its usage.
+func isClientRender(args []string) bool {
+	if *importFlag != "" || len(args) == 0 {
+		return false
+	}
+	for _, arg := range args {
+		if !strings.Contains(arg, "=") {
+			return false
+		}
+	}
+	return true
+}
+
+// setCommandDefaults sets the defaults of the flags differing by
+// subcommand, if not set on the command line.
+func setCommandDefaults(cmd string) {
+	switch cmd {
+	case cmdRender:
+		if *outputFile == "" {
+			*outputFile = "callvis"
+		}
+	case cmdExport:
+		if *outputFile == "" {
+			*outputFile = "-"
+		}
+		if !isFlagSet("format") {
+			*outputFormat = "json"
+		}
+		if _, text := dot.LookupRenderer(*outputFormat); !text {
+			fmt.Fprintf(os.Stderr, "%s writes text formats, not %s\n", cmdExport, *outputFormat)
+			os.Exit(2)
+		}
+	case cmdStats:
+		*statsFlag = true
+		if !isFlagSet("format") {
+			*outputFormat = statsText
+		}
+		if !slices.Contains([]string{statsText, statsJSON, statsHTML}, *outputFormat) {
+			fmt.Fprintf(os.Stderr, "%s writes the formats %s, %s or %s, not %s\n", cmdStats, statsText, statsJSON, statsHTML, *outputFormat)
+			os.Exit(2)
+		}
+	}
+}
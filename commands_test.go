@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestIsClientRender(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"./..."}, false},
+		{[]string{"f=mypkg"}, true},
+		{[]string{"f=mypkg", "format=dot"}, true},
+		{[]string{"f=mypkg", "./cmd"}, false},
+	} {
+		if got := isClientRender(tt.args); got != tt.want {
+			t.Errorf("isClientRender(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
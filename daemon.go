@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/hierarchy"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Commands talking to a long-running daemon, which keeps the analysis
+// warm between invocations.
+const (
+	cmdDaemon = "daemon"
+	cmdQuery  = "query"
+	cmdRender = "render"
+)
+
+var socketFlag = flag.String("socket", defaultSocket(), "Unix socket the daemon listens on.")
+
+// defaultSocket returns the socket of the daemon of the current user, in
+// its runtime directory, or else in a private directory of the temporary
+// directory, see socketDir.
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "go-callvis.sock")
+	}
+	if dir := socketDir(); dir != "" {
+		return filepath.Join(dir, "daemon.sock")
+	}
+	return filepath.Join(os.TempDir(), "go-callvis.sock")
+}
+
+// socketDir returns the directory of the default socket in the shared
+// temporary directory, private to the current user, or "" on Windows,
+// whose temporary directory is per user.
+func socketDir() string {
+	if runtime.GOOS == "windows" {
+		return ""
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis-%d", os.Getuid()))
+}
+
+// makePrivateDir creates dir accessible only to the current user, or
+// checks that an existing one is, so that no other user can connect to
+// the socket in it or replace it.
+func makePrivateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() || fi.Mode().Perm()&0077 != 0 {
+		return fmt.Errorf("%s is not a directory private to the user, remove it or set -socket", dir)
+	}
+	return nil
+}
+
+// listenDaemon listens on the unix socket, accessible only to the current
+// user.
+func listenDaemon(socket string) (net.Listener, error) {
+	if dir := socketDir(); dir != "" && filepath.Dir(socket) == dir {
+		if err := makePrivateDir(dir); err != nil {
+			return nil, err
+		}
+	}
+	// a socket left behind by a killed daemon refuses connections
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+	}
+	if fi, err := os.Lstat(socket); err == nil && fi.Mode()&fs.ModeSocket != 0 {
+		os.Remove(socket)
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// serveDaemon serves mux on the unix socket until the daemon is
+// interrupted.
+func serveDaemon(socket string, mux http.Handler) error {
+	l, err := listenDaemon(socket)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		// closing the listener removes the socket
+		srv.Close()
+	}()
+	if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// queryHandler writes the callers or callees of the functions named by the
+// sym param, one per line, or their LSP call hierarchies with format=lsp.
+func queryHandler(current func() *analysis.Analysis) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sym := r.FormValue("sym")
+		if sym == "" {
+			http.Error(w, "missing sym param", http.StatusBadRequest)
+			return
+		}
+
+		index := current().Index()
+		var related func(*ssa.Function) []*ssa.Function
+		switch dir := r.FormValue("dir"); dir {
+		case "", "callees":
+			related = index.Callees
+		case "callers":
+			related = index.Callers
+		default:
+			http.Error(w, fmt.Sprintf("invalid dir %q, must be callers or callees", dir), http.StatusBadRequest)
+			return
+		}
+
+		fns := index.Lookup(sym)
+		if len(fns) == 0 {
+			http.Error(w, fmt.Sprintf("no function %s", sym), http.StatusNotFound)
+			return
+		}
+		if r.FormValue("format") == "lsp" {
+			w.Header().Set("Content-Type", "application/json")
+			hierarchy.Write(w, hierarchy.Of(index.Graph(), fns))
+			return
+		}
+		var names []string
+		for _, fn := range fns {
+			for _, f := range related(fn) {
+				names = append(names, f.String())
+			}
+		}
+		slices.Sort(names)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, name := range slices.Compact(names) {
+			fmt.Fprintln(w, name)
+		}
+	}
+}
+
+// daemonClient returns an HTTP client connecting to the daemon on socket.
+func daemonClient(socket string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// runClient runs the query and render commands against a running daemon,
+// returning the exit code.
+func runClient(cmd string, args []string) int {
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	socket := fs.String("socket", defaultSocket(), "Unix socket the daemon listens on.")
+
+	var path string
+	params := url.Values{}
+	var outFile *string
+	switch cmd {
+	case cmdQuery:
+		callers := fs.Bool("callers", false, "List the callers instead of the callees.")
+		lsp := fs.Bool("lsp", false, "Write the call hierarchies as JSON LSP callHierarchy items, with both callers and callees.")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: go-callvis query [flags] pkg.Func\n\nFlags:\n")
+			fs.PrintDefaults()
+		}
+		fs.Parse(args)
+		if fs.NArg() != 1 {
+			fs.Usage()
+			return 2
+		}
+		path = "/api/query"
+		params.Set("sym", fs.Arg(0))
+		if *callers {
+			params.Set("dir", "callers")
+		}
+		if *lsp {
+			params.Set("format", "lsp")
+		}
+	case cmdRender:
+		outFile = fs.String("file", "-", "Output filename, - writes to stdout.")
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: go-callvis render [flags] [param=value ...]\n\n"+
+				"The params are those of the interactive viewer, e.g. f=pkg or format=dot.\n\nFlags:\n")
+			fs.PrintDefaults()
+		}
+		fs.Parse(args)
+		path = "/"
+		for _, arg := range fs.Args() {
+			k, v, ok := strings.Cut(arg, "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "invalid param %q, must be param=value\n", arg)
+				return 2
+			}
+			params.Add(k, v)
+		}
+	}
+
+	resp, err := daemonClient(*socket).Get("http://daemon" + path + "?" + params.Encode())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no daemon listening on %s, start one with go-callvis daemon: %v\n", *socket, err)
+		return 1
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		fmt.Fprintf(os.Stderr, "%s: %s", resp.Status, msg)
+		return 1
+	}
+
+	if outFile == nil || *outFile == "-" {
+		_, err = io.Copy(os.Stdout, resp.Body)
+	} else {
+		var f *os.File
+		if f, err = os.Create(*outFile); err == nil {
+			_, err = io.Copy(f, resp.Body)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
+}
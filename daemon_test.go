@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestListenDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the socket is in the temporary directory of the user")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	socket := defaultSocket()
+	if filepath.Dir(socket) != socketDir() {
+		t.Fatalf("default socket %s is not in %s", socket, socketDir())
+	}
+
+	l, err := listenDaemon(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for name, want := range map[string]os.FileMode{socketDir(): 0700, socket: 0600} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("%s has mode %v, want %v", name, fi.Mode().Perm(), want)
+		}
+	}
+	if _, err := listenDaemon(socket); err == nil {
+		t.Error("listenDaemon listened on the socket of a running daemon")
+	}
+}
+
+func TestListenDaemonSharedDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the socket is in the temporary directory of the user")
+	}
+	t.Setenv("TMPDIR", t.TempDir())
+	// created by another user before the daemon
+	if err := os.Mkdir(socketDir(), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(socketDir(), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if l, err := listenDaemon(filepath.Join(socketDir(), "daemon.sock")); err == nil {
+		l.Close()
+		t.Error("listenDaemon listened in a directory shared with other users")
+	}
+}
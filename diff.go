@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/gitdiff"
+	"github.com/ofabry/go-callvis/pkg/output"
+)
+
+// cmdDiff renders the delta of the call graph between two git revisions
+// instead of the graph itself, for reviewing large changes.
+const cmdDiff = "diff"
+
+var summaryFlag = flag.String("summary", "", "Write the JSON summary of go-callvis diff to this file instead of stdout.")
+
+// diffSummary is the JSON summary of a delta graph.
+type diffSummary struct {
+	Base string `json:"base"`
+	Head string `json:"head"`
+	*dot.Delta
+}
+
+// runDiff analyzes the packages matching args at both ends of the revision
+// range revs, checking them out into temporary worktrees, and writes the
+// delta graph to -file and its summary to -summary.
+func runDiff(ctx context.Context, revs string, args []string) error {
+	if *outputFile == "-" && *summaryFlag == "" {
+		return fmt.Errorf("%s writes its summary to stdout, set -summary to write the graph there", cmdDiff)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	root, base, head, err := gitdiff.Resolve(ctx, wd, revs)
+	if err != nil {
+		return err
+	}
+	// analyze the same directory of the repository at both revisions
+	if real, err := filepath.EvalSymlinks(wd); err == nil {
+		wd = real
+	}
+	rel, err := filepath.Rel(root, wd)
+	if err != nil {
+		return err
+	}
+
+	baseGraph, _, err := graphAt(ctx, root, base, rel, args)
+	if err != nil {
+		return fmt.Errorf("analyzing %s: %w", base, err)
+	}
+	headGraph, a, err := graphAt(ctx, root, head, rel, args)
+	if err != nil {
+		return fmt.Errorf("analyzing %s: %w", headName(head), err)
+	}
+
+	style := a.PrintOptions["nodestyle"]
+	g, delta := dot.Diff(baseGraph, headGraph, dot.DiffAttrs{
+		AddedNode: dot.DotAttrs{
+			"fillcolor": a.Theme.Nodes[output.ThemeAdded],
+			"color":     a.Theme.Edges[output.ThemeAdded],
+			"penwidth":  "2",
+		},
+		RemovedNode: dot.DotAttrs{
+			"fillcolor": a.Theme.Nodes[output.ThemeRemoved],
+			"color":     a.Theme.Edges[output.ThemeRemoved],
+			"style":     style + ",dashed",
+			"penwidth":  "2",
+		},
+		AddedEdge: dot.DotAttrs{
+			"color":    a.Theme.Edges[output.ThemeAdded],
+			"penwidth": "2",
+		},
+		RemovedEdge: dot.DotAttrs{
+			"color": a.Theme.Edges[output.ThemeRemoved],
+			"style": "dashed",
+		},
+	})
+	log.Printf("%d nodes and %d edges added, %d nodes and %d edges removed",
+		len(delta.AddedNodes), len(delta.AddedEdges), len(delta.RemovedNodes), len(delta.RemovedEdges))
+
+	fname := *outputFile
+	if fname == "" {
+		fname = cmdDiff
+	}
+	if err := writeGraph(ctx, a, g, fname, *outputFormat); err != nil {
+		return err
+	}
+	return writeSummary(*summaryFlag, diffSummary{Base: base, Head: headName(head), Delta: delta})
+}
+
+// graphAt builds the graph of the packages matching args in the directory
+// rel of the repository at root, checked out at rev, or in the working
+// tree if rev is empty.
+func graphAt(ctx context.Context, root, rev, rel string, args []string) (*dot.DotGraph, *analysis.Analysis, error) {
+	dir := root
+	if rev != "" {
+		worktree, remove, err := gitdiff.Checkout(ctx, root, rev)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer func() {
+			if err := remove(); err != nil {
+				log.Printf("removing worktree of %s: %v", rev, err)
+			}
+		}()
+		dir = worktree
+	}
+
+	a, err := newAnalysis(filepath.Join(dir, rel), args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := a.DoAnalysis(ctx, filepath.Join(dir, rel), *testFlag, args); err != nil {
+		return nil, nil, err
+	}
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, a, nil
+}
+
+func headName(head string) string {
+	if head == "" {
+		return "working tree"
+	}
+	return head
+}
+
+// writeSummary writes the summary as JSON to path, or stdout if empty.
+func writeSummary(path string, summary diffSummary) error {
+	if path == "" {
+		return encodeSummary(os.Stdout, summary)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := encodeSummary(f, summary); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func encodeSummary(w io.Writer, summary diffSummary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summary)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// Exit codes of the failures to tell apart, 2 being also used by the flag
+// package for invalid flags.
+const (
+	exitError    = 1
+	exitUsage    = 2
+	exitLoad     = 3
+	exitGraphviz = 4
+	exitRender   = 5
+	exitCheck    = 6
+	exitNoMain   = 7
+	exitFocus    = 8
+)
+
+// A flagError reports invalid flags, or files given by flags.
+type flagError struct {
+	err error
+}
+
+func (e *flagError) Error() string {
+	return e.err.Error()
+}
+
+func (e *flagError) Unwrap() error {
+	return e.err
+}
+
+// exitCode returns the exit code reporting err.
+func exitCode(err error) int {
+	var (
+		usage    *flagError
+		load     *analysis.LoadError
+		focus    *analysis.FocusError
+		missing  *dot.GraphvizMissingError
+		renderer *dot.RenderError
+		tooLarge *dot.GraphTooLargeError
+		check    *violationsError
+	)
+	switch {
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &load):
+		return exitLoad
+	case errors.As(err, &missing):
+		return exitGraphviz
+	case errors.As(err, &renderer), errors.As(err, &tooLarge):
+		return exitRender
+	case errors.As(err, &check):
+		return exitCheck
+	case errors.Is(err, analysis.ErrNoMainPackages):
+		return exitNoMain
+	case errors.As(err, &focus):
+		return exitFocus
+	}
+	return exitError
+}
+
+// httpStatus returns the HTTP status reporting err.
+func httpStatus(err error) int {
+	var (
+		missing  *dot.GraphvizMissingError
+		tooLarge *dot.GraphTooLargeError
+	)
+	switch {
+	case errors.As(err, &missing):
+		return http.StatusNotImplemented
+	case errors.As(err, &tooLarge):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, dot.ErrRenderTimeout):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// fatal logs err, with all errors of the packages if they failed to load,
+// notifies -webhook and exits with its exit code.
+func fatal(err error) {
+	var load *analysis.LoadError
+	if errors.As(err, &load) {
+		for _, e := range load.Errors {
+			fmt.Fprintln(os.Stderr, e)
+		}
+	}
+	logger.LogError("%v", err)
+	notifyWebhook(context.Background(), err)
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var focusFileFlag = flag.String("focus-file", "", "Read the packages to focus and the functions to highlight, one per line, from a file, - for stdin, e.g. the files listed by rg -l.")
+
+// readFocusFile reads the -focus-file list, adding its packages to -focus,
+// replacing the default main package, and its functions to -highlight.
+// Lines naming a file or directory focus on the package in it, so lists of
+// files found by grep work as well; empty lines and # comments are skipped.
+func readFocusFile() error {
+	var r io.Reader
+	if *focusFileFlag == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(*focusFileFlag)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var pkgs, funcs []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if fi, err := os.Stat(line); err == nil {
+			dir, err := filepath.Abs(line)
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				dir = filepath.Dir(dir)
+			}
+			pkgs = append(pkgs, dir)
+		} else if isFuncSymbol(line) {
+			funcs = append(funcs, line)
+		} else {
+			pkgs = append(pkgs, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if len(pkgs) > 0 {
+		if isFlagSet("focus") {
+			pkgs = append([]string{*focusFlag}, pkgs...)
+		}
+		*focusFlag = strings.Join(uniqueLines(pkgs), ",")
+	}
+	if len(funcs) > 0 {
+		if highlight != "" {
+			funcs = append([]string{highlight}, funcs...)
+		}
+		highlight = strings.Join(uniqueLines(funcs), ",")
+	}
+	return nil
+}
+
+// isFuncSymbol tells if sym names a function, like pkg.Func or
+// (*pkg.T).Method, rather than a package.
+func isFuncSymbol(sym string) bool {
+	if strings.HasPrefix(sym, "(") {
+		return true
+	}
+	return strings.Contains(sym[strings.LastIndex(sym, "/")+1:], ".")
+}
+
+// uniqueLines returns lines without repeated lines, in order.
+func uniqueLines(lines []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, l := range lines {
+		if !seen[l] {
+			seen[l] = true
+			unique = append(unique, l)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// cmdFormats lists the values of -format working with this binary.
+const cmdFormats = "formats"
+
+// runFormats prints the output formats: the text formats built in, and the
+// image formats of the renderer which would be used, the embedded Graphviz
+// or the dot program, returning the exit code.
+func runFormats(args []string) int {
+	fs := flag.NewFlagSet(cmdFormats, flag.ExitOnError)
+	graphviz := fs.Bool("graphviz", false, "List the image formats of Graphviz's dot program, as -graphviz renders with it.")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: go-callvis formats [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return 2
+	}
+
+	text := dot.RendererNames()
+	images, useDot, err := dot.ImageFormats(context.Background(), *graphviz)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, name := range text {
+		fmt.Fprintf(w, "%s\ttext\n", name)
+	}
+	for _, name := range images {
+		// the text renderers take precedence over the images of the same name
+		if !slices.Contains(text, name) {
+			fmt.Fprintf(w, "%s\timage, %s\n", name, rendererName(useDot))
+		}
+	}
+	w.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "no image formats: %v\n", err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+// rendererName names the renderer of images, the dot program if useDot.
+func rendererName(useDot bool) string {
+	if useDot {
+		return "dot program"
+	}
+	return "embedded Graphviz"
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+var headlessFlag = flag.Bool("headless", false, "Run unattended, e.g. in CI containers: never open a browser, serve only if -http is set, render without external programs and write reproducible output.")
+
+// setupHeadless applies -headless, failing on the options which need a
+// browser, a server or the dot program. serves tells whether the graph is
+// served unless -file is set, and renders whether it is rendered at all,
+// which the check subcommand and -hierarchy do not.
+func setupHeadless(serves, renders bool) error {
+	if !*headlessFlag {
+		return nil
+	}
+	*skipBrowser = true
+	if *openFlag {
+		return errors.New("-open starts a viewer, which -headless avoids")
+	}
+	if *graphvizFlag {
+		return errors.New("-graphviz runs the dot program, which -headless avoids")
+	}
+	if serves && *outputFile == "" && !isFlagSet("http") {
+		return errors.New("-headless starts no server, set -file to write the graph or -http to serve it")
+	}
+	if _, text := dot.LookupRenderer(*outputFormat); renders && !text && !dot.EmbeddedGraphviz {
+		return fmt.Errorf("this build renders %s images with the dot program, which -headless avoids, use a text format %v", *outputFormat, dot.RendererNames())
+	}
+	return nil
+}
+
+// isFlagSet reports whether the flag name was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
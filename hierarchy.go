@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/hierarchy"
+	"golang.org/x/tools/go/ssa"
+)
+
+var hierarchyFlag = flag.String("callhierarchy", "", "Write the call hierarchies of all functions of the package as JSON LSP callHierarchy items to this file, - for stdout, instead of rendering.")
+
+// writeHierarchy writes the call hierarchies of the functions of the
+// analyzed packages to path, or stdout for -.
+func writeHierarchy(a *analysis.Analysis, path string) error {
+	index := a.Index()
+	seen := make(map[*ssa.Function]bool)
+	var fns []*ssa.Function
+	for _, p := range a.Packages() {
+		if p == nil {
+			continue
+		}
+		for _, n := range index.Funcs(p.Pkg) {
+			if !seen[n.Func] {
+				seen[n.Func] = true
+				fns = append(fns, n.Func)
+			}
+		}
+	}
+	entries := hierarchy.Of(index.Graph(), fns)
+
+	if path == "-" {
+		return hierarchy.Write(os.Stdout, entries)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := hierarchy.Write(f, entries); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
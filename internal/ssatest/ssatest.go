@@ -0,0 +1,91 @@
+// Package ssatest builds programs in SSA form from source for the tests
+// of the packages analyzing them.
+package ssatest
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Package is a package to build, of one file.
+type Package struct {
+	Path string
+	Src  string
+}
+
+// File returns the name of the file of the package at path, like
+// /src/example.com/p/p.go for example.com/p.
+func File(pkgPath string) string {
+	return "/src/" + pkgPath + "/" + path.Base(pkgPath) + ".go"
+}
+
+// Build type-checks the packages and builds them in SSA form, along with
+// the packages they import, as the analysis does. The packages are given
+// in dependency order, each importing the ones before it and the standard
+// library, which is type-checked from source.
+func Build(t testing.TB, pkgs ...Package) []*ssa.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, 0)
+	std := importer.ForCompiler(fset, "source", nil)
+	checked := make(map[string]*types.Package)
+	conf := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		if pkg, ok := checked[path]; ok {
+			return pkg, nil
+		}
+		return std.Import(path)
+	})}
+
+	// the imported packages are created without syntax, only their
+	// members are used
+	created := make(map[*types.Package]bool)
+	var createImports func(pkg *types.Package)
+	createImports = func(pkg *types.Package) {
+		for _, imp := range pkg.Imports() {
+			if !created[imp] {
+				created[imp] = true
+				prog.CreatePackage(imp, nil, nil, true)
+				createImports(imp)
+			}
+		}
+	}
+
+	var built []*ssa.Package
+	for _, p := range pkgs {
+		f, err := parser.ParseFile(fset, File(p.Path), p.Src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Types:        make(map[ast.Expr]types.TypeAndValue),
+			Defs:         make(map[*ast.Ident]types.Object),
+			Uses:         make(map[*ast.Ident]types.Object),
+			Implicits:    make(map[ast.Node]types.Object),
+			Instances:    make(map[*ast.Ident]types.Instance),
+			Selections:   make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:       make(map[ast.Node]*types.Scope),
+			FileVersions: make(map[*ast.File]string),
+		}
+		pkg, err := conf.Check(p.Path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checked[p.Path] = pkg
+		created[pkg] = true
+		createImports(pkg)
+		built = append(built, prog.CreatePackage(pkg, []*ast.File{f}, info, false))
+	}
+	prog.Build()
+	return built
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
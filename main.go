@@ -2,21 +2,35 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"go/build"
+	"io"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ofabry/go-callvis/analysis"
 	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/gitdiff"
 	"github.com/ofabry/go-callvis/pkg/logger"
+	"github.com/ofabry/go-callvis/pkg/output"
+	"github.com/ofabry/go-callvis/pkg/policy"
+	"github.com/ofabry/go-callvis/pkg/reach"
+	"github.com/ofabry/go-callvis/pkg/upload"
 	"github.com/pkg/browser"
 	"golang.org/x/tools/go/buildutil"
 )
@@ -25,9 +39,54 @@ const Usage = `go-callvis: visualize call graph of a Go program.
 
 Usage:
 
+  go-callvis serve [flags] package
+  go-callvis render [flags] package
+  go-callvis render -import graph.json [flags]
+  go-callvis export [flags] package
+  go-callvis stats [-format text|json|html] [flags] package
+  go-callvis tui [flags] package
+  go-callvis check -rules rules.yaml [flags] package
+  go-callvis reach [-sinks sets] [flags] package
+  go-callvis diff [flags] BASE..HEAD package
+  go-callvis batch [flags] packages...
+  go-callvis daemon [flags] package
+  go-callvis query [-socket path] [-callers] pkg.Func
+  go-callvis render [-socket path] [-file path] [param=value ...]
+  go-callvis formats [-graphviz]
   go-callvis [flags] package
 
   Package should be main package, otherwise -tests flag must be used.
+  Run go-callvis <command> -h for the flags of a command.
+
+  Serve runs the interactive viewer, render writes the graph to -file as
+  image or text format and export writes it in a text format, json by
+  default, to stdout or -file. Stats prints the size of the graph instead,
+  or with -format json or html a report of its metrics.
+  Tui browses the callers and callees of the functions in the terminal,
+  exporting the browsed ones to -file.
+
+  The daemon keeps the analysis of the package in memory and listens on a
+  unix socket, so query and render with viewer params answer without
+  analyzing it again.
+
+  Check exits with a non-zero status if calls forbidden by the rules
+  exist, printing them with their call sites.
+
+  Reach prints the security sensitive functions of the -sinks sets the
+  entry points of the package reach, rendering the paths to -file if set.
+
+  Diff renders the calls added and removed between two git revisions,
+  writing the delta graph to -file and a JSON summary to stdout.
+
+  Batch renders one graph per main package matching the patterns, e.g.
+  ./cmd/..., into the directory -file along with an index.html page.
+
+  Formats lists the values of -format this binary renders, the image
+  formats depending on the renderer, the embedded Graphviz or the dot
+  program.
+
+  Without command, all flags are taken and -file selects between the
+  viewer and writing the graph.
 
 Flags:
 `
@@ -54,43 +113,236 @@ func openBrowser(url string) {
 	}
 }
 
-func outputDot(analysis *analysis.Analysis, fname string, outputFormat string) {
-	if e := analysis.ProcessListArgs(); e != nil {
-		log.Fatalf("%v\n", e)
+var openFlag = flag.Bool("open", false, "Open the output file with the default application once written, in file mode.")
+
+// openFile opens the output file with the default application of the
+// system, like openBrowser does for server mode.
+func openFile(name string) {
+	if err := browser.OpenFile(name); err != nil {
+		logger.LogWarn("opening %s: %v", name, err)
 	}
+}
+
+// renderContext returns the context for rendering an image, limited by
+// -render-timeout.
+func renderContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if *renderLimit <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, *renderLimit)
+}
 
-	output, err := analysis.Render(analysis.Minlen, analysis.PrintOptions)
+// checkRenderSize refuses rendering g, built from the analysis a, to an
+// image if it exceeds -render-max-nodes or -render-max-edges, unless
+// -force is set. Without analysis, like for -import, the error names no
+// packages.
+func checkRenderSize(a *analysis.Analysis, g *dot.DotGraph) error {
+	if *forceFlag {
+		return nil
+	}
+	limits := dot.SizeLimits{Nodes: *renderNodes, Edges: *renderEdges}
+	if a != nil {
+		return a.CheckRenderSize(g, limits)
+	}
+	if err := dot.CheckSize(g, limits); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderWith writes the graph in the text format of renderer to w.
+func renderWith(ctx context.Context, a *analysis.Analysis, w io.Writer, renderer dot.Renderer) error {
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return err
+	}
+	return renderer.Render(w, g)
+}
+
+// outputDot writes the graph to fname in the output format, rendering
+// images with Graphviz.
+func outputDot(ctx context.Context, analysis *analysis.Analysis, fname string, outputFormat string) error {
+	renderer, textFormat := dot.LookupRenderer(outputFormat)
+
+	// stream the text output as is, so it can be piped to other tools
+	if fname == "-" {
+		if !textFormat {
+			renderer, _ = dot.LookupRenderer("dot")
+		}
+		return renderWith(ctx, analysis, os.Stdout, renderer)
+	}
+
+	g, err := analysis.BuildGraph(ctx, analysis.Minlen, analysis.PrintOptions)
 	if err != nil {
-		log.Fatalf("%v\n", err)
+		return err
+	}
+	return writeOutput(ctx, analysis, g, fname, outputFormat)
+}
+
+// writeOutput writes g, built from the analysis a if any, to fname in the
+// output format as outputDot does, keeping the DOT file of images.
+func writeOutput(ctx context.Context, a *analysis.Analysis, g *dot.DotGraph, fname string, outputFormat string) error {
+	renderer, textFormat := dot.LookupRenderer(outputFormat)
+	if fname == "-" {
+		if !textFormat {
+			renderer, _ = dot.LookupRenderer("dot")
+		}
+		return renderer.Render(os.Stdout, g)
+	}
+	if textFormat {
+		log.Printf("writing %s output..\n", outputFormat)
+		f, err := os.Create(fmt.Sprintf("%s.%s", fname, outputFormat))
+		if err != nil {
+			return err
+		}
+		err = renderer.Render(f, g)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		return publishOutput(ctx, f.Name(), g)
+	}
+
+	if err := checkRenderSize(a, g); err != nil {
+		return err
 	}
 
 	log.Println("writing dot output..")
 
-	writeErr := os.WriteFile(fmt.Sprintf("%s.gv", fname), output, 0755)
-	if writeErr != nil {
-		log.Fatalf("%v\n", writeErr)
+	gv := fmt.Sprintf("%s.gv", fname)
+	f, err := os.OpenFile(gv, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	err = g.WriteDot(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
 	}
 
 	log.Printf("converting dot to %s..\n", outputFormat)
 
-	_, err = dot.DotToImage(*graphvizFlag, fname, outputFormat, output)
+	output, err := os.ReadFile(gv)
+	if err != nil {
+		return err
+	}
+	renderCtx, cancel := renderContext(ctx)
+	defer cancel()
+	img, err := dot.DotToImage(renderCtx, *graphvizFlag, fname, outputFormat, output)
+	if err != nil {
+		return err
+	}
+	return publishOutput(ctx, img, g)
+}
+
+// importGraph renders a graph exported in the JSON format to fname, in
+// the given format.
+func importGraph(ctx context.Context, graph, fname, format string) error {
+	if fname == "" {
+		return fmt.Errorf("-import needs -file to write the output to")
+	}
+	f, err := os.Open(graph)
+	if err != nil {
+		return err
+	}
+	g, err := dot.ReadJSON(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", graph, err)
+	}
+	return writeGraph(ctx, nil, g, fname, format)
+}
+
+// writeGraph writes g, built from the analysis a if any, to fname in the
+// given format, rendering images with Graphviz.
+func writeGraph(ctx context.Context, a *analysis.Analysis, g *dot.DotGraph, fname, format string) error {
+	var buf bytes.Buffer
+	renderer, textFormat := dot.LookupRenderer(format)
+	if !textFormat {
+		renderer, _ = dot.LookupRenderer("dot")
+	}
+	if err := renderer.Render(&buf, g); err != nil {
+		return err
+	}
+	if fname == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	if textFormat {
+		out := fmt.Sprintf("%s.%s", fname, format)
+		if err := os.WriteFile(out, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		return publishOutput(ctx, out, g)
+	}
+
+	if err := checkRenderSize(a, g); err != nil {
+		return err
+	}
+	log.Printf("converting dot to %s..\n", format)
+	renderCtx, cancel := renderContext(ctx)
+	defer cancel()
+	img, err := dot.DotToImage(renderCtx, *graphvizFlag, fname, format, buf.Bytes())
 	if err != nil {
-		log.Fatalf("%v\n", err)
+		return err
 	}
+	return publishOutput(ctx, img, g)
 }
 
 var (
-	focusFlag    = flag.String("focus", "main", "Focus specific package using name or import path.")
+	focusFlag    = flag.String("focus", "main", "Focus specific packages using name or import path (separated by comma).")
 	groupFlag    = flag.String("group", "pkg", "Grouping functions by packages and/or types [pkg, type] (separated by comma)")
 	limitFlag    = flag.String("limit", "", "Limit package paths to given prefixes (separated by comma)")
+	edgesFlag    = flag.String("edges", "", fmt.Sprintf("Include only given kinds of calls %v (separated by comma)", output.EdgeKinds))
+	unfocusFlag  = flag.String("unfocus", "", "Remove packages with given prefixes and everything only reachable through them (separated by comma)")
 	ignoreFlag   = flag.String("ignore", "", "Ignore package paths containing given prefixes (separated by comma)")
 	includeFlag  = flag.String("include", "", "Include package paths with given prefixes (separated by comma)")
 	nostdFlag    = flag.Bool("nostd", false, "Omit calls to/from packages in standard library.")
 	nointerFlag  = flag.Bool("nointer", false, "Omit calls to unexported functions.")
-	cacheDir     = flag.String("cacheDir", "", "Enable caching to avoid unnecessary re-rendering, you can force rendering by adding 'refresh=true' to the URL query or emptying the cache directory")
-	graphvizFlag = flag.Bool("graphviz", false, "Use Graphviz's dot program to render images.")
-	debugFlag    = flag.Bool("debug", true, "Enable verbose log.")
-	outputFormat = flag.String("format", "svg", "output file format [svg | png | jpg | ...]")
+	exportedFlag = flag.Bool("exportedonly", false, "Show only exported functions, collapsing calls through unexported helpers into transitive edges.")
+	crossFlag    = flag.Bool("crosspkg", false, "Omit calls between functions of the same package, showing only cross-package calls.")
+	minWeight    = flag.Uint("minweight", 0, "Omit edges representing fewer than the given number of call sites.")
+	filterFlag   = flag.String("filter", "", `Show only functions or calls matching an expression, e.g. 'pkg =~ "internal" && !exported' (see README)`)
+	presetFlag   = flag.String("preset", "", fmt.Sprintf("Ignore well-known noise packages using presets (separated by comma) %v", analysis.PresetNames()))
+	presetFile   = flag.String("presetfile", "", "JSON file mapping preset names to package path prefixes, overriding the built-in presets.")
+	maxNodes     = flag.Uint("maxnodes", 0, "Keep only the given number of most important nodes, eliding the rest into one summary node per package.")
+	rankByFlag   = flag.String("rankby", output.RankPageRank, fmt.Sprintf("Metric used to rank nodes for -maxnodes %v", output.RankMetrics))
+	testFlag     = flag.Bool("tests", false, "Include test code.")
+	nohelperFlag = flag.Bool("notesthelpers", false, "Hide test helpers, keeping only test roots (Test*, Benchmark*, Example*, Fuzz*). Requires -tests.")
+	testCluFlag  = flag.Bool("testcluster", false, "Group test code into a dedicated cluster. Requires -tests.")
+	httpFlag     = flag.String("http", ":7878", "HTTP service address.")
+	skipBrowser  = flag.Bool("skipbrowser", false, "Skip opening browser.")
+	outputFile   = flag.String("file", "", "output filename - omit to use server mode, use - to write DOT to stdout")
+	versionFlag  = flag.Bool("version", false, "Show version, build and rendering capabilities, and exit.")
+	algoFlag     = flag.String("algo", string(analysis.CallGraphTypeCha), fmt.Sprintf("The algorithm used to construct the call graph. Possible values inlcude: %q, %q, %q",
+		analysis.CallGraphTypeStatic, analysis.CallGraphTypeCha, analysis.CallGraphTypeRta))
+	cacheDir     = flag.String("cacheDir", "", "Enable caching to avoid unnecessary re-analysis and re-rendering, you can force rendering by adding 'refresh=true' to the URL query or emptying the cache directory")
+	cacheFlag    = flag.Bool("cache", false, "Enable caching in the user cache directory, per module, unless -cacheDir is set.")
+	cacheSize    = flag.Int64("cache-size", 0, "Maximum size of the cached images in MB, evicting the least recently used ones (0 means no limit).")
+	cacheTTL     = flag.Duration("cache-ttl", 0, "Time after which cached images expire, e.g. 24h (0 means never).")
+	watchFlag    = flag.Bool("watch", false, "Analyze again when the sources change, reloading the interactive viewer or writing the -file again.")
+	graphvizFlag = flag.Bool("graphviz", false, "Use Graphviz's dot program to render images, falling back to the embedded Graphviz if it is not installed.")
+	renderLimit  = flag.Duration("render-timeout", 0, "Abort rendering images taking longer than the given duration, e.g. 30s (0 means no timeout).")
+	renderNodes  = flag.Int("render-max-nodes", 2000, "Refuse rendering images of graphs with more nodes, suggesting how to reduce them (0 means no limit).")
+	renderEdges  = flag.Int("render-max-edges", 6000, "Refuse rendering images of graphs with more edges, suggesting how to reduce them (0 means no limit).")
+	forceFlag    = flag.Bool("force", false, "Render images of graphs exceeding -render-max-nodes or -render-max-edges anyway.")
+	logLevel     = flag.String("loglevel", "info", "Log messages from the given level on [debug | info | warn | error]")
+	debugFlag    = flag.Bool("debug", false, "Enable verbose log, same as -loglevel=debug.")
+	quietFlag    = flag.Bool("quiet", false, "Log only errors, same as -loglevel=error.")
+	logFormat    = flag.String("log-format", logger.FormatText, fmt.Sprintf("Format of the log messages [%s | %s]", logger.FormatText, logger.FormatJSON))
+	themeFile    = flag.String("theme", "", "JSON file with colors and fonts overriding the palette.")
+	paletteFlag  = flag.String("palette", "default", fmt.Sprintf("Color palette %v", output.PaletteNames()))
+	outputFormat = flag.String("format", "svg", fmt.Sprintf("output file format, an image format rendered by Graphviz [svg | png | jpg | ...] or a text format %v", dot.RendererNames()))
+	cpuProfile   = flag.String("cpuprofile", "", "Write a CPU profile of the analysis and rendering to the given file.")
+	memProfile   = flag.String("memprofile", "", "Write a memory profile after the analysis and rendering to the given file.")
+	pprofFlag    = flag.Bool("pprof", false, "Serve runtime profiles at /debug/pprof/ in server mode.")
+	postCmd      = flag.String("postcmd", "", "Command run on each rendered output file, getting its path as last argument, e.g. an image optimizer or uploader.")
+	importFlag   = flag.String("import", "", "Render a graph exported with -format=json instead of analyzing a package.")
+	fullLoad     = flag.Bool("fullload", false, "Load all package metadata, like export data and embedded files, instead of only what the analysis needs.")
 )
 
 var (
@@ -99,19 +351,299 @@ var (
 	nodeshape string
 	nodestyle string
 	rankdir   string
+	nodelabel string
+	edgelabel string
+
+	nodefontname     string
+	nodefontsize     string
+	edgefontname     string
+	edgefontsize     string
+	clusterfontname  string
+	clusterfontsize  string
+	clusterlabel     string
+	clusterlabelloc  string
+	clusterlabeljust string
+
+	focuspenwidth string
+	dim           bool
+
+	highlight      string
+	highlightpaths bool
+	tracePath      string
+	samerank       string
+	nodesizeby     string
+	heatby         string
+	heatfile       string
+	profileFile    string
+	execTraceFile  string
+	findingsFile   string
+	otel           bool
+	otelSpans      string
+	recursion      bool
+	granularity    string
+	interfaces     bool
+	interfaceCalls bool
+	unused         bool
+	panicAudit     bool
+	title          string
+	footer         bool
+	bgcolor        string
+	dpi            string
+	size           string
+	ratio          string
+	splines        string
+	concentrate    bool
+	imports        bool
+	expand         string
 )
 
+// footerText describes how the graph was generated: the analyzed
+// packages, the options set on the command line, the commit of the
+// packages loaded in dir and the tool version.
+func footerText(dir string, args []string) string {
+	var opts []string
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "footer" {
+			opts = append(opts, fmt.Sprintf("-%s=%s", f.Name, f.Value))
+		}
+	})
+	commit := "(unknown)"
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = packageDir(dir, args)
+	if out, err := cmd.Output(); err == nil {
+		commit = strings.TrimSpace(string(out))
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("package: %s", strings.Join(args, " ")),
+		fmt.Sprintf("options: %s", strings.Join(opts, " ")),
+		fmt.Sprintf("commit: %s", commit),
+		fmt.Sprintf("go-callvis %s", Version()),
+	}, "\n")
+}
+
+// packageDir returns the directory of the packages matching args loaded
+// in dir: the one of the first pattern naming a local directory, like
+// ../other/..., else dir.
+func packageDir(dir string, args []string) string {
+	for _, arg := range args {
+		if !build.IsLocalImport(arg) && !filepath.IsAbs(arg) {
+			continue
+		}
+		arg = filepath.Clean(strings.TrimSuffix(arg, "..."))
+		if filepath.IsAbs(arg) {
+			return arg
+		}
+		return filepath.Join(dir, arg)
+	}
+	return dir
+}
+
+// selectRenderer picks the renderer of images, the dot program with
+// -graphviz, else the embedded Graphviz, falling back to the other one if
+// it is not available rather than failing on the first render.
+func selectRenderer() error {
+	useDot, err := dot.SelectRenderer(*graphvizFlag)
+	if err != nil {
+		return err
+	}
+	formats, _, err := dot.ImageFormats(context.Background(), useDot)
+	if err == nil && !slices.Contains(formats, *outputFormat) {
+		return &flagError{fmt.Errorf("format %s is not rendered by the %s, see go-callvis formats", *outputFormat, rendererName(useDot))}
+	}
+	switch {
+	case *graphvizFlag && !useDot:
+		logger.LogWarn("dot program not found, rendering images with the embedded Graphviz")
+	case !*graphvizFlag && useDot:
+		logger.LogDebug("this build has no embedded Graphviz, rendering images with the dot program")
+	}
+	*graphvizFlag = useDot
+	return nil
+}
+
+var driverFlag = flag.String("driver", "", "go/packages driver program loading the packages, e.g. the gopackagesdriver of rules_go for Bazel (overrides GOPACKAGESDRIVER).")
+
+var allowErrorsFlag = flag.Bool("allow-errors", false, "Analyze packages with errors, like work in progress, as far as they type-check, showing the functions with errors without their calls.")
+
+var overlayFlag = flag.String("overlay", "", "JSON file replacing source files, like unsaved editor buffers, in the format of go build -overlay.")
+
+// The build environment of the analyzed packages, to analyze the build of
+// another platform.
 var (
-	version = "v0.8.0"
-	commit  = "(unknown)"
+	goosFlag    = flag.String("goos", "", "Analyze the build for another operating system, e.g. windows or js (sets GOOS).")
+	goarchFlag  = flag.String("goarch", "", "Analyze the build for another architecture, e.g. arm64 or wasm (sets GOARCH).")
+	goflagsFlag = flag.String("goflags", "", "Build flags of the go command loading the packages, e.g. -mod=vendor (sets GOFLAGS).")
 )
 
-func Version() string {
-	return fmt.Sprintf("%s built from git %s", version, commit)
+// buildEnv returns the variables of the build environment set by flags.
+func buildEnv() []string {
+	var env []string
+	for _, v := range []struct{ key, value string }{
+		{"GOOS", *goosFlag},
+		{"GOARCH", *goarchFlag},
+		{"GOFLAGS", *goflagsFlag},
+	} {
+		if v.value != "" {
+			env = append(env, v.key+"="+v.value)
+		}
+	}
+	return env
+}
+
+// cachePath returns the cache directory, -cacheDir or with -cache the one
+// of the module in the working directory, or "" if caching is disabled.
+func cachePath() (string, error) {
+	if *cacheDir != "" || !*cacheFlag {
+		return *cacheDir, nil
+	}
+	return analysis.DefaultCacheDir(".")
+}
+
+var gitChangedFlag = flag.String("git-changed", "", "Mark the functions changed in a git revision range, e.g. main..HEAD, and the functions calling them.")
+
+// newAnalysis returns an analysis of the packages matching args in dir,
+// set up by the flags.
+func newAnalysis(dir string, args []string) (*analysis.Analysis, error) {
+	cache, err := cachePath()
+	if err != nil {
+		return nil, err
+	}
+	a := analysis.NewAnalysis(*outputFormat)
+	err = a.SetOptions(analysis.Options{
+		Algo:          analysis.CallGraphType(*algoFlag),
+		CacheDir:      cache,
+		Focus:         analysis.SplitList(*focusFlag),
+		Group:         analysis.SplitList(*groupFlag),
+		Limit:         analysis.SplitList(*limitFlag),
+		Ignore:        analysis.SplitList(*ignoreFlag),
+		Include:       analysis.SplitList(*includeFlag),
+		Unfocus:       analysis.SplitList(*unfocusFlag),
+		Presets:       analysis.SplitList(*presetFlag),
+		Edges:         analysis.SplitList(*edgesFlag),
+		NoStd:         *nostdFlag,
+		NoInter:       *nointerFlag,
+		ExportedOnly:  *exportedFlag,
+		Tests:         *testFlag,
+		NoTestHelpers: *nohelperFlag,
+		TestCluster:   *testCluFlag,
+		CrossPkg:      *crossFlag,
+		MinWeight:     *minWeight,
+		MaxNodes:      *maxNodes,
+		RankBy:        *rankByFlag,
+		Filter:        *filterFlag,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.Minlen = minlen
+	a.FullLoad = *fullLoad
+	a.Driver = *driverFlag
+	a.AllowErrors = *allowErrorsFlag
+	a.Env = buildEnv()
+	if *overlayFlag != "" {
+		if a.Overlay, err = analysis.LoadOverlay(*overlayFlag); err != nil {
+			return nil, err
+		}
+	}
+	if cache != "" {
+		a.Images = analysis.NewImageCache(cache, *cacheSize<<20, *cacheTTL)
+	}
+	a.PrintOptions = map[string]string{
+		"minlen":    fmt.Sprint(minlen),
+		"nodesep":   fmt.Sprint(nodesep),
+		"nodeshape": fmt.Sprint(nodeshape),
+		"nodestyle": fmt.Sprint(nodestyle),
+		"rankdir":   fmt.Sprint(rankdir),
+		"nodelabel": nodelabel,
+		"edgelabel": edgelabel,
+
+		"nodefontname":     nodefontname,
+		"nodefontsize":     nodefontsize,
+		"edgefontname":     edgefontname,
+		"edgefontsize":     edgefontsize,
+		"clusterfontname":  clusterfontname,
+		"clusterfontsize":  clusterfontsize,
+		"clusterlabel":     clusterlabel,
+		"clusterlabelloc":  clusterlabelloc,
+		"clusterlabeljust": clusterlabeljust,
+
+		"focuspenwidth": focuspenwidth,
+		"dim":           fmt.Sprint(dim),
+
+		"highlight":      highlight,
+		"highlightpaths": fmt.Sprint(highlightpaths),
+		"trace":          tracePath,
+		"samerank":       samerank,
+		"nodesizeby":     nodesizeby,
+		"heatby":         heatby,
+		"heatfile":       heatfile,
+		"profile":        profileFile,
+		"exectrace":      execTraceFile,
+		"findings":       findingsFile,
+		"otel":           fmt.Sprint(otel),
+		"otelspans":      otelSpans,
+		"recursion":      fmt.Sprint(recursion),
+		"granularity":    granularity,
+		"interfaces":     fmt.Sprint(interfaces),
+		"interfacecalls": fmt.Sprint(interfaceCalls),
+		"unused":         fmt.Sprint(unused),
+		"panics":         fmt.Sprint(panicAudit),
+		"title":          title,
+		"bgcolor":        bgcolor,
+		"dpi":            dpi,
+		"size":           size,
+		"ratio":          ratio,
+		"splines":        splines,
+		"concentrate":    fmt.Sprint(concentrate),
+		"imports":        fmt.Sprint(imports),
+		"expand":         expand,
+		"reproducible":   fmt.Sprint(*headlessFlag),
+	}
+
+	if footer {
+		a.PrintOptions["footer"] = footerText(dir, args)
+	}
+
+	theme, err := output.PaletteTheme(*paletteFlag)
+	if err != nil {
+		return nil, err
+	}
+	if *themeFile != "" {
+		if theme, err = output.LoadTheme(*themeFile, theme); err != nil {
+			return nil, err
+		}
+	}
+	a.Theme = theme
+
+	if *gitChangedFlag != "" {
+		changes, err := gitdiff.Changed(context.Background(), "", *gitChangedFlag)
+		if err != nil {
+			return nil, err
+		}
+		logger.LogDebug("%d functions changed in %s", changes.Len(), *gitChangedFlag)
+		a.Changed = changes.Contains
+	}
+	return a, nil
 }
 
 // noinspection GoUnhandledErrorResult
 func main() {
+	cmdArgs := os.Args[1:]
+	command := ""
+	if len(cmdArgs) > 0 {
+		switch cmdArgs[0] {
+		case cmdQuery:
+			os.Exit(runClient(cmdQuery, cmdArgs[1:]))
+		case cmdFormats:
+			os.Exit(runFormats(cmdArgs[1:]))
+		case cmdServe, cmdRender, cmdExport, cmdStats, cmdTUI, cmdDaemon, cmdCheck, cmdReach, cmdDiff, cmdBatch:
+			command = cmdArgs[0]
+			cmdArgs = cmdArgs[1:]
+		}
+	}
+	daemon, check, diff, batch := command == cmdDaemon, command == cmdCheck, command == cmdDiff, command == cmdBatch
+	reaches := command == cmdReach
+
 	flag.Var((*buildutil.TagsFlag)(&build.Default.BuildTags), "tags", buildutil.TagsFlagDoc)
 	// Graphviz options
 	flag.UintVar(&minlen, "minlen", 2, "Minimum edge length (for wider output).")
@@ -119,77 +651,384 @@ func main() {
 	flag.StringVar(&nodeshape, "nodeshape", "box", "graph node shape (see graphvis manpage for valid values)")
 	flag.StringVar(&nodestyle, "nodestyle", "filled,rounded", "graph node style (see graphvis manpage for valid values)")
 	flag.StringVar(&rankdir, "rankdir", "LR", "Direction of graph layout [LR | RL | TB | BT]")
+	flag.StringVar(&nodefontname, "nodefontname", "", "Font name of node labels (defaults to the theme font).")
+	flag.StringVar(&nodefontsize, "nodefontsize", "", "Font size of node labels.")
+	flag.StringVar(&edgefontname, "edgefontname", "", "Font name of edge labels.")
+	flag.StringVar(&edgefontsize, "edgefontsize", "", "Font size of edge labels.")
+	flag.StringVar(&clusterfontname, "clusterfontname", "", "Font name of cluster labels (defaults to the theme font).")
+	flag.StringVar(&clusterfontsize, "clusterfontsize", "", "Font size of cluster labels.")
+	flag.StringVar(&clusterlabel, "clusterlabel", "", `Cluster label format [name | path | base | relative] or a template, e.g. "{{.Module}}\n{{.Relative}}" (fields: Name, Path, Base, Relative, Module)`)
+	flag.StringVar(&clusterlabelloc, "clusterlabelloc", "", "Vertical position of cluster labels [t | b]")
+	flag.StringVar(&clusterlabeljust, "clusterlabeljust", "", "Justification of cluster labels [l | c | r]")
+	flag.StringVar(&focuspenwidth, "focuspenwidth", "2", "Border width of the focused package clusters.")
+	flag.BoolVar(&dim, "dim", false, "Dim nodes outside the focused packages.")
+	flag.StringVar(&highlight, "highlight", "", "Highlight functions by name, e.g. pkg.Func or (*pkg.T).Method (separated by comma)")
+	flag.BoolVar(&highlightpaths, "highlightpaths", false, "Also highlight all call paths leading to the -highlight functions.")
+	flag.StringVar(&tracePath, "trace", "", "Color every call path from the entry points to a function, e.g. pkg.Func, fading the other calls.")
+	flag.StringVar(&samerank, "samerank", output.SameRankNone, fmt.Sprintf("Place related nodes on the same rank [%s | %s | %s]", output.SameRankNone, output.SameRankExported, output.SameRankEntry))
+	flag.StringVar(&nodesizeby, "nodesize-by", "", fmt.Sprintf("Scale nodes by a metric [%s]", strings.Join(output.SizeMetrics, " ")))
+	flag.StringVar(&heatby, "heat-by", "", fmt.Sprintf("Color nodes along a gradient by a metric [%s]", strings.Join(output.HeatMetrics, " ")))
+	flag.StringVar(&heatfile, "heatfile", "", "Coverage profile (go test -coverprofile) or CPU profile (pprof) used by -heat-by coverage or samples.")
+	flag.StringVar(&profileFile, "profile", "", "CPU profile (pprof) overlaid on the graph, coloring and scaling nodes and edges by their samples.")
+	flag.StringVar(&execTraceFile, "exectrace", "", "Execution trace (go tool trace) overlaid on the graph, highlighting the calls exercised at runtime with their counts.")
+	flag.StringVar(&findingsFile, "findings", "", "JSON report of go vet -json or staticcheck -f json, badging the functions with findings.")
+	flag.BoolVar(&otel, "otel", false, "Outline the functions starting OpenTelemetry spans and those running outside of all spans.")
+	flag.StringVar(&otelSpans, "otel-spans", "", "YAML file mapping span names to the functions starting them, for -otel (implies -otel).")
+	flag.BoolVar(&recursion, "recursion", false, "Badge the directly and mutually recursive functions and color the calls between them.")
+	flag.BoolVar(&panicAudit, "panics", false, "Badge the functions calling panic and recover, and color the call paths from exported functions to panics not recovered on the way.")
+	flag.BoolVar(&unused, "unused", false, "Dim the exported functions and methods never called within the module, nor by its tests with -tests.")
+	flag.StringVar(&bgcolor, "bgcolor", "", "Background color of the graph, e.g. white or transparent (defaults to the theme background).")
+	flag.StringVar(&dpi, "dpi", "", "Resolution of raster output in dots per inch, e.g. 150.")
+	flag.StringVar(&size, "size", "", `Maximum drawing size in inches, e.g. "11,8.5" (append ! to scale up to it).`)
+	flag.StringVar(&ratio, "ratio", "", "Aspect ratio of the drawing [fill | compress | expand | auto] or a number.")
+	flag.StringVar(&splines, "splines", "", fmt.Sprintf("Routing of edges [%s | %s | %s | %s]", output.SplinesSpline, output.SplinesOrtho, output.SplinesPolyline, output.SplinesCurved))
+	flag.BoolVar(&concentrate, "concentrate", false, "Merge parallel edges into shared paths.")
+	flag.BoolVar(&imports, "imports", false, "Show the import graph of the packages, each collapsed into one node, with the call graph of the -expand packages nested in it.")
+	flag.StringVar(&expand, "expand", "", "Packages shown with their call graph by -imports, by import path (separated by comma).")
+	flag.StringVar(&granularity, "granularity", output.GranularityFunc, fmt.Sprintf("Nodes of the graph, one per function, per package or per receiver type of methods, with the calls between them counted [%s]", strings.Join(output.Granularities, " | ")))
+	flag.BoolVar(&interfaces, "interfaces", false, "Show the interfaces of the packages with their methods and the concrete types implementing them instead of the call graph.")
+	flag.BoolVar(&interfaceCalls, "interface-calls", false, "Add to -interfaces the dynamic calls through each interface to the types they reach, labeled with the methods (implies -interfaces).")
+	flag.StringVar(&title, "title", "", "Title shown at the top of the graph.")
+	flag.BoolVar(&footer, "footer", false, "Add a footer with the analyzed package, options, commit, timestamp and tool version.")
+	flag.StringVar(&edgelabel, "edgelabel", output.EdgeLabelNone, fmt.Sprintf("Label edges with call-site locations [%s | %s | %s]", output.EdgeLabelNone, output.EdgeLabelFirst, output.EdgeLabelAll))
+	flag.StringVar(&nodelabel, "nodelabel", "", `Template for node labels, e.g. "{{.Pkg}}\n{{.Func}} ({{.Line}})" (fields: Pkg, PkgPath, Func, Name, Qualified, Signature, File, Line, FanIn, FanOut)`)
 
-	flag.Parse()
-
-	testFlag := flag.Bool("tests", false, "Include test code.")
-	httpFlag := flag.String("http", ":7878", "HTTP service address.")
-	skipBrowser := flag.Bool("skipbrowser", false, "Skip opening browser.")
-	outputFile := flag.String("file", "", "output filename - omit to use server mode")
-	callgraphAlgo := flag.String("algo", "cha", fmt.Sprintf("The algorithm used to construct the call graph. Possible values inlcude: %q, %q, %q",
-		analysis.CallGraphTypeStatic, analysis.CallGraphTypeCha, analysis.CallGraphTypeRta))
-
-	versionFlag := flag.Bool("version", false, "Show version and exit.")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, Usage)
+		flag.PrintDefaults()
+	}
+	if command != "" {
+		useCommandFlags(command)
+	}
+	flag.CommandLine.Parse(cmdArgs)
+	if command == cmdRender && isClientRender(flag.Args()) {
+		os.Exit(runClient(cmdRender, cmdArgs))
+	}
 
 	if *versionFlag {
-		fmt.Fprintln(os.Stderr, Version())
+		printVersion(os.Stderr)
 		os.Exit(0)
 	}
+	if err := applyView(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	setCommandDefaults(command)
+	l, err := logger.ParseLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 	if *debugFlag {
-		log.SetFlags(log.Lmicroseconds)
+		l = logger.DebugLevel
 	}
+	if *quietFlag {
+		l = logger.ErrorLevel
+	}
+	logger.InitializeLogger(l)
+	if err := logger.SetFormat(*logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	logger.RedirectStdLog()
 
-	if flag.NArg() != 1 {
-		fmt.Fprint(os.Stderr, Usage)
-		flag.PrintDefaults()
+	if *stdioFlag {
+		// stdout carries the responses, logs go to stderr
+		if err := serveStdio(context.Background(), os.Stdin, os.Stdout); err != nil {
+			logger.LogFatal(err.Error())
+		}
+		return
+	}
+
+	if *uploadFlag != "" {
+		t, err := upload.Parse(*uploadFlag)
+		if err != nil {
+			fatal(&flagError{err})
+		}
+		uploadTarget = t
+	}
+	if err := loadWebhookTemplate(); err != nil {
+		fatal(&flagError{err})
+	}
+
+	tui := command == cmdTUI
+	renders := !check && !(reaches && *outputFile == "") && *hierarchyFlag == "" && *recursionReport == "" && *unusedReport == "" && !*statsFlag
+	if err := setupHeadless(renders && !daemon && !diff && !batch && !tui && !reaches, renders); err != nil {
+		fatal(&flagError{err})
+	}
+	if *openFlag && *outputFile == "-" {
+		fatal(&flagError{errors.New("-open needs an output file, -file - writes to stdout")})
+	}
+	if _, text := dot.LookupRenderer(*outputFormat); renders && !text {
+		if err := selectRenderer(); err != nil {
+			fatal(err)
+		}
+	}
+
+	if *importFlag != "" && flag.NArg() == 0 {
+		if err := importGraph(context.Background(), *importFlag, *outputFile, *outputFormat); err != nil {
+			fatal(err)
+		}
+		notifyWebhook(context.Background(), nil)
+		return
+	}
+
+	wantArgs := 1
+	if diff {
+		// the revision range comes before the package
+		wantArgs = 2
+	}
+	if flag.NArg() != wantArgs && !(batch && flag.NArg() > 0) {
+		flag.CommandLine.Usage()
 		os.Exit(2)
 	}
 
-	l := 0
-	if *debugFlag {
-		l = -4
+	// load the rules before the analysis, to fail early on mistakes
+	var rules *policy.Policy
+	if check {
+		var err error
+		if rules, err = loadPolicy(); err != nil {
+			fatal(&flagError{err})
+		}
+	}
+	var sinks []*reach.Set
+	if reaches {
+		var err error
+		if sinks, err = loadSinks(); err != nil {
+			fatal(&flagError{err})
+		}
+		if *outputFile == "-" {
+			fatal(&flagError{fmt.Errorf("%s writes its report to stdout, set -file to a file to render the paths", cmdReach)})
+		}
+	}
+
+	stopProfiling := startProfiling()
+
+	if *presetFile != "" {
+		if err := analysis.LoadPresets(*presetFile); err != nil {
+			fatal(&flagError{err})
+		}
+	}
+	if *focusFileFlag != "" {
+		if err := readFocusFile(); err != nil {
+			fatal(&flagError{err})
+		}
+	}
+
+	if diff && *remoteFlag != "" {
+		fatal(&flagError{errors.New("-remote is not supported by " + cmdDiff)})
+	}
+	if diff {
+		err := runDiff(context.Background(), flag.Arg(0), flag.Args()[1:])
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		notifyWebhook(context.Background(), nil)
+		return
 	}
-	logger.InitializeLogger(logger.LogLevel(l))
 
 	args := flag.Args()
 	tests := *testFlag
 	httpAddr := *httpFlag
 	urlAddr := parseHTTPAddr(httpAddr)
+	ctx := context.Background()
 
-	a := analysis.NewAnalysis(*outputFile)
-	a.OptsSetup(*cacheDir, *focusFlag, *groupFlag, *ignoreFlag, *includeFlag, *limitFlag, *nointerFlag, false, *nostdFlag, analysis.CallGraphType(*callgraphAlgo))
+	// the analysis of a remote module runs in a temporary module requiring
+	// it, which is only needed to load the packages
+	dir, removeRemote := "", func() error { return nil }
+	if *remoteFlag != "" {
+		if *watchFlag {
+			logger.LogWarn("-watch is ignored with -remote, the module cache does not change")
+			*watchFlag = false
+		}
+		var err error
+		if dir, args, removeRemote, err = remoteModule(ctx, *remoteFlag, args); err != nil {
+			fatal(err)
+		}
+	}
 
-	a.Minlen = minlen
-	a.PrintOptions = map[string]string{
-		"minlen":    fmt.Sprint(minlen),
-		"nodesep":   fmt.Sprint(nodesep),
-		"nodeshape": fmt.Sprint(nodeshape),
-		"nodestyle": fmt.Sprint(nodestyle),
-		"rankdir":   fmt.Sprint(rankdir),
+	a, err := newAnalysis(dir, args)
+	if err != nil {
+		removeRemote()
+		fatal(&flagError{err})
+	}
+
+	key := snapshotKey(a, command, dir, args)
+	if ok, err := renderSnapshot(ctx, a, key); ok {
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		notifyWebhook(ctx, nil)
+		if *openFlag {
+			openFile(fmt.Sprintf("%s.%s", *outputFile, *outputFormat))
+		}
+		return
+	}
+
+	err = a.DoAnalysis(ctx, dir, tests, args)
+	if rerr := removeRemote(); rerr != nil {
+		logger.LogWarn("removing temporary module: %v", rerr)
+	}
+	if err != nil {
+		fatal(err)
 	}
 
-	if err := a.DoAnalysis(analysis.CallGraphType(*callgraphAlgo), "", tests, args); err != nil {
-		logger.LogFatal(err.Error())
+	if batch {
+		err := runBatch(ctx, a, args)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		notifyWebhook(ctx, nil)
+		return
+	}
+	if check {
+		err := runCheck(a, rules)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if reaches {
+		err := runReach(ctx, a, sinks)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *hierarchyFlag != "" {
+		err := writeHierarchy(a, *hierarchyFlag)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *recursionReport != "" {
+		err := writeRecursion(a, *recursionReport)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *unusedReport != "" {
+		err := writeUnused(a, *unusedReport)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if tui {
+		err := runTUI(ctx, a)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *statsFlag {
+		err := runStats(ctx, a)
+		stopProfiling()
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	var current atomic.Pointer[analysis.Analysis]
+	current.Store(a)
+
+	load := current.Load
+	if !*watchFlag {
+		load = freshAnalysis(&current, tests, args)
 	}
 
 	hdl := http.HandlerFunc(handler)
-	wrappedHandler := InjectAnalysisMiddleware(a)(hdl)
+	wrappedHandler := InjectAnalysisMiddleware(load)(hdl)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", wrappedHandler)
+	if a.Images != nil {
+		mux.HandleFunc("/api/cache", cacheHandler(a.Images))
+	}
+	mux.HandleFunc("/api/query", queryHandler(load))
 
-	http.Handle("/", wrappedHandler)
+	if *outputFile == "-" && *postCmd != "" {
+		logger.LogWarn("-postcmd is ignored when writing to stdout, pipe the output instead")
+	}
 
-	if *outputFile == "" {
+	if *outputFile == "" || daemon {
 		*outputFile = "output"
-		if !*skipBrowser {
+		if !*skipBrowser && !daemon {
 			go openBrowser(urlAddr)
 		}
 
-		log.Printf("http serving at %s", urlAddr)
+		if *watchFlag {
+			hub := newReloadHub()
+			mux.Handle("/events", hub)
+			go watchSources(&current, hub, tests, args)
+		}
+
+		if *pprofFlag {
+			handlePprof(mux)
+		}
+
+		// the profiles cover the analysis, use -pprof to profile the server
+		stopProfiling()
 
-		if err := http.ListenAndServe(httpAddr, nil); err != nil {
+		if daemon {
+			log.Printf("daemon listening on %s", *socketFlag)
+			err = serveDaemon(*socketFlag, mux)
+		} else {
+			log.Printf("http serving at %s", urlAddr)
+			err = http.ListenAndServe(httpAddr, mux)
+		}
+		if err != nil {
 			logger.LogFatal(err.Error())
 		}
 	} else {
-		outputDot(a, *outputFile, *outputFormat)
+		if *watchFlag && *outputFile == "-" {
+			logger.LogWarn("-watch is ignored when writing to stdout")
+			*watchFlag = false
+		}
+		if err := renderOutput(ctx, a, key); err != nil {
+			fatal(err)
+		}
+		stopProfiling()
+		notifyWebhook(ctx, nil)
+		if *openFlag {
+			openFile(fmt.Sprintf("%s.%s", *outputFile, *outputFormat))
+		}
+		if *watchFlag {
+			watchOutput(ctx, a, tests, args)
+		}
+	}
+}
+
+// cacheHandler reports the image cache stats as JSON, and purges the cache
+// on DELETE requests.
+func cacheHandler(images *analysis.ImageCache) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodDelete:
+			images.Purge()
+			log.Println("purged image cache")
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(images.Stats()); err != nil {
+			logger.LogError("encoding cache stats: %v", err)
+		}
 	}
 }
 
@@ -198,12 +1037,13 @@ type contextKey string
 
 const analysisKey contextKey = "analysis"
 
-// Middleware to inject MyObject
-func InjectAnalysisMiddleware(obj *analysis.Analysis) func(http.Handler) http.Handler {
+// Middleware to inject the current analysis, which changes when the
+// sources are analyzed again
+func InjectAnalysisMiddleware(current func() *analysis.Analysis) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Add the object to the context
-			ctx := context.WithValue(r.Context(), analysisKey, obj)
+			ctx := context.WithValue(r.Context(), analysisKey, current())
 			// Pass the request with the new context to the next handler
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -232,48 +1072,88 @@ func handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// .. and allow overriding by HTTP params
-	analysis.OverrideByHTTP(r)
+	// .. and allow overriding by HTTP params, without affecting other requests
+	analysis = analysis.Clone()
+	if err := analysis.OverrideByHTTP(r); err != nil {
+		http.Error(w, "invalid parameters: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	var img string
-	if img = analysis.FindCachedImg(); img != "" {
-		log.Println("serving file:", img)
-		http.ServeFile(w, r, img)
+	format := r.Form.Get("format")
+	if format == "" {
+		format = *outputFormat
+	}
+	if renderer, ok := dot.LookupRenderer(format); ok {
+		log.Printf("writing %s output..\n", format)
+		contentType := mime.TypeByExtension("." + format)
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		var buf bytes.Buffer
+		if err := renderWith(r.Context(), analysis, &buf, renderer); err != nil {
+			http.Error(w, err.Error(), httpStatus(err))
+			return
+		}
+		data, err := postProcess(r.Context(), buf.Bytes(), format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Write(data)
 		return
 	}
 
-	// Convert list-style args to []string
-	if e := analysis.ProcessListArgs(); e != nil {
-		http.Error(w, "invalid parameters", http.StatusBadRequest)
+	if img := analysis.FindCachedImg(); img != "" {
+		log.Println("serving file:", img)
+		serveCachedImage(w, r, img)
 		return
 	}
 
-	output, err := analysis.Render(analysis.Minlen, analysis.PrintOptions)
+	g, err := analysis.BuildGraph(r.Context(), analysis.Minlen, analysis.PrintOptions)
+	if err == nil {
+		err = checkRenderSize(analysis, g)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), httpStatus(err))
 		return
 	}
-
-	if r.Form.Get("format") == "dot" {
-		log.Println("writing dot output..")
-		fmt.Fprint(w, string(output))
+	var buf bytes.Buffer
+	if err := g.WriteDot(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	output := buf.Bytes()
 
 	log.Printf("converting dot to %s..\n", *outputFormat)
 
-	img, err = dot.DotToImage(*graphvizFlag, "", *outputFormat, output)
+	ctx, cancel := renderContext(r.Context())
+	defer cancel()
+	// options producing the same graph share its image
+	if img := analysis.FindDotImg(output); img != "" {
+		log.Println("serving file:", img)
+		serveCachedImage(w, r, img)
+		return
+	}
+
+	data, err := dot.RenderImage(ctx, *graphvizFlag, *outputFormat, output)
 	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	if data, err = postProcess(r.Context(), data, *outputFormat); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = analysis.CacheImg(img)
-	if err != nil {
+	if err := analysis.CacheDotImg(output, data); err != nil {
+		http.Error(w, "cache img error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := analysis.CacheImg(data); err != nil {
 		http.Error(w, "cache img error: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Println("serving file:", img)
-	http.ServeFile(w, r, img)
+	serveImage(w, r, data)
 }
@@ -0,0 +1,276 @@
+// Package callvis generates call graphs of Go programs, allowing other
+// tools to embed go-callvis instead of running its binary.
+//
+//	g, err := callvis.Analyze(ctx, callvis.Options{
+//		Packages: []string{"./cmd/server"},
+//		Focus:    []string{"main"},
+//		NoStd:    true,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	return g.RenderDOT(os.Stdout)
+package callvis
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"github.com/ofabry/go-callvis/pkg/output"
+)
+
+// Algorithm is an algorithm constructing the call graph.
+type Algorithm = analysis.CallGraphType
+
+const (
+	Static = analysis.CallGraphTypeStatic
+	CHA    = analysis.CallGraphTypeCha
+	RTA    = analysis.CallGraphTypeRta
+)
+
+// DefaultPrintOptions are the print options of the command line tool
+// applied to every graph, see Options.PrintOptions.
+var DefaultPrintOptions = map[string]string{
+	"minlen":    "2",
+	"nodesep":   "0.35",
+	"nodeshape": "box",
+	"nodestyle": "filled,rounded",
+	"rankdir":   "LR",
+}
+
+// Options configures the analysis of a program and the rendering of its
+// call graph. They correspond to the flags of the command line tool.
+type Options struct {
+	// Packages are the patterns of the packages to analyze, "." if empty.
+	Packages []string
+	// Sources, if set, are analyzed instead of loading Packages with the
+	// go command, see analysis.DoAnalysisSources.
+	Sources map[string][]byte
+	// Dir is the directory the packages are loaded in, the current
+	// directory if empty.
+	Dir string
+	// Tests includes test code.
+	Tests bool
+	// Algo is the algorithm constructing the call graph, CHA if empty.
+	Algo Algorithm
+	// CacheDir caches the call graph between analyses, if set.
+	CacheDir string
+	// FullLoad loads all package metadata instead of only what the
+	// analysis needs.
+	FullLoad bool
+	// Driver is the go/packages driver program loading the packages, like
+	// the gopackagesdriver of rules_go, GOPACKAGESDRIVER if empty.
+	Driver string
+
+	// Focus focuses packages by name or import path.
+	Focus []string
+	// Group groups functions by package and/or type, by package if empty.
+	Group []string
+	// Limit, Ignore and Include select packages by path prefix.
+	Limit   []string
+	Ignore  []string
+	Include []string
+	// Unfocus removes packages and everything only reachable through them.
+	Unfocus []string
+	// Presets ignore well-known noise packages, see analysis.PresetNames.
+	Presets []string
+	// Edges includes only the given kinds of calls, see output.EdgeKinds.
+	Edges []string
+
+	NoStd         bool
+	NoInter       bool
+	ExportedOnly  bool
+	NoTestHelpers bool
+	TestCluster   bool
+	CrossPkg      bool
+	MinWeight     uint
+	MaxNodes      uint
+	// RankBy is the metric ranking nodes for MaxNodes, PageRank if empty.
+	RankBy string
+	// Filter shows only the functions or calls matching a filter
+	// expression, see output.ParseFilter.
+	Filter string
+	// NodeFilters and EdgeFilters are custom filters applied after all
+	// other filters.
+	NodeFilters []output.NodeFilter
+	EdgeFilters []output.EdgeFilter
+	// NodeDecorators and EdgeDecorators are called for each function and
+	// call shown, returning extra attributes like colors, links or labels.
+	NodeDecorators []output.NodeDecorator
+	EdgeDecorators []output.EdgeDecorator
+
+	// Theme sets the colors and fonts, the default palette if nil.
+	Theme *output.Theme
+	// PrintOptions are merged into DefaultPrintOptions, setting the
+	// layout and labels like the corresponding flags, e.g. "rankdir" or
+	// "nodelabel".
+	PrintOptions map[string]string
+	// Graphviz renders images with Graphviz's dot program, or with the
+	// embedded Graphviz if it is not installed.
+	Graphviz bool
+}
+
+// Errors reported by Analyze and the rendering methods, to be told apart
+// with errors.As.
+type (
+	// LoadError reports that the packages could not be loaded or contain
+	// errors.
+	LoadError = analysis.LoadError
+	// RenderError reports that rendering an image failed.
+	RenderError = dot.RenderError
+	// GraphvizMissingError reports that images cannot be rendered: the dot
+	// program is not installed and the build has no embedded Graphviz.
+	GraphvizMissingError = dot.GraphvizMissingError
+)
+
+// A Renderer writes graphs in a text output format, see RegisterRenderer.
+type Renderer = dot.Renderer
+
+// RegisterRenderer makes a renderer available for the output format name,
+// both to Graph.Render and to the -format flag of the command line tool
+// when built with it.
+func RegisterRenderer(name string, r Renderer) {
+	dot.RegisterRenderer(name, r)
+}
+
+// A Graph is the rendered call graph of an analyzed program.
+type Graph struct {
+	dot      *dot.DotGraph
+	graphviz bool
+}
+
+// Analyze analyzes the program and builds its call graph. Loading the
+// packages and building the graph are aborted when ctx is done.
+func Analyze(ctx context.Context, opts Options) (*Graph, error) {
+	logger.InitializeLogger(logger.WarnLevel)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	group := opts.Group
+	if len(group) == 0 {
+		group = []string{"pkg"}
+	}
+	patterns := opts.Packages
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	printOptions := maps.Clone(DefaultPrintOptions)
+	maps.Copy(printOptions, opts.PrintOptions)
+	minlen, err := strconv.ParseUint(printOptions["minlen"], 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minlen print option: %v", err)
+	}
+
+	a := analysis.NewAnalysis("")
+	err = a.SetOptions(analysis.Options{
+		Algo:          opts.Algo,
+		CacheDir:      opts.CacheDir,
+		Focus:         opts.Focus,
+		Group:         group,
+		Limit:         opts.Limit,
+		Ignore:        opts.Ignore,
+		Include:       opts.Include,
+		Unfocus:       opts.Unfocus,
+		Presets:       opts.Presets,
+		Edges:         opts.Edges,
+		NoStd:         opts.NoStd,
+		NoInter:       opts.NoInter,
+		ExportedOnly:  opts.ExportedOnly,
+		Tests:         opts.Tests,
+		NoTestHelpers: opts.NoTestHelpers,
+		TestCluster:   opts.TestCluster,
+		CrossPkg:      opts.CrossPkg,
+		MinWeight:     opts.MinWeight,
+		MaxNodes:      opts.MaxNodes,
+		RankBy:        opts.RankBy,
+		Filter:        opts.Filter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.Minlen = uint(minlen)
+	a.PrintOptions = printOptions
+	a.Theme = opts.Theme
+	a.FullLoad = opts.FullLoad
+	a.Driver = opts.Driver
+	a.NodeFilters = opts.NodeFilters
+	a.EdgeFilters = opts.EdgeFilters
+	a.NodeDecorators = opts.NodeDecorators
+	a.EdgeDecorators = opts.EdgeDecorators
+
+	if opts.Sources != nil {
+		err = a.DoAnalysisSources(ctx, opts.Sources)
+	} else {
+		err = a.DoAnalysis(ctx, opts.Dir, opts.Tests, patterns)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{dot: g, graphviz: opts.Graphviz}, nil
+}
+
+// ReadGraph reads a graph exported in the JSON format, e.g. by
+// Graph.RenderJSON, to render it without analyzing the program again.
+func ReadGraph(r io.Reader, graphviz bool) (*Graph, error) {
+	g, err := dot.ReadJSON(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Graph{dot: g, graphviz: graphviz}, nil
+}
+
+// Dot returns the DOT graph, e.g. to inspect its nodes and edges.
+func (g *Graph) Dot() *dot.DotGraph {
+	return g.dot
+}
+
+// RenderDOT writes the graph in the DOT language to w.
+func (g *Graph) RenderDOT(w io.Writer) error {
+	return g.dot.WriteDot(w)
+}
+
+// RenderJSON writes the clusters, nodes and edges of the graph as JSON to
+// w.
+func (g *Graph) RenderJSON(w io.Writer) error {
+	return g.dot.WriteJSON(w)
+}
+
+// Render writes the graph to w in the text format of the renderer
+// registered as format, like dot, json or mermaid.
+func (g *Graph) Render(w io.Writer, format string) error {
+	r, ok := dot.LookupRenderer(format)
+	if !ok {
+		return fmt.Errorf("no renderer for format %q, registered are %v", format, dot.RendererNames())
+	}
+	return r.Render(w, g.dot)
+}
+
+// RenderImage renders the graph to an image in the given format, like svg
+// or png, and writes it to w. Rendering is aborted when ctx is done.
+func (g *Graph) RenderImage(ctx context.Context, format string, w io.Writer) error {
+	var buf bytes.Buffer
+	if err := g.dot.WriteDot(&buf); err != nil {
+		return err
+	}
+	data, err := dot.RenderImage(ctx, g.graphviz, format, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,157 @@
+package dot
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// DiffAttrs are the attributes marking the nodes and edges of a delta
+// graph which were added or removed.
+type DiffAttrs struct {
+	AddedNode   DotAttrs
+	RemovedNode DotAttrs
+	AddedEdge   DotAttrs
+	RemovedEdge DotAttrs
+}
+
+// A Delta summarizes the differences between two graphs, identifying nodes
+// by ID and edges by the IDs of their ends.
+type Delta struct {
+	AddedNodes     []string    `json:"addedNodes"`
+	RemovedNodes   []string    `json:"removedNodes"`
+	AddedEdges     []DeltaEdge `json:"addedEdges"`
+	RemovedEdges   []DeltaEdge `json:"removedEdges"`
+	UnchangedNodes int         `json:"unchangedNodes"`
+	UnchangedEdges int         `json:"unchangedEdges"`
+}
+
+type DeltaEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff returns the delta graph from base to head: head with its new nodes
+// and edges marked as added, and the nodes and edges of base it lacks put
+// back, in the same clusters, marked as removed. head is modified.
+func Diff(base, head *DotGraph, marks DiffAttrs) (*DotGraph, *Delta) {
+	delta := &Delta{
+		AddedNodes:   []string{},
+		RemovedNodes: []string{},
+		AddedEdges:   []DeltaEdge{},
+		RemovedEdges: []DeltaEdge{},
+	}
+
+	baseNodes := graphNodes(base)
+	headNodes := graphNodes(head)
+	for id, n := range headNodes {
+		if _, ok := baseNodes[id]; ok {
+			delta.UnchangedNodes++
+			continue
+		}
+		maps.Copy(n.node.Attrs, marks.AddedNode)
+		delta.AddedNodes = append(delta.AddedNodes, id)
+	}
+
+	// nodes of the delta graph by ID, to attach the removed edges
+	nodes := make(map[string]*DotNode, len(headNodes))
+	for id, n := range headNodes {
+		nodes[id] = n.node
+	}
+	for id, n := range baseNodes {
+		if _, ok := headNodes[id]; ok {
+			continue
+		}
+		removed := &DotNode{ID: id, Attrs: maps.Clone(n.node.Attrs)}
+		maps.Copy(removed.Attrs, marks.RemovedNode)
+		nodes[id] = removed
+		delta.RemovedNodes = append(delta.RemovedNodes, id)
+		if len(n.clusters) == 0 || head.Cluster == nil {
+			head.Nodes = append(head.Nodes, removed)
+			continue
+		}
+		c := head.Cluster
+		for _, bc := range n.clusters[1:] {
+			sub, ok := c.Clusters[bc.ID]
+			if !ok {
+				sub = NewDotCluster(bc.ID)
+				sub.Attrs = maps.Clone(bc.Attrs)
+				c.Clusters[bc.ID] = sub
+			}
+			c = sub
+		}
+		c.Nodes = append(c.Nodes, removed)
+	}
+
+	baseEdges := make(map[DeltaEdge]*DotEdge, len(base.Edges))
+	for _, e := range base.Edges {
+		baseEdges[DeltaEdge{e.From.ID, e.To.ID}] = e
+	}
+	headEdges := make(map[DeltaEdge]bool, len(head.Edges))
+	for _, e := range head.Edges {
+		key := DeltaEdge{e.From.ID, e.To.ID}
+		if headEdges[key] {
+			continue
+		}
+		headEdges[key] = true
+		if _, ok := baseEdges[key]; ok {
+			delta.UnchangedEdges++
+			continue
+		}
+		maps.Copy(e.Attrs, marks.AddedEdge)
+		delta.AddedEdges = append(delta.AddedEdges, key)
+	}
+	for _, e := range base.Edges {
+		key := DeltaEdge{e.From.ID, e.To.ID}
+		if headEdges[key] {
+			continue
+		}
+		// mark the edge as seen, in case base has parallel edges
+		headEdges[key] = true
+		removed := &DotEdge{From: nodes[key.From], To: nodes[key.To], Attrs: maps.Clone(e.Attrs)}
+		maps.Copy(removed.Attrs, marks.RemovedEdge)
+		head.Edges = append(head.Edges, removed)
+		delta.RemovedEdges = append(delta.RemovedEdges, key)
+	}
+
+	slices.Sort(delta.AddedNodes)
+	slices.Sort(delta.RemovedNodes)
+	compareEdges := func(a, b DeltaEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	}
+	slices.SortFunc(delta.AddedEdges, compareEdges)
+	slices.SortFunc(delta.RemovedEdges, compareEdges)
+	// the removed nodes were added in any order
+	head.Sort()
+	return head, delta
+}
+
+// clusteredNode is a node with the chain of clusters containing it, from
+// the root cluster down.
+type clusteredNode struct {
+	node     *DotNode
+	clusters []*DotCluster
+}
+
+// graphNodes returns the nodes of g by ID, those outside of clusters
+// included.
+func graphNodes(g *DotGraph) map[string]clusteredNode {
+	nodes := make(map[string]clusteredNode)
+	for _, n := range g.Nodes {
+		nodes[n.ID] = clusteredNode{node: n}
+	}
+	var walk func(c *DotCluster, chain []*DotCluster)
+	walk = func(c *DotCluster, chain []*DotCluster) {
+		chain = append(slices.Clip(chain), c)
+		for _, n := range c.Nodes {
+			nodes[n.ID] = clusteredNode{node: n, clusters: chain}
+		}
+		for _, sub := range c.Clusters {
+			walk(sub, chain)
+		}
+	}
+	if g.Cluster != nil {
+		walk(g.Cluster, nil)
+	}
+	return nodes
+}
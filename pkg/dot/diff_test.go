@@ -0,0 +1,90 @@
+package dot
+
+import (
+	"reflect"
+	"testing"
+)
+
+// diffGraph returns a graph of the nodes in cluster pkg of the root
+// cluster, with edges between them given as pairs of IDs.
+func diffGraph(ids []string, edges ...[2]string) *DotGraph {
+	nodes := make(map[string]*DotNode)
+	pkg := NewDotCluster("pkg")
+	pkg.Attrs["label"] = "pkg"
+	for _, id := range ids {
+		nodes[id] = &DotNode{ID: id, Attrs: DotAttrs{"label": id}}
+		pkg.Nodes = append(pkg.Nodes, nodes[id])
+	}
+	root := NewDotCluster("focus")
+	root.Clusters[pkg.ID] = pkg
+	g := &DotGraph{Cluster: root}
+	for _, e := range edges {
+		g.Edges = append(g.Edges, &DotEdge{From: nodes[e[0]], To: nodes[e[1]], Attrs: DotAttrs{}})
+	}
+	return g
+}
+
+func TestDiff(t *testing.T) {
+	base := diffGraph([]string{"a", "b", "c"}, [2]string{"a", "b"}, [2]string{"b", "c"}, [2]string{"b", "c"})
+	head := diffGraph([]string{"a", "b", "d"}, [2]string{"a", "b"}, [2]string{"a", "d"})
+	marks := DiffAttrs{
+		AddedNode:   DotAttrs{"color": "green"},
+		RemovedNode: DotAttrs{"color": "red"},
+		AddedEdge:   DotAttrs{"color": "green"},
+		RemovedEdge: DotAttrs{"style": "dashed"},
+	}
+	g, delta := Diff(base, head, marks)
+
+	want := &Delta{
+		AddedNodes:     []string{"d"},
+		RemovedNodes:   []string{"c"},
+		AddedEdges:     []DeltaEdge{{"a", "d"}},
+		RemovedEdges:   []DeltaEdge{{"b", "c"}},
+		UnchangedNodes: 2,
+		UnchangedEdges: 1,
+	}
+	if !reflect.DeepEqual(delta, want) {
+		t.Errorf("delta = %+v, want %+v", delta, want)
+	}
+
+	// the removed node is put back into its cluster
+	pkg := g.Cluster.Clusters["pkg"]
+	attrs := make(map[string]DotAttrs)
+	for _, n := range pkg.Nodes {
+		attrs[n.ID] = n.Attrs
+	}
+	if len(attrs) != 4 || attrs["c"]["color"] != "red" || attrs["d"]["color"] != "green" || attrs["a"]["color"] != "" {
+		t.Errorf("nodes of the delta graph are %v", attrs)
+	}
+	if len(g.Edges) != 3 {
+		t.Fatalf("delta graph has %d edges, want 3", len(g.Edges))
+	}
+	for _, e := range g.Edges {
+		switch e.From.ID + "->" + e.To.ID {
+		case "a->b":
+			if len(e.Attrs) != 0 {
+				t.Errorf("unchanged edge is marked %v", e.Attrs)
+			}
+		case "a->d":
+			if e.Attrs["color"] != "green" {
+				t.Errorf("added edge is marked %v", e.Attrs)
+			}
+		case "b->c":
+			if e.Attrs["style"] != "dashed" || e.To != clusterNode(pkg, "c") {
+				t.Errorf("removed edge is marked %v, to %p", e.Attrs, e.To)
+			}
+		default:
+			t.Errorf("unexpected edge %s -> %s", e.From.ID, e.To.ID)
+		}
+	}
+}
+
+// clusterNode returns the node of c with the ID.
+func clusterNode(c *DotCluster, id string) *DotNode {
+	for _, n := range c.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
@@ -1,14 +1,20 @@
 package dot
 
 import (
+	"bufio"
 	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"maps"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -33,36 +39,50 @@ const tmplCluster = `{{define "cluster" -}}
 {{- end}}`
 
 const tmplNode = `{{define "edge" -}}
-    {{printf "%q -> %q [ %s ]" .From .To .Attrs}}
+    {{.Statement}}
 {{- end}}`
 
 const tmplEdge = `{{define "node" -}}
-    {{printf "%q [ %s ]" .ID .Attrs}}
+    {{.Statement}}
 {{- end}}`
 
 const tmplGraph = `digraph gocallvis {
-    label="{{.Title}}";
-    labeljust="l";
-    fontname="Arial";
+    label={{printf "%q" .Title}};
+    labeljust="l";{{with .Options.labelloc}}
+    labelloc={{printf "%q" .}};{{end}}
+    fontname={{printf "%q" .Options.fontname}};
     fontsize="14";
-    rankdir="{{.Options.rankdir}}";
-    bgcolor="lightgray";
+    rankdir={{printf "%q" .Options.rankdir}};
+    bgcolor={{printf "%q" .Options.bgcolor}};
     style="solid";
     penwidth="0.5";
     pad="0.0";
-    nodesep="{{.Options.nodesep}}";
+    nodesep={{printf "%q" .Options.nodesep}};{{with .Options.dpi}}
+    dpi={{printf "%q" .}};{{end}}{{with .Options.size}}
+    size={{printf "%q" .}};{{end}}{{with .Options.ratio}}
+    ratio={{printf "%q" .}};{{end}}{{with .Options.splines}}
+    splines={{printf "%q" .}};{{end}}{{if eq .Options.concentrate "true"}}
+    concentrate="true";{{end}}
 
-    node [shape="{{.Options.nodeshape}}" style="{{.Options.nodestyle}}" fillcolor="honeydew" fontname="Verdana" penwidth="1.0" margin="0.05,0.0"];
-    edge [minlen="{{.Options.minlen}}"]
+    node [shape={{printf "%q" .Options.nodeshape}} style={{printf "%q" .Options.nodestyle}} fillcolor={{printf "%q" .Options.nodefillcolor}} fontname={{printf "%q" .Options.nodefontname}}{{with .Options.nodefontsize}} fontsize={{printf "%q" .}}{{end}} penwidth="1.0" margin="0.05,0.0"];
+    edge [minlen={{printf "%q" .Options.minlen}}{{with .Options.edgefontname}} fontname={{printf "%q" .}}{{end}}{{with .Options.edgefontsize}} fontsize={{printf "%q" .}}{{end}}]
 
     {{template "cluster" .Cluster}}
 
     {{- range .Edges}}
     {{template "edge" .}}
     {{- end}}
+
+    {{- range .Ranks}}
+    { rank=same; {{range .}}{{printf "%q; " .ID}}{{end}}}
+    {{- end}}
 }
 `
 
+func init() {
+	RegisterRenderer("dot", RendererFunc(func(w io.Writer, g *DotGraph) error { return g.WriteDot(w) }))
+}
+
 // ==[ type def/func: DotCluster ]===============================================
 type DotCluster struct {
 	ID       string
@@ -93,6 +113,11 @@ func (n *DotNode) String() string {
 	return n.ID
 }
 
+// Statement returns the DOT statement declaring the node.
+func (n *DotNode) Statement() string {
+	return strconv.Quote(n.ID) + " [ " + n.Attrs.String() + " ]"
+}
+
 // ==[ type def/func: DotEdge    ]===============================================
 type DotEdge struct {
 	From  *DotNode
@@ -100,23 +125,59 @@ type DotEdge struct {
 	Attrs DotAttrs
 }
 
+// Statement returns the DOT statement declaring the edge.
+func (e *DotEdge) Statement() string {
+	return strconv.Quote(e.From.ID) + " -> " + strconv.Quote(e.To.ID) + " [ " + e.Attrs.String() + " ]"
+}
+
 // ==[ type def/func: DotAttrs   ]===============================================
 type DotAttrs map[string]string
 
+// List returns the attributes sorted by name, so that equal attributes
+// always print the same.
 func (p DotAttrs) List() []string {
-	l := []string{}
-	for k, v := range p {
-		l = append(l, fmt.Sprintf("%s=%q", k, v))
+	l := make([]string, 0, len(p))
+	for _, k := range slices.Sorted(maps.Keys(p)) {
+		l = append(l, k+"="+strconv.Quote(p[k]))
 	}
 	return l
 }
 
 func (p DotAttrs) String() string {
-	return strings.Join(p.List(), " ")
+	return p.join(" ", "")
 }
 
 func (p DotAttrs) Lines() string {
-	return fmt.Sprintf("%s;", strings.Join(p.List(), ";\n"))
+	return p.join(";\n", ";")
+}
+
+// bufPool holds the buffers attributes are printed to, as printing them
+// is the bulk of writing large graphs.
+var bufPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// join prints the attributes sorted by name, separated by sep.
+func (p DotAttrs) join(sep, end string) string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer bufPool.Put(buf)
+	buf.Reset()
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.Write(strconv.AppendQuote(buf.AvailableBuffer(), p[k]))
+	}
+	buf.WriteString(end)
+	return buf.String()
 }
 
 // ==[ type def/func: DotGraph   ]===============================================
@@ -127,9 +188,44 @@ type DotGraph struct {
 	Cluster *DotCluster
 	Nodes   []*DotNode
 	Edges   []*DotEdge
+	Ranks   [][]*DotNode
 	Options map[string]string
 }
 
+// Sort orders the nodes of g and of its clusters by ID, and its edges by
+// the IDs of their ends, so that equal graphs always print the same.
+func (g *DotGraph) Sort() {
+	byID := func(a, b *DotNode) int { return cmp.Compare(a.ID, b.ID) }
+	slices.SortFunc(g.Nodes, byID)
+	var sortCluster func(c *DotCluster)
+	sortCluster = func(c *DotCluster) {
+		slices.SortFunc(c.Nodes, byID)
+		for _, sub := range c.Clusters {
+			sortCluster(sub)
+		}
+	}
+	if g.Cluster != nil {
+		sortCluster(g.Cluster)
+	}
+	slices.SortFunc(g.Edges, func(a, b *DotEdge) int {
+		if c := cmp.Compare(a.From.ID, b.From.ID); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.To.ID, b.To.ID); c != 0 {
+			return c
+		}
+		// printing the attributes is costly, so only edges between the
+		// same nodes are ordered by them
+		return cmp.Compare(a.Attrs.String(), b.Attrs.String())
+	})
+	for _, rank := range g.Ranks {
+		slices.SortFunc(rank, byID)
+	}
+	slices.SortFunc(g.Ranks, func(a, b []*DotNode) int {
+		return slices.CompareFunc(a, b, byID)
+	})
+}
+
 func (g *DotGraph) WriteDot(w io.Writer) error {
 	t := template.New("dot")
 	for _, s := range []string{tmplCluster, tmplNode, tmplEdge, tmplGraph} {
@@ -137,48 +233,130 @@ func (g *DotGraph) WriteDot(w io.Writer) error {
 			return err
 		}
 	}
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, g); err != nil {
+	bw := bufio.NewWriter(w)
+	if err := t.Execute(bw, g); err != nil {
 		return err
 	}
-	_, err := buf.WriteTo(w)
-	return err
+	return bw.Flush()
 }
 
-func DotToImage(graphvizFlag bool, outfname string, format string, dot []byte) (string, error) {
+// DotToImage renders dot to an image in the given format, writing it to
+// outfname with the format as extension and returning its path. Rendering
+// is aborted when ctx is done.
+func DotToImage(ctx context.Context, graphvizFlag bool, outfname string, format string, dot []byte) (string, error) {
+	data, err := RenderImage(ctx, graphvizFlag, format, dot)
+	if err != nil {
+		return "", err
+	}
+	img := fmt.Sprintf("%s.%s", outfname, format)
+	if err := os.WriteFile(img, data, 0644); err != nil {
+		return "", err
+	}
+	return img, nil
+}
+
+// RenderImage renders dot to an image in the given format in memory, with
+// the renderer chosen by SelectRenderer. Rendering is aborted when ctx is
+// done. Failures are reported as RenderError, or as GraphvizMissingError
+// if no renderer is available.
+func RenderImage(ctx context.Context, graphvizFlag bool, format string, dot []byte) ([]byte, error) {
+	var data []byte
+	var err error
+	if graphvizFlag, err = SelectRenderer(graphvizFlag); err != nil {
+		return nil, err
+	}
 	if graphvizFlag {
-		return runDotToImageCallSystemGraphviz(outfname, format, dot)
+		data, err = runDotToImageCallSystemGraphviz(ctx, format, dot)
+	} else {
+		data, err = runDotToImage(ctx, format, dot)
 	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, &RenderError{Format: format, Err: ErrRenderTimeout}
+	}
+	var missing *GraphvizMissingError
+	if err != nil && !errors.As(err, &missing) {
+		err = &RenderError{Format: format, Err: err}
+	}
+	return data, err
+}
+
+// SelectRenderer tells whether images are rendered with the dot program,
+// given whether it is preferred to the Graphviz embedded in the binary:
+// the preferred renderer if available, else the other one. It fails with
+// GraphvizMissingError if neither is available.
+func SelectRenderer(graphvizFlag bool) (bool, error) {
+	_, err := lookupDot()
+	switch {
+	case err == nil && (graphvizFlag || !EmbeddedGraphviz):
+		return true, nil
+	case EmbeddedGraphviz:
+		return false, nil
+	}
+	return false, err
+}
 
-	return runDotToImage(outfname, format, dot)
+// ImageFormats returns the image formats of the renderer picked by
+// SelectRenderer, sorted, and whether it is the dot program, whose
+// formats are those listed by dot -T?.
+func ImageFormats(ctx context.Context, graphvizFlag bool) ([]string, bool, error) {
+	useDot, err := SelectRenderer(graphvizFlag)
+	if err != nil {
+		return nil, false, err
+	}
+	if !useDot {
+		return slices.Sorted(slices.Values(embeddedFormats)), false, nil
+	}
+	path, _ := lookupDot()
+	// dot lists its formats when asked for an unknown one, failing
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "-T?")
+	cmd.Stdin = strings.NewReader("digraph {}")
+	cmd.Stderr = &stderr
+	cmd.Run()
+	_, list, ok := strings.Cut(stderr.String(), "Use one of:")
+	if !ok {
+		return nil, true, fmt.Errorf("listing the formats of %s: %s", path, strings.TrimSpace(stderr.String()))
+	}
+	formats := strings.Fields(list)
+	slices.Sort(formats)
+	return slices.Compact(formats), true, nil
 }
 
 // location of dot executable for converting from .dot to .svg
 // it's usually at: /usr/bin/dot
-var dotSystemBinary string
+var (
+	dotSystemBinary string
+	dotLookupErr    error
+	dotLookupOnce   sync.Once
+)
 
-// runDotToImageCallSystemGraphviz generates a SVG using the 'dot' utility, returning the filepath
-func runDotToImageCallSystemGraphviz(outfname string, format string, dot []byte) (string, error) {
-	if dotSystemBinary == "" {
-		dot, err := exec.LookPath("dot")
+// lookupDot returns the path of the dot program, looked up once.
+func lookupDot() (string, error) {
+	dotLookupOnce.Do(func() {
+		path, err := exec.LookPath("dot")
 		if err != nil {
-			log.Fatalln("unable to find program 'dot', please install it or check your PATH")
+			dotLookupErr = &GraphvizMissingError{Err: err}
 		}
-		dotSystemBinary = dot
-	}
+		dotSystemBinary = path
+	})
+	return dotSystemBinary, dotLookupErr
+}
 
-	var img string
-	if outfname == "" {
-		img = filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis_export.%s", format))
-	} else {
-		img = fmt.Sprintf("%s.%s", outfname, format)
+// runDotToImageCallSystemGraphviz generates an image using the 'dot' utility
+func runDotToImageCallSystemGraphviz(ctx context.Context, format string, dot []byte) ([]byte, error) {
+	dotSystemBinary, err := lookupDot()
+	if err != nil {
+		return nil, err
 	}
-	cmd := exec.Command(dotSystemBinary, fmt.Sprintf("-T%s", format), "-o", img)
+
+	// the dot process is killed when ctx is done
+	cmd := exec.CommandContext(ctx, dotSystemBinary, fmt.Sprintf("-T%s", format))
 	cmd.Stdin = bytes.NewReader(dot)
-	var stderr bytes.Buffer
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("command '%v': %v\n%v", cmd, err, stderr.String())
+		return nil, fmt.Errorf("command '%v': %v\n%v", cmd, err, stderr.String())
 	}
-	return img, nil
+	return stdout.Bytes(), nil
 }
@@ -4,38 +4,60 @@
 package dot
 
 import (
+	"bytes"
 	"context"
-	"fmt"
-	"log"
-	"os"
-	"path/filepath"
 
 	"github.com/goccy/go-graphviz"
 )
 
-func runDotToImage(outfname string, format string, dot []byte) (string, error) {
-	g, err := graphviz.New(context.Background())
+// EmbeddedGraphviz reports whether images are rendered by the Graphviz
+// library built into the binary, without the dot program.
+const EmbeddedGraphviz = true
+
+// embeddedFormats are the image formats of the embedded Graphviz.
+var embeddedFormats = []string{string(graphviz.SVG), string(graphviz.PNG), string(graphviz.JPG), string(graphviz.XDOT)}
+
+type renderResult struct {
+	data []byte
+	err  error
+}
+
+// runDotToImage renders in the background, so it can give up on the render
+// when ctx is done. The embedded Graphviz does not stop a running layout
+// on ctx, so an abandoned render still runs to completion before its
+// resources are freed.
+func runDotToImage(ctx context.Context, format string, dot []byte) ([]byte, error) {
+	done := make(chan renderResult, 1)
+	go func() {
+		data, err := renderDotToImage(ctx, format, dot)
+		done <- renderResult{data, err}
+	}()
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func renderDotToImage(ctx context.Context, format string, dot []byte) (data []byte, err error) {
+	g, err := graphviz.New(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	graph, err := graphviz.ParseBytes(dot)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer func() {
-		if err := graph.Close(); err != nil {
-			log.Fatal(err)
+		if cerr := graph.Close(); err == nil {
+			err = cerr
 		}
 		g.Close()
 	}()
-	var img string
-	if outfname == "" {
-		img = filepath.Join(os.TempDir(), fmt.Sprintf("go-callvis_export.%s", format))
-	} else {
-		img = fmt.Sprintf("%s.%s", outfname, format)
-	}
-	if err := g.RenderFilename(context.Background(), graph, graphviz.Format(format), img); err != nil {
-		return "", err
+	var buf bytes.Buffer
+	if err := g.Render(ctx, graph, graphviz.Format(format), &buf); err != nil {
+		return nil, err
 	}
-	return img, nil
+	return buf.Bytes(), nil
 }
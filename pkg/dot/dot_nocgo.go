@@ -1,8 +1,19 @@
-//go:build !cgo
+//go:build !cgo && !(js && wasm)
 // +build !cgo
+// +build !js !wasm
 
 package dot
 
-func runDotToImage(outfname string, format string, dot []byte) (string, error) {
-	return runDotToImageCallSystemGraphviz(outfname, format, dot)
+import "context"
+
+// EmbeddedGraphviz reports whether images are rendered by the Graphviz
+// library built into the binary, without the dot program. Builds without
+// cgo render them with the dot program.
+const EmbeddedGraphviz = false
+
+// embeddedFormats are the image formats of the embedded Graphviz, none.
+var embeddedFormats []string
+
+func runDotToImage(ctx context.Context, format string, dot []byte) ([]byte, error) {
+	return runDotToImageCallSystemGraphviz(ctx, format, dot)
 }
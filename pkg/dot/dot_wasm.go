@@ -0,0 +1,21 @@
+//go:build js && wasm
+
+package dot
+
+import (
+	"context"
+	"errors"
+)
+
+// EmbeddedGraphviz reports whether images are rendered by the Graphviz
+// library built into the binary, without the dot program.
+const EmbeddedGraphviz = false
+
+// embeddedFormats are the image formats of the embedded Graphviz, none.
+var embeddedFormats []string
+
+// runDotToImage fails in the browser, which has neither the embedded nor
+// the system Graphviz. Render the DOT output with viz.js instead.
+func runDotToImage(ctx context.Context, format string, dot []byte) ([]byte, error) {
+	return nil, errors.New("rendering images is not supported in WebAssembly, render the DOT output with viz.js instead")
+}
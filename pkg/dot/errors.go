@@ -0,0 +1,39 @@
+package dot
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRenderTimeout is the cause of a RenderError when rendering an image
+// takes too long.
+var ErrRenderTimeout = errors.New("timed out, the graph is probably too large: try -limit, -ignore, -nostd or -maxnodes")
+
+// A RenderError reports that rendering an image failed.
+type RenderError struct {
+	Format string
+	Err    error
+}
+
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("rendering %s: %v", e.Format, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// A GraphvizMissingError reports that the dot program of Graphviz, used
+// for rendering images by builds without the embedded Graphviz, is not
+// installed.
+type GraphvizMissingError struct {
+	Err error
+}
+
+func (e *GraphvizMissingError) Error() string {
+	return "unable to find program 'dot', please install it or check your PATH"
+}
+
+func (e *GraphvizMissingError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,259 @@
+package dot
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+func init() {
+	RegisterRenderer("json", RendererFunc(func(w io.Writer, g *DotGraph) error { return g.WriteJSON(w) }))
+}
+
+// GraphFormatVersion is the version of the JSON graph format written by
+// WriteJSON. It is increased on incompatible changes, ReadJSON reads the
+// versions up to it.
+const GraphFormatVersion = 1
+
+// jsonGraph is the JSON form of a DotGraph, flattening its clusters into
+// lists referring to each other by ID. The root cluster has no parent.
+type jsonGraph struct {
+	Version  int               `json:"version"`
+	Title    string            `json:"title,omitempty"`
+	Minlen   uint              `json:"minlen,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	Clusters []jsonCluster     `json:"clusters"`
+	Nodes    []jsonNode        `json:"nodes"`
+	Edges    []jsonEdge        `json:"edges"`
+	Ranks    [][]string        `json:"ranks,omitempty"`
+}
+
+type jsonCluster struct {
+	ID     string   `json:"id"`
+	Parent string   `json:"parent,omitempty"`
+	Attrs  DotAttrs `json:"attrs,omitempty"`
+}
+
+type jsonNode struct {
+	ID      string   `json:"id"`
+	Cluster string   `json:"cluster,omitempty"`
+	Attrs   DotAttrs `json:"attrs,omitempty"`
+}
+
+type jsonEdge struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Attrs DotAttrs `json:"attrs,omitempty"`
+}
+
+// WriteJSON writes the graph as JSON to w, with its clusters, nodes and
+// edges sorted by ID. ReadJSON reads it back.
+func (g *DotGraph) WriteJSON(w io.Writer) error {
+	jg := jsonGraph{
+		Version:  GraphFormatVersion,
+		Title:    g.Title,
+		Minlen:   g.Minlen,
+		Options:  g.Options,
+		Clusters: []jsonCluster{},
+		Nodes:    []jsonNode{},
+		Edges:    []jsonEdge{},
+	}
+	for _, n := range g.Nodes {
+		jg.Nodes = append(jg.Nodes, jsonNode{ID: n.ID, Attrs: n.Attrs})
+	}
+	if g.Cluster != nil {
+		jg.addCluster(g.Cluster, "")
+	}
+	for _, e := range g.Edges {
+		jg.Edges = append(jg.Edges, jsonEdge{From: e.From.ID, To: e.To.ID, Attrs: e.Attrs})
+	}
+	for _, rank := range g.Ranks {
+		ids := make([]string, len(rank))
+		for i, n := range rank {
+			ids[i] = n.ID
+		}
+		jg.Ranks = append(jg.Ranks, ids)
+	}
+
+	slices.SortFunc(jg.Clusters, func(a, b jsonCluster) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortFunc(jg.Nodes, func(a, b jsonNode) int { return cmp.Compare(a.ID, b.ID) })
+	slices.SortStableFunc(jg.Edges, func(a, b jsonEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jg)
+}
+
+func (jg *jsonGraph) addCluster(c *DotCluster, parent string) {
+	jg.Clusters = append(jg.Clusters, jsonCluster{ID: c.ID, Parent: parent, Attrs: c.Attrs})
+	for _, n := range c.Nodes {
+		jg.Nodes = append(jg.Nodes, jsonNode{ID: n.ID, Cluster: c.ID, Attrs: n.Attrs})
+	}
+	for _, sub := range c.Clusters {
+		jg.addCluster(sub, c.ID)
+	}
+}
+
+// importDefaults are the graph options of the default theme, used for the
+// options missing in graphs fed by other tools.
+var importDefaults = map[string]string{
+	"minlen":        "2",
+	"nodesep":       "0.35",
+	"nodeshape":     "box",
+	"nodestyle":     "filled,rounded",
+	"rankdir":       "LR",
+	"fontname":      "Arial",
+	"bgcolor":       "lightgray",
+	"nodefillcolor": "honeydew",
+	"nodefontname":  "Verdana",
+}
+
+// attrNameRe matches the attribute names which can be written to DOT
+// unquoted.
+var attrNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkAttrs returns an error if attrs has a name which is not a plain
+// DOT identifier. The values are always written quoted.
+func checkAttrs(attrs DotAttrs) error {
+	for _, name := range slices.Sorted(maps.Keys(attrs)) {
+		if !attrNameRe.MatchString(name) {
+			return fmt.Errorf("invalid attribute name %q", name)
+		}
+	}
+	return nil
+}
+
+// ReadJSON reads a graph written by WriteJSON, or by other tools feeding
+// graphs to go-callvis in the same format. Only the graph options written
+// to DOT are read, and their values and the attribute names must be valid.
+func ReadJSON(r io.Reader) (*DotGraph, error) {
+	var jg jsonGraph
+	if err := json.NewDecoder(r).Decode(&jg); err != nil {
+		return nil, fmt.Errorf("reading graph: %v", err)
+	}
+	if jg.Version < 1 || jg.Version > GraphFormatVersion {
+		return nil, fmt.Errorf("unsupported graph format version %d, expected 1 to %d", jg.Version, GraphFormatVersion)
+	}
+
+	g := &DotGraph{
+		Title:   jg.Title,
+		Minlen:  jg.Minlen,
+		Options: make(map[string]string),
+	}
+	maps.Copy(g.Options, importDefaults)
+	// only the graph options written to DOT are taken, validated as they
+	// are written unescaped otherwise
+	for _, name := range slices.Sorted(maps.Keys(jg.Options)) {
+		kind, ok := GraphOptions[name]
+		value := jg.Options[name]
+		if !ok || value == "" {
+			continue
+		}
+		if err := kind(value); err != nil {
+			return nil, fmt.Errorf("invalid option %s=%q: %v", name, value, err)
+		}
+		g.Options[name] = value
+	}
+
+	clusters := make(map[string]*DotCluster, len(jg.Clusters))
+	for _, jc := range jg.Clusters {
+		if _, ok := clusters[jc.ID]; ok {
+			return nil, fmt.Errorf("duplicate cluster %q", jc.ID)
+		}
+		if err := checkAttrs(jc.Attrs); err != nil {
+			return nil, fmt.Errorf("cluster %q: %v", jc.ID, err)
+		}
+		c := NewDotCluster(jc.ID)
+		if jc.Attrs != nil {
+			c.Attrs = jc.Attrs
+		}
+		clusters[jc.ID] = c
+	}
+	for _, jc := range jg.Clusters {
+		if jc.Parent == "" {
+			if g.Cluster != nil {
+				return nil, fmt.Errorf("more than one root cluster: %q and %q", g.Cluster.ID, jc.ID)
+			}
+			g.Cluster = clusters[jc.ID]
+			continue
+		}
+		parent, ok := clusters[jc.Parent]
+		if !ok {
+			return nil, fmt.Errorf("cluster %q has unknown parent %q", jc.ID, jc.Parent)
+		}
+		parent.Clusters[jc.ID] = clusters[jc.ID]
+	}
+	if g.Cluster == nil {
+		// graphs without clusters are drawn in an invisible root cluster
+		g.Cluster = NewDotCluster("focus")
+		g.Cluster.Attrs = DotAttrs{"label": "", "peripheries": "0"}
+	}
+
+	nodes := make(map[string]*DotNode, len(jg.Nodes))
+	for _, jn := range jg.Nodes {
+		if _, ok := nodes[jn.ID]; ok {
+			return nil, fmt.Errorf("duplicate node %q", jn.ID)
+		}
+		if err := checkAttrs(jn.Attrs); err != nil {
+			return nil, fmt.Errorf("node %q: %v", jn.ID, err)
+		}
+		n := &DotNode{ID: jn.ID, Attrs: jn.Attrs}
+		if n.Attrs == nil {
+			n.Attrs = make(DotAttrs)
+		}
+		nodes[jn.ID] = n
+		// nodes outside of clusters are drawn in the root cluster, keeping
+		// their attributes
+		c := g.Cluster
+		if jn.Cluster != "" {
+			var ok bool
+			if c, ok = clusters[jn.Cluster]; !ok {
+				return nil, fmt.Errorf("node %q is in unknown cluster %q", jn.ID, jn.Cluster)
+			}
+		}
+		c.Nodes = append(c.Nodes, n)
+	}
+
+	node := func(id string) (*DotNode, error) {
+		if n, ok := nodes[id]; ok {
+			return n, nil
+		}
+		return nil, fmt.Errorf("unknown node %q", id)
+	}
+	for _, je := range jg.Edges {
+		from, err := node(je.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := node(je.To)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkAttrs(je.Attrs); err != nil {
+			return nil, fmt.Errorf("edge %q -> %q: %v", je.From, je.To, err)
+		}
+		e := &DotEdge{From: from, To: to, Attrs: je.Attrs}
+		if e.Attrs == nil {
+			e.Attrs = make(DotAttrs)
+		}
+		g.Edges = append(g.Edges, e)
+	}
+	for _, ids := range jg.Ranks {
+		rank := make([]*DotNode, 0, len(ids))
+		for _, id := range ids {
+			n, err := node(id)
+			if err != nil {
+				return nil, err
+			}
+			rank = append(rank, n)
+		}
+		g.Ranks = append(g.Ranks, rank)
+	}
+	return g, nil
+}
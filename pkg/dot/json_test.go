@@ -0,0 +1,58 @@
+package dot
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadJSONRejectsHostileGraphs(t *testing.T) {
+	for name, graph := range map[string]string{
+		"option value": `{"version": 1, "options": {"bgcolor": "x\"; node [image=\"/etc/passwd\"]; //"}}`,
+		"font name":    `{"version": 1, "options": {"nodefontname": "Verdana\"]; node [image=\"/etc/passwd\""}}`,
+		"node shape":   `{"version": 1, "options": {"nodeshape": "box\" image=\"/etc/passwd"}}`,
+		"node attr":    `{"version": 1, "nodes": [{"id": "a", "attrs": {"image=\"/etc/passwd\" label": "a"}}]}`,
+		"edge attr": `{"version": 1, "nodes": [{"id": "a"}, {"id": "b"}],
+			"edges": [{"from": "a", "to": "b", "attrs": {"];\nx [image": "/etc/passwd"}}]}`,
+		"cluster attr": `{"version": 1, "clusters": [{"id": "c", "attrs": {"shapefile ": "/etc/passwd"}}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if g, err := ReadJSON(strings.NewReader(graph)); err == nil {
+				var buf bytes.Buffer
+				g.WriteDot(&buf)
+				t.Fatalf("ReadJSON accepted the graph, writing:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestReadJSONTakesGraphOptionsOnly(t *testing.T) {
+	graph := `{"version": 1, "title": "a \"title\"",
+		"options": {"bgcolor": "#ffffff", "nodeshape": "ellipse", "heatfile": "/etc/passwd", "labeljust\"; x": "r"},
+		"nodes": [{"id": "a", "attrs": {"label": "\"quoted\"; image=x"}}]}`
+	g, err := ReadJSON(strings.NewReader(graph))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := g.Options["heatfile"]; ok {
+		t.Errorf("took option heatfile, want only graph options")
+	}
+	var buf bytes.Buffer
+	if err := g.WriteDot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`label="a \"title\""`,
+		`bgcolor="#ffffff"`,
+		`shape="ellipse"`,
+		`label="\"quoted\"; image=x"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "labeljust\"") {
+		t.Errorf("output has the unknown option:\n%s", out)
+	}
+}
@@ -0,0 +1,94 @@
+package dot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+func init() {
+	RegisterRenderer("mermaid", RendererFunc(func(w io.Writer, g *DotGraph) error { return g.WriteMermaid(w) }))
+}
+
+// mermaidDirections maps Graphviz rank directions to Mermaid flowchart
+// directions.
+var mermaidDirections = map[string]string{"LR": "LR", "RL": "RL", "TB": "TB", "BT": "BT"}
+
+// WriteMermaid writes the graph as a Mermaid flowchart to w. Clusters
+// become subgraphs, unless they have no label.
+func (g *DotGraph) WriteMermaid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	m := &mermaidWriter{w: bw, ids: make(map[*DotNode]string)}
+
+	dir := mermaidDirections[g.Options["rankdir"]]
+	if dir == "" {
+		dir = "LR"
+	}
+	if g.Title != "" {
+		fmt.Fprintf(bw, "---\ntitle: %s\n---\n", g.Title)
+	}
+	fmt.Fprintf(bw, "flowchart %s\n", dir)
+
+	for _, n := range g.Nodes {
+		m.node(n, 1)
+	}
+	if g.Cluster != nil {
+		m.cluster(g.Cluster, 1)
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if strings.Contains(e.Attrs["style"], "dashed") || strings.Contains(e.Attrs["style"], "dotted") {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(bw, "    %s %s %s\n", m.id(e.From), arrow, m.id(e.To))
+	}
+	return bw.Flush()
+}
+
+type mermaidWriter struct {
+	w        *bufio.Writer
+	ids      map[*DotNode]string
+	clusters int
+}
+
+// id returns the Mermaid ID of n, as DOT IDs may contain any character.
+func (m *mermaidWriter) id(n *DotNode) string {
+	id, ok := m.ids[n]
+	if !ok {
+		id = fmt.Sprintf("n%d", len(m.ids))
+		m.ids[n] = id
+	}
+	return id
+}
+
+func (m *mermaidWriter) node(n *DotNode, depth int) {
+	label := n.Attrs["label"]
+	if label == "" {
+		label = n.ID
+	}
+	fmt.Fprintf(m.w, "%s%s[\"%s\"]\n", strings.Repeat("    ", depth), m.id(n), mermaidText(label))
+}
+
+func (m *mermaidWriter) cluster(c *DotCluster, depth int) {
+	label := c.Attrs["label"]
+	if label != "" {
+		m.clusters++
+		fmt.Fprintf(m.w, "%ssubgraph c%d[\"%s\"]\n", strings.Repeat("    ", depth), m.clusters, mermaidText(label))
+		depth++
+	}
+	for _, n := range c.Nodes {
+		m.node(n, depth)
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Clusters)) {
+		m.cluster(c.Clusters[id], depth)
+	}
+	if label != "" {
+		fmt.Fprintf(m.w, "%send\n", strings.Repeat("    ", depth-1))
+	}
+}
+
+// mermaidText escapes text for a quoted Mermaid label.
+var mermaidText = strings.NewReplacer(`"`, "#quot;", "\n", "<br>", `\n`, "<br>").Replace
@@ -0,0 +1,145 @@
+package dot
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// An OptionKind validates the values of a graph option, so that they can
+// be written to DOT without changing its meaning.
+type OptionKind func(value string) error
+
+// Shapes are the node shapes of Graphviz.
+var Shapes = []string{
+	"box", "polygon", "ellipse", "oval", "circle", "point", "egg", "triangle",
+	"plaintext", "plain", "diamond", "trapezium", "parallelogram", "house",
+	"pentagon", "hexagon", "septagon", "octagon", "doublecircle",
+	"doubleoctagon", "tripleoctagon", "invtriangle", "invtrapezium",
+	"invhouse", "Mdiamond", "Msquare", "Mcircle", "rect", "rectangle",
+	"square", "star", "none", "underline", "cylinder", "note", "tab",
+	"folder", "box3d", "component", "promoter", "cds", "terminator", "utr",
+	"primersite", "restrictionsite", "fivepoverhang", "threepoverhang",
+	"noverhang", "assembly", "signature", "insulator", "ribosite", "rnastab",
+	"proteasesite", "proteinstab", "rpromoter", "rarrow", "larrow",
+	"lpromoter", "record", "Mrecord",
+}
+
+// Styles are the node styles of Graphviz.
+var Styles = []string{
+	"solid", "dashed", "dotted", "bold", "rounded", "diagonals", "filled",
+	"striped", "wedged", "invis", "radial",
+}
+
+var (
+	colorRe = regexp.MustCompile(`^#?\w+$`)
+	sizeRe  = regexp.MustCompile(`^\d+(\.\d+)?,\d+(\.\d+)?!?$`)
+)
+
+// ShapeOption takes one of the Shapes.
+func ShapeOption(value string) error {
+	if !slices.Contains(Shapes, value) {
+		return fmt.Errorf("want a Graphviz node shape, e.g. box or ellipse")
+	}
+	return nil
+}
+
+// StyleOption takes a comma separated list of Styles.
+func StyleOption(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		if !slices.Contains(Styles, s) {
+			return fmt.Errorf("want comma separated Graphviz node styles, e.g. filled,rounded")
+		}
+	}
+	return nil
+}
+
+// ColorOption takes a color name or a #RRGGBB(AA) color.
+func ColorOption(value string) error {
+	if !colorRe.MatchString(value) {
+		return fmt.Errorf("want a color name or #RRGGBB")
+	}
+	return nil
+}
+
+// SizeOption takes a size in inches as width,height, optionally followed
+// by ! to scale up to it.
+func SizeOption(value string) error {
+	if !sizeRe.MatchString(value) {
+		return fmt.Errorf(`want width,height in inches, e.g. "11,8.5" or "11,8.5!"`)
+	}
+	return nil
+}
+
+// TextOption takes free text, such as a font name, without quotes,
+// backslashes or control characters.
+func TextOption(value string) error {
+	if strings.ContainsFunc(value, func(r rune) bool {
+		return r == '"' || r == '\\' || r < ' ' || r == 0x7f
+	}) {
+		return fmt.Errorf("must not contain quotes, backslashes or control characters")
+	}
+	return nil
+}
+
+// NumberOption takes a non-negative number.
+func NumberOption(value string) error {
+	if f, err := strconv.ParseFloat(value, 64); err != nil || f < 0 {
+		return fmt.Errorf("want a non-negative number")
+	}
+	return nil
+}
+
+// UintOption takes a non-negative integer.
+func UintOption(value string) error {
+	if _, err := strconv.ParseUint(value, 10, 0); err != nil {
+		return fmt.Errorf("want a non-negative integer")
+	}
+	return nil
+}
+
+// OneOfOption takes one of values.
+func OneOfOption(values ...string) OptionKind {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("want one of %v", values)
+		}
+		return nil
+	}
+}
+
+// RatioOption takes a ratio mode or a number.
+func RatioOption(value string) error {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return nil
+	}
+	if slices.Contains([]string{"fill", "compress", "expand", "auto"}, value) {
+		return nil
+	}
+	return fmt.Errorf("want fill, compress, expand, auto or a number")
+}
+
+// GraphOptions are the options written to DOT by WriteDot, by name, with
+// their kind of value.
+var GraphOptions = map[string]OptionKind{
+	"labelloc":      OneOfOption("t", "b", "c"),
+	"fontname":      TextOption,
+	"rankdir":       OneOfOption("LR", "RL", "TB", "BT"),
+	"bgcolor":       ColorOption,
+	"nodesep":       NumberOption,
+	"dpi":           NumberOption,
+	"size":          SizeOption,
+	"ratio":         RatioOption,
+	"splines":       OneOfOption("spline", "ortho", "polyline", "curved", "line", "none", "true", "false"),
+	"concentrate":   OneOfOption("true", "false"),
+	"nodeshape":     ShapeOption,
+	"nodestyle":     StyleOption,
+	"nodefillcolor": ColorOption,
+	"nodefontname":  TextOption,
+	"nodefontsize":  NumberOption,
+	"minlen":        UintOption,
+	"edgefontname":  TextOption,
+	"edgefontsize":  NumberOption,
+}
@@ -0,0 +1,50 @@
+package dot
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// A Renderer writes graphs in a text output format. Formats without a
+// renderer are rendered to images by Graphviz.
+type Renderer interface {
+	Render(w io.Writer, g *DotGraph) error
+}
+
+// RendererFunc adapts a function to a Renderer.
+type RendererFunc func(w io.Writer, g *DotGraph) error
+
+func (f RendererFunc) Render(w io.Writer, g *DotGraph) error {
+	return f(w, g)
+}
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = make(map[string]Renderer)
+)
+
+// RegisterRenderer makes a renderer available for the output format name,
+// replacing any renderer registered before.
+func RegisterRenderer(name string, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	renderers[name] = r
+}
+
+// LookupRenderer returns the renderer registered for the output format
+// name, if any.
+func LookupRenderer(name string) (Renderer, bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	r, ok := renderers[name]
+	return r, ok
+}
+
+// RendererNames returns the sorted names of the registered renderers.
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	return slices.Sorted(maps.Keys(renderers))
+}
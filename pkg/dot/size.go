@@ -0,0 +1,79 @@
+package dot
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SizeLimits bound the size of the graphs rendered to images, beyond
+// which Graphviz takes too long or runs out of memory. Zero means no
+// limit.
+type SizeLimits struct {
+	Nodes int
+	Edges int
+}
+
+// A PackageSize is the number of nodes of a package in a graph.
+type PackageSize struct {
+	Path  string
+	Nodes int
+}
+
+// A GraphTooLargeError reports a graph exceeding the SizeLimits, refused
+// before rendering it.
+type GraphTooLargeError struct {
+	Nodes  int
+	Edges  int
+	Limits SizeLimits
+	// Heaviest are the packages with the most nodes, heaviest first.
+	Heaviest []PackageSize
+	// Suggestions are the flags reducing the graph, like -ignore=pkg.
+	Suggestions []string
+}
+
+func (e *GraphTooLargeError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "graph of %d nodes and %d edges is too large to render (limits %d nodes, %d edges)", e.Nodes, e.Edges, e.Limits.Nodes, e.Limits.Edges)
+	if len(e.Heaviest) > 0 {
+		b.WriteString(", heaviest packages by nodes:")
+		for i, p := range e.Heaviest {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, " %s (%d)", p.Path, p.Nodes)
+		}
+	}
+	b.WriteString("; try ")
+	for _, s := range e.Suggestions {
+		b.WriteString(s + ", ")
+	}
+	b.WriteString("or -force to render it anyway")
+	return b.String()
+}
+
+// AllNodes returns the nodes of g, in its clusters or not.
+func (g *DotGraph) AllNodes() []*DotNode {
+	nodes := slices.Clone(g.Nodes)
+	var walk func(c *DotCluster)
+	walk = func(c *DotCluster) {
+		nodes = append(nodes, c.Nodes...)
+		for _, sub := range c.Clusters {
+			walk(sub)
+		}
+	}
+	if g.Cluster != nil {
+		walk(g.Cluster)
+	}
+	return nodes
+}
+
+// CheckSize returns a GraphTooLargeError if g exceeds limits, nil
+// otherwise.
+func CheckSize(g *DotGraph, limits SizeLimits) *GraphTooLargeError {
+	nodes, edges := len(g.AllNodes()), len(g.Edges)
+	if (limits.Nodes == 0 || nodes <= limits.Nodes) && (limits.Edges == 0 || edges <= limits.Edges) {
+		return nil
+	}
+	return &GraphTooLargeError{Nodes: nodes, Edges: edges, Limits: limits}
+}
@@ -0,0 +1,312 @@
+// Package gitdiff finds the Go functions changed between two git
+// revisions, by comparing the declarations of the changed files rather than
+// the changed lines, so moving or reformatting code does not count.
+package gitdiff
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Changes are the functions changed in a range of revisions.
+type Changes struct {
+	// funcs maps the absolute paths of the changed files to the keys of
+	// their changed declarations, see declKey.
+	funcs map[string]map[string]bool
+	// resolved caches the paths of the analyzed files with their symbolic
+	// links resolved, as git reports the real paths.
+	resolved sync.Map
+}
+
+// Changed returns the functions changed in the revision range revs of the
+// repository containing dir, see Resolve.
+func Changed(ctx context.Context, dir, revs string) (*Changes, error) {
+	root, base, head, err := Resolve(ctx, dir, revs)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"diff", "--name-only", "-z", "--no-renames", "--diff-filter=AM", base}
+	if head != "" {
+		args = append(args, head)
+	}
+	out, err := git(ctx, root, append(args, "--", "*.go")...)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Changes{funcs: make(map[string]map[string]bool)}
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name == "" {
+			continue
+		}
+		// added files have no base version, all of their functions are new
+		old, _ := git(ctx, root, "show", base+":"+name)
+		var cur []byte
+		if head != "" {
+			cur, err = git(ctx, root, "show", head+":"+name)
+		} else {
+			cur, err = os.ReadFile(filepath.Join(root, name))
+		}
+		if err != nil {
+			return nil, err
+		}
+		changed, err := changedDecls(name, old, cur)
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) > 0 {
+			c.funcs[filepath.Join(root, filepath.FromSlash(name))] = changed
+		}
+	}
+	return c, nil
+}
+
+// Resolve returns the root of the repository containing dir and the base
+// and head revisions of the range revs. The range is BASE..HEAD,
+// BASE...HEAD to compare with the merge base of both, or BASE alone to
+// compare with the working tree, head being empty then.
+func Resolve(ctx context.Context, dir, revs string) (root, base, head string, err error) {
+	out, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", "", err
+	}
+	root = strings.TrimSpace(string(out))
+
+	base, mergeBase := revs, false
+	if b, h, ok := strings.Cut(revs, "..."); ok {
+		base, head, mergeBase = b, h, true
+	} else if b, h, ok := strings.Cut(revs, ".."); ok {
+		base, head = b, h
+	}
+	if base == "" {
+		return "", "", "", fmt.Errorf("invalid revision range %q, expected BASE..HEAD", revs)
+	}
+	if mergeBase {
+		out, err := git(ctx, root, "merge-base", base, cmp.Or(head, "HEAD"))
+		if err != nil {
+			return "", "", "", err
+		}
+		base = strings.TrimSpace(string(out))
+	}
+	return root, base, head, nil
+}
+
+// Checkout checks out rev of the repository at root into a new temporary
+// worktree, returning its path and the function removing it.
+func Checkout(ctx context.Context, root, rev string) (string, func() error, error) {
+	dir, err := os.MkdirTemp("", "go-callvis-worktree-")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := git(ctx, root, "worktree", "add", "--detach", "--quiet", dir, rev); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	remove := func() error {
+		// not canceled with ctx, to always clean up
+		_, err := git(context.Background(), root, "worktree", "remove", "--force", dir)
+		return err
+	}
+	return dir, remove, nil
+}
+
+// Len returns the number of changed functions.
+func (c *Changes) Len() int {
+	n := 0
+	for _, keys := range c.funcs {
+		n += len(keys)
+	}
+	return n
+}
+
+// Contains reports whether fn, or the function declaring the closure fn,
+// changed.
+func (c *Changes) Contains(fn *ssa.Function) bool {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	decl, ok := fn.Syntax().(*ast.FuncDecl)
+	if !ok {
+		return false
+	}
+	keys := c.funcs[c.resolve(fn.Prog.Fset.Position(decl.Pos()).Filename)]
+	return keys[declKey(decl, indexOfInit(fn, decl))]
+}
+
+func (c *Changes) resolve(file string) string {
+	if path, ok := c.resolved.Load(file); ok {
+		return path.(string)
+	}
+	path, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		path = file
+	}
+	c.resolved.Store(file, path)
+	return path
+}
+
+// indexOfInit returns the index of the init function fn, declared by decl,
+// among those of its file as numbered by funcDecls, or 0 for other
+// functions. SSA numbers them across the files of the package instead, as
+// init#1, init#2 and so on, so they are counted by position.
+func indexOfInit(fn *ssa.Function, decl *ast.FuncDecl) int {
+	if decl.Recv != nil || decl.Name.Name != "init" || fn.Pkg == nil {
+		return 0
+	}
+	fset := fn.Prog.Fset
+	file := fset.File(decl.Pos())
+	n := 0
+	for name, m := range fn.Pkg.Members {
+		init, ok := m.(*ssa.Function)
+		if !ok || !strings.HasPrefix(name, "init#") {
+			continue
+		}
+		if d, ok := init.Syntax().(*ast.FuncDecl); ok && fset.File(d.Pos()) == file && d.Pos() <= decl.Pos() {
+			n++
+		}
+	}
+	return n
+}
+
+// changedDecls returns the keys of the functions declared in cur which are
+// not declared in old or whose declaration differs, ignoring comments and
+// formatting.
+func changedDecls(name string, old, cur []byte) (map[string]bool, error) {
+	oldDecls := make(map[string]string)
+	if old != nil {
+		decls, err := funcDecls(name, old)
+		if err != nil {
+			// the base version did not compile, consider all functions new
+			decls = nil
+		}
+		oldDecls = decls
+	}
+	curDecls, err := funcDecls(name, cur)
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for key, src := range curDecls {
+		if prev, ok := oldDecls[key]; !ok || prev != src {
+			changed[key] = true
+		}
+	}
+	return changed, nil
+}
+
+// funcDecls returns the function declarations of the source by key, each
+// as its tokens without comments, so formatting does not matter.
+func funcDecls(name string, src []byte) (map[string]string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	file := fset.File(f.Pos())
+	decls := make(map[string]string)
+	inits := 0
+	for _, d := range f.Decls {
+		decl, ok := d.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		init := 0
+		if decl.Recv == nil && decl.Name.Name == "init" {
+			inits++
+			init = inits
+		}
+		start, end := file.Offset(decl.Type.Pos()), file.Offset(decl.End())
+		decls[declKey(decl, init)] = tokens(src[start:end])
+	}
+	return decls, nil
+}
+
+// tokens returns the tokens of src separated by spaces, leaving out the
+// semicolons inserted at line ends.
+func tokens(src []byte) string {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	s.Init(fset.AddFile("", -1, len(src)), src, nil, 0)
+	var b strings.Builder
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit != ";" {
+			continue
+		}
+		if lit == "" {
+			lit = tok.String()
+		}
+		b.WriteString(lit)
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// declKey identifies a function declaration within its file, like Func,
+// T.Method or init#2 for the second init function of the file.
+func declKey(decl *ast.FuncDecl, init int) string {
+	if decl.Recv == nil || len(decl.Recv.List) == 0 {
+		if init > 0 {
+			return fmt.Sprintf("init#%d", init)
+		}
+		return decl.Name.Name
+	}
+	typ := decl.Recv.List[0].Type
+	for {
+		switch t := typ.(type) {
+		case *ast.StarExpr:
+			typ = t.X
+			continue
+		case *ast.ParenExpr:
+			typ = t.X
+			continue
+		case *ast.IndexExpr:
+			typ = t.X
+			continue
+		case *ast.IndexListExpr:
+			typ = t.X
+			continue
+		}
+		break
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name + "." + decl.Name.Name
+	}
+	return decl.Name.Name
+}
+
+// git runs git in dir, returning its output or an error with its message.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,48 @@
+package gitdiff
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
+)
+
+func TestContainsInitOfSecondFile(t *testing.T) {
+	const (
+		a    = "package p\n\nfunc init() {}\n\nfunc init() { println(1) }\n"
+		bOld = "package p\n\nfunc init() { println(2) }\n"
+		bCur = "package p\n\nfunc init() { println(3) }\n"
+	)
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, f := range [][2]string{{"/src/p/a.go", a}, {"/src/p/b.go", bCur}} {
+		file, err := parser.ParseFile(fset, f[0], f[1], 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage("example.com/p", "p"), files, ssa.SanityCheckFunctions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := changedDecls("b.go", []byte(bOld), []byte(bCur))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Changes{funcs: map[string]map[string]bool{"/src/p/b.go": changed}}
+	for name, want := range map[string]bool{
+		"init#1": false, // first of a.go
+		"init#2": false, // second of a.go
+		"init#3": true,  // first of b.go
+	} {
+		if got := c.Contains(pkg.Func(name)); got != want {
+			t.Errorf("Contains(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Package hierarchy exports the call hierarchies of functions in the shape
+// of the Language Server Protocol callHierarchy items, so editor plugins
+// can show the callers and callees found by the whole-program analysis of
+// go-callvis instead of those of a single package.
+package hierarchy
+
+import (
+	"cmp"
+	"encoding/json"
+	"go/ast"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Symbol kinds of the LSP used for items.
+const (
+	KindMethod   = 6
+	KindFunction = 12
+)
+
+// A Position is a zero-based line and character offset. Characters are
+// counted in bytes, which clients must accept by negotiating the utf-8
+// position encoding of LSP 3.17.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// A Range is a span of a file, its end being exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// An Item is an LSP CallHierarchyItem, standing for a function.
+type Item struct {
+	Name string `json:"name"`
+	Kind int    `json:"kind"`
+	// Detail is the import path of the package of the function.
+	Detail string `json:"detail,omitempty"`
+	URI    string `json:"uri"`
+	// Range spans the whole function, SelectionRange its name.
+	Range          Range    `json:"range"`
+	SelectionRange Range    `json:"selectionRange"`
+	Data           ItemData `json:"data"`
+}
+
+// ItemData identifies the function of an item for queries, see
+// output.GraphIndex.Lookup.
+type ItemData struct {
+	Symbol string `json:"symbol"`
+}
+
+// An IncomingCall is an LSP CallHierarchyIncomingCall: a caller with the
+// sites in it calling the function.
+type IncomingCall struct {
+	From       Item    `json:"from"`
+	FromRanges []Range `json:"fromRanges"`
+}
+
+// An OutgoingCall is an LSP CallHierarchyOutgoingCall: a callee with the
+// sites in the function calling it.
+type OutgoingCall struct {
+	To         Item    `json:"to"`
+	FromRanges []Range `json:"fromRanges"`
+}
+
+// An Entry is the call hierarchy of one function.
+type Entry struct {
+	Item     Item           `json:"item"`
+	Incoming []IncomingCall `json:"incoming"`
+	Outgoing []OutgoingCall `json:"outgoing"`
+}
+
+// Of returns the call hierarchies of the functions in cg, skipping the
+// functions without source position.
+func Of(cg *callgraph.Graph, fns []*ssa.Function) []Entry {
+	var entries []Entry
+	for _, fn := range fns {
+		n := cg.Nodes[fn]
+		item, ok := ItemOf(fn)
+		if n == nil || !ok {
+			continue
+		}
+		e := Entry{Item: item, Incoming: []IncomingCall{}, Outgoing: []OutgoingCall{}}
+		for _, c := range groupCalls(n.In, func(e *callgraph.Edge) *ssa.Function { return e.Caller.Func }) {
+			e.Incoming = append(e.Incoming, IncomingCall{From: c.item, FromRanges: c.ranges})
+		}
+		for _, c := range groupCalls(n.Out, func(e *callgraph.Edge) *ssa.Function { return e.Callee.Func }) {
+			e.Outgoing = append(e.Outgoing, OutgoingCall{To: c.item, FromRanges: c.ranges})
+		}
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Or(
+			cmp.Compare(a.Item.URI, b.Item.URI),
+			comparePos(a.Item.Range.Start, b.Item.Range.Start),
+			cmp.Compare(a.Item.Data.Symbol, b.Item.Data.Symbol),
+		)
+	})
+	return entries
+}
+
+// Write writes the entries as JSON to w.
+func Write(w io.Writer, entries []Entry) error {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+type calls struct {
+	item   Item
+	ranges []Range
+}
+
+// groupCalls groups the edges by the function at their other end, with
+// the ranges of their call sites.
+func groupCalls(edges []*callgraph.Edge, end func(*callgraph.Edge) *ssa.Function) []calls {
+	byFunc := make(map[*ssa.Function]*calls)
+	var grouped []*calls
+	for _, e := range edges {
+		fn := end(e)
+		c, ok := byFunc[fn]
+		if !ok {
+			item, ok := ItemOf(fn)
+			if !ok {
+				continue
+			}
+			c = &calls{item: item, ranges: []Range{}}
+			byFunc[fn] = c
+			grouped = append(grouped, c)
+		}
+		if e.Pos().IsValid() {
+			p := position(fn.Prog.Fset, e.Pos())
+			r := Range{Start: p, End: p}
+			if !slices.Contains(c.ranges, r) {
+				c.ranges = append(c.ranges, r)
+			}
+		}
+	}
+	result := make([]calls, len(grouped))
+	for i, c := range grouped {
+		slices.SortFunc(c.ranges, func(a, b Range) int { return comparePos(a.Start, b.Start) })
+		result[i] = *c
+	}
+	slices.SortFunc(result, func(a, b calls) int { return cmp.Compare(a.item.Data.Symbol, b.item.Data.Symbol) })
+	return result
+}
+
+// ItemOf returns the item of fn, reporting false if fn has no source
+// position.
+func ItemOf(fn *ssa.Function) (Item, bool) {
+	if !fn.Pos().IsValid() {
+		return Item{}, false
+	}
+	fset := fn.Prog.Fset
+	file := fset.Position(fn.Pos()).Filename
+	if file == "" {
+		return Item{}, false
+	}
+
+	item := Item{
+		Name: fn.Name(),
+		Kind: KindFunction,
+		URI:  fileURI(file),
+		Data: ItemData{Symbol: fn.String()},
+	}
+	if fn.Pkg != nil {
+		item.Name = fn.RelString(fn.Pkg.Pkg)
+		item.Detail = fn.Pkg.Pkg.Path()
+	}
+	if fn.Signature.Recv() != nil {
+		item.Kind = KindMethod
+	}
+
+	name := Range{Start: position(fset, fn.Pos()), End: position(fset, fn.Pos())}
+	name.End.Character += len(fn.Name())
+	item.Range, item.SelectionRange = name, name
+	switch syntax := fn.Syntax().(type) {
+	case *ast.FuncDecl:
+		item.Range = Range{Start: position(fset, syntax.Pos()), End: position(fset, syntax.End())}
+	case *ast.FuncLit:
+		item.Range = Range{Start: position(fset, syntax.Pos()), End: position(fset, syntax.End())}
+		// anonymous functions are selected by their func keyword
+		item.SelectionRange.End.Character = item.SelectionRange.Start.Character + len("func")
+	}
+	return item, true
+}
+
+func position(fset *token.FileSet, pos token.Pos) Position {
+	p := fset.Position(pos)
+	return Position{Line: max(p.Line-1, 0), Character: max(p.Column-1, 0)}
+}
+
+func comparePos(a, b Position) int {
+	return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Character, b.Character))
+}
+
+// fileURI returns the file URI of the absolute path.
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths like C:/src/main.go
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return u.String()
+}
@@ -1,6 +1,9 @@
 package logger
 
 import (
+	"fmt"
+	"io"
+	stdlog "log"
 	"math"
 	"os"
 	"sync"
@@ -29,6 +32,22 @@ const (
 	noLevel LogLevel = math.MaxInt32
 )
 
+// ParseLevel returns the level of the given name, one of debug, info, warn
+// and error.
+func ParseLevel(name string) (LogLevel, error) {
+	switch name {
+	case "debug":
+		return DebugLevel, nil
+	case "info":
+		return InfoLevel, nil
+	case "warn":
+		return WarnLevel, nil
+	case "error":
+		return ErrorLevel, nil
+	}
+	return 0, fmt.Errorf("invalid log level: %q", name)
+}
+
 type logger struct {
 	*log.Logger
 }
@@ -51,7 +70,51 @@ func InitializeLogger(level LogLevel) error {
 	return nil
 }
 
+// Formats of the log messages accepted by SetFormat.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// SetFormat sets the format of the log messages, text by default or JSON
+// objects, one per line, for log collectors.
+func SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		singleton.SetFormatter(log.TextFormatter)
+	case FormatJSON:
+		singleton.SetFormatter(log.JSONFormatter)
+	default:
+		return fmt.Errorf("invalid log format: %q", format)
+	}
+	return nil
+}
+
+// SetOutput sets the writer the messages are logged to, stderr by default.
+func SetOutput(w io.Writer) {
+	singleton.SetOutput(w)
+	if stdRedirected {
+		// the standard logger writes to a copy of the logger
+		RedirectStdLog()
+	}
+}
+
+// stdRedirected tells if RedirectStdLog was called.
+var stdRedirected bool
+
+// RedirectStdLog routes the messages of the standard log package through
+// the logger at the info level, so they follow its format and level.
+func RedirectStdLog() {
+	stdRedirected = true
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(singleton.StandardLog(log.StandardLogOptions{ForceLevel: log.InfoLevel}).Writer())
+}
+
 func LogDebug(msg string, args ...interface{}) {
+	// skip formatting, debug messages are logged in hot loops
+	if singleton.GetLevel() > log.DebugLevel {
+		return
+	}
 	singleton.Debugf(msg, args...)
 }
 
@@ -0,0 +1,83 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// Cluster label formats accepted by the "clusterlabel" print option. Any
+// other value is parsed as a template over ClusterLabel.
+const (
+	ClusterLabelName     = "name"
+	ClusterLabelPath     = "path"
+	ClusterLabelBase     = "base"
+	ClusterLabelRelative = "relative"
+)
+
+var clusterLabelFormats = map[string]string{
+	ClusterLabelName:     "{{.Name}}",
+	ClusterLabelPath:     "{{.Path}}",
+	ClusterLabelBase:     "{{.Base}}",
+	ClusterLabelRelative: "{{.Relative}}",
+}
+
+// ClusterLabel is the data available to cluster label templates.
+type ClusterLabel struct {
+	Name     string // package name
+	Path     string // package import path
+	Base     string // last element of the import path
+	Relative string // import path relative to the module root
+	Module   string // module path, empty outside of modules
+}
+
+// parseClusterLabel parses a cluster label format or template. An empty
+// format keeps the default labels: package names, and import paths for the
+// standard library.
+func parseClusterLabel(format string) (*template.Template, error) {
+	if format == "" {
+		return nil, nil
+	}
+	text, ok := clusterLabelFormats[format]
+	if !ok {
+		text = strings.ReplaceAll(format, `\n`, "\n")
+	}
+	tmpl, err := template.New("clusterlabel").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cluster label template: %v", err)
+	}
+	if err := tmpl.Execute(io.Discard, ClusterLabel{}); err != nil {
+		return nil, fmt.Errorf("invalid cluster label template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// clusterLabel renders the label of the cluster of pkg using tmpl, falling
+// back to def if there is no template or it fails.
+func clusterLabel(tmpl *template.Template, pkg *types.Package, modules map[string]string, def string) string {
+	if tmpl == nil {
+		return def
+	}
+	data := ClusterLabel{
+		Name:     pkg.Name(),
+		Path:     pkg.Path(),
+		Base:     path.Base(pkg.Path()),
+		Relative: pkg.Path(),
+		Module:   modules[pkg.Path()],
+	}
+	if data.Module != "" {
+		data.Relative = strings.TrimPrefix(strings.TrimPrefix(pkg.Path(), data.Module), "/")
+		if data.Relative == "" {
+			data.Relative = data.Base
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return def
+	}
+	return buf.String()
+}
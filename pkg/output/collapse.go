@@ -0,0 +1,66 @@
+package output
+
+import (
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// isExportedFunc reports whether fn is part of the API-level view.
+// The main function of a main package is kept as well so the graph
+// still has its entry point.
+func isExportedFunc(fn *ssa.Function) bool {
+	if fn == nil || fn.Parent() != nil {
+		return false
+	}
+	if fn.Object() != nil && fn.Object().Exported() {
+		return true
+	}
+	return fn.Pkg != nil && fn.Pkg.Pkg.Name() == "main" && fn.Name() == "main"
+}
+
+// collapse returns a new graph that only contains the functions accepted
+// by keep. Calls that pass through dropped functions are replaced by a
+// transitive edge between the kept endpoints, using the call site in the
+// kept caller.
+func collapse(cg *callgraph.Graph, keep func(fn *ssa.Function) bool) *callgraph.Graph {
+	g := &callgraph.Graph{Nodes: make(map[*ssa.Function]*callgraph.Node)}
+
+	type link struct {
+		site   ssa.CallInstruction
+		callee *ssa.Function
+	}
+
+	for fn, node := range cg.Nodes {
+		if fn == nil || !keep(fn) {
+			continue
+		}
+		from := g.CreateNode(fn)
+		seen := make(map[*callgraph.Node]bool)
+		linked := make(map[link]bool)
+
+		var walk func(n *callgraph.Node, site ssa.CallInstruction)
+		walk = func(n *callgraph.Node, site ssa.CallInstruction) {
+			for _, e := range n.Out {
+				s := site
+				if s == nil {
+					s = e.Site
+				}
+				if keep(e.Callee.Func) {
+					if l := (link{s, e.Callee.Func}); !linked[l] {
+						linked[l] = true
+						callgraph.AddEdge(from, s, g.CreateNode(e.Callee.Func))
+					}
+					continue
+				}
+				if seen[e.Callee] {
+					continue
+				}
+				seen[e.Callee] = true
+				walk(e.Callee, s)
+			}
+		}
+		walk(node, nil)
+	}
+
+	return g
+}
@@ -0,0 +1,49 @@
+package output
+
+import (
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A NodeDecorator returns extra attributes for the node of fn, like
+// colors, links or labels driven by other data sources. They override the
+// attributes set by go-callvis.
+type NodeDecorator func(fn *ssa.Function) map[string]string
+
+// An EdgeDecorator returns extra attributes for the edge of a call. An
+// edge standing for several calls between the same functions is decorated
+// with the first of them.
+type EdgeDecorator func(edge *callgraph.Edge) map[string]string
+
+// decorate applies the decorators to the nodes and edges of the graph,
+// skipping the summary nodes and edges standing for no single function or
+// call.
+func decorate(
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	edges []*dot.DotEdge,
+	edgeCall map[*dot.DotEdge]*callgraph.Edge,
+	nodeDecorators []NodeDecorator,
+	edgeDecorators []EdgeDecorator,
+) {
+	if len(nodeDecorators) > 0 {
+		for n, fn := range nodeFunc {
+			for _, d := range nodeDecorators {
+				for k, v := range d(fn) {
+					n.Attrs[k] = v
+				}
+			}
+		}
+	}
+	for _, e := range edges {
+		call, ok := edgeCall[e]
+		if !ok {
+			continue
+		}
+		for _, d := range edgeDecorators {
+			for k, v := range d(call) {
+				e.Attrs[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package output
+
+import (
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// docAnchor returns the fragment of the documentation page pointing at fn,
+// e.g. #Func or #T.Method, or an empty string for undocumented functions.
+func docAnchor(fn *ssa.Function) string {
+	if fn.Parent() != nil || fn.Synthetic != "" || !token.IsExported(fn.Name()) {
+		return ""
+	}
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return "#" + fn.Name()
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok || !named.Obj().Exported() {
+		return ""
+	}
+	return "#" + named.Obj().Name() + "." + fn.Name()
+}
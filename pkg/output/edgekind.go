@@ -0,0 +1,50 @@
+package output
+
+import (
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Edge kinds accepted by Options.EdgeKinds.
+const (
+	EdgeStatic  = "static"
+	EdgeDynamic = "dynamic"
+	EdgeCall    = "call"
+	EdgeGo      = "go"
+	EdgeDefer   = "defer"
+)
+
+// EdgeKinds lists all valid edge kinds.
+var EdgeKinds = []string{EdgeStatic, EdgeDynamic, EdgeCall, EdgeGo, EdgeDefer}
+
+// edgeKinds returns the kinds of a call edge: whether its callee is known
+// statically, and whether it is a regular, go or defer call.
+func edgeKinds(edge *callgraph.Edge) []string {
+	var kinds []string
+	if edge.Site != nil && edge.Site.Common().StaticCallee() == nil {
+		kinds = append(kinds, EdgeDynamic)
+	} else {
+		kinds = append(kinds, EdgeStatic)
+	}
+	switch edge.Site.(type) {
+	case *ssa.Go:
+		kinds = append(kinds, EdgeGo)
+	case *ssa.Defer:
+		kinds = append(kinds, EdgeDefer)
+	default:
+		kinds = append(kinds, EdgeCall)
+	}
+	return kinds
+}
+
+// hasEdgeKind reports whether edge is of any of the given kinds.
+func hasEdgeKind(edge *callgraph.Edge, kinds []string) bool {
+	for _, k := range edgeKinds(edge) {
+		for _, want := range kinds {
+			if k == want {
+				return true
+			}
+		}
+	}
+	return false
+}
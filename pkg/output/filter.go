@@ -0,0 +1,47 @@
+package output
+
+import (
+	"golang.org/x/tools/go/callgraph"
+)
+
+// A NodeFilter decides which functions are shown. Calls from or to the
+// functions it rejects are omitted. Filters are called concurrently, so
+// they must not modify any state.
+type NodeFilter interface {
+	KeepNode(node *callgraph.Node) bool
+}
+
+// An EdgeFilter decides which calls are shown. Filters are called
+// concurrently, so they must not modify any state.
+type EdgeFilter interface {
+	KeepEdge(edge *callgraph.Edge) bool
+}
+
+// NodeFilterFunc adapts a function to a NodeFilter.
+type NodeFilterFunc func(node *callgraph.Node) bool
+
+func (f NodeFilterFunc) KeepNode(node *callgraph.Node) bool {
+	return f(node)
+}
+
+// EdgeFilterFunc adapts a function to an EdgeFilter.
+type EdgeFilterFunc func(edge *callgraph.Edge) bool
+
+func (f EdgeFilterFunc) KeepEdge(edge *callgraph.Edge) bool {
+	return f(edge)
+}
+
+// passFilters reports whether edge and both of its ends pass all filters.
+func passFilters(edge *callgraph.Edge, nodeFilters []NodeFilter, edgeFilters []EdgeFilter) bool {
+	for _, f := range nodeFilters {
+		if !f.KeepNode(edge.Caller) || !f.KeepNode(edge.Callee) {
+			return false
+		}
+	}
+	for _, f := range edgeFilters {
+		if !f.KeepEdge(edge) {
+			return false
+		}
+	}
+	return true
+}
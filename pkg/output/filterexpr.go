@@ -0,0 +1,397 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Filter expressions select functions or calls, e.g.
+//
+//	pkg =~ "internal" && !exported
+//	caller.pkg != callee.pkg && !dynamic
+//
+// They combine fields, string, number and bool literals with the operators
+// ||, &&, !, ==, !=, <, <=, >, >=, =~ and !~ (matching regular
+// expressions). Expressions using only function fields filter functions,
+// those using caller.<field>, callee.<field> or call fields filter calls.
+
+type exprType int
+
+const (
+	typeBool exprType = iota
+	typeString
+	typeNumber
+)
+
+func (t exprType) String() string {
+	switch t {
+	case typeBool:
+		return "bool"
+	case typeString:
+		return "string"
+	}
+	return "number"
+}
+
+type nodeField struct {
+	typ exprType
+	get func(n *callgraph.Node) any
+}
+
+// nodeFields are the fields of functions in filter expressions.
+var nodeFields = map[string]nodeField{
+	"pkg": {typeString, func(n *callgraph.Node) any {
+		if n.Func.Pkg == nil {
+			return ""
+		}
+		return n.Func.Pkg.Pkg.Path()
+	}},
+	"pkgname": {typeString, func(n *callgraph.Node) any {
+		if n.Func.Pkg == nil {
+			return ""
+		}
+		return n.Func.Pkg.Pkg.Name()
+	}},
+	"name": {typeString, func(n *callgraph.Node) any { return n.Func.Name() }},
+	"func": {typeString, func(n *callgraph.Node) any { return n.Func.String() }},
+	"kind": {typeString, func(n *callgraph.Node) any { return funcKind(n.Func) }},
+	"recv": {typeString, func(n *callgraph.Node) any {
+		if recv := n.Func.Signature.Recv(); recv != nil {
+			return strings.TrimPrefix(recv.Type().String(), "*")
+		}
+		return ""
+	}},
+	"file": {typeString, func(n *callgraph.Node) any {
+		if n.Func.Prog == nil || !n.Func.Pos().IsValid() {
+			return ""
+		}
+		return filepath.Base(n.Func.Prog.Fset.Position(n.Func.Pos()).Filename)
+	}},
+	"exported": {typeBool, func(n *callgraph.Node) any {
+		return n.Func.Object() != nil && n.Func.Object().Exported()
+	}},
+	"std":    {typeBool, func(n *callgraph.Node) any { return n.Func.Pkg != nil && inStd(n) }},
+	"test":   {typeBool, func(n *callgraph.Node) any { return isTestFunc(n.Func) }},
+	"fanin":  {typeNumber, func(n *callgraph.Node) any { return float64(len(n.In)) }},
+	"fanout": {typeNumber, func(n *callgraph.Node) any { return float64(len(n.Out)) }},
+}
+
+// edgeFields are the fields of calls in filter expressions.
+var edgeFields = map[string]func(e *callgraph.Edge) bool{
+	"dynamic": func(e *callgraph.Edge) bool {
+		return e.Site != nil && e.Site.Common().StaticCallee() == nil
+	},
+	"go": func(e *callgraph.Edge) bool {
+		_, ok := e.Site.(*ssa.Go)
+		return ok
+	},
+	"defer": func(e *callgraph.Edge) bool {
+		_, ok := e.Site.(*ssa.Defer)
+		return ok
+	},
+	"crosspkg": func(e *callgraph.Edge) bool {
+		return nodeFields["pkg"].get(e.Caller) != nodeFields["pkg"].get(e.Callee)
+	},
+}
+
+// filterEnv is what a filter expression is evaluated on, a function or a
+// call.
+type filterEnv struct {
+	node *callgraph.Node
+	edge *callgraph.Edge
+}
+
+type compiledExpr struct {
+	typ     exprType
+	literal bool
+	eval    func(env *filterEnv) any
+}
+
+// filterExpr is a compiled filter expression, see ParseFilter.
+type filterExpr struct {
+	eval func(env *filterEnv) any
+}
+
+func (f *filterExpr) KeepNode(node *callgraph.Node) bool {
+	return f.eval(&filterEnv{node: node}).(bool)
+}
+
+func (f *filterExpr) KeepEdge(edge *callgraph.Edge) bool {
+	return f.eval(&filterEnv{edge: edge}).(bool)
+}
+
+// ParseFilter compiles a filter expression. An expression using only
+// function fields results in a NodeFilter, one using caller., callee. or
+// call fields in an EdgeFilter. The other result is nil.
+func ParseFilter(expr string) (NodeFilter, EdgeFilter, error) {
+	p := &filterParser{}
+	p.s.Init(strings.NewReader(expr))
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanFloats | scanner.ScanStrings | scanner.ScanRawStrings
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%s at column %d", msg, s.Pos().Column)
+		}
+	}
+	p.next()
+
+	x, err := p.parseOr()
+	if err == nil && p.tok != scanner.EOF {
+		err = p.errorf("unexpected %s", p.text)
+	}
+	if err == nil && p.err != nil {
+		err = p.err
+	}
+	if err == nil && x.typ != typeBool {
+		err = fmt.Errorf("expression is a %s, not a bool", x.typ)
+	}
+	if err == nil && p.usesNode && p.usesEdge {
+		err = fmt.Errorf("function fields must be prefixed by caller. or callee. when using call fields")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid filter %q: %v", expr, err)
+	}
+
+	f := &filterExpr{eval: x.eval}
+	if p.usesEdge {
+		return nil, f, nil
+	}
+	return f, nil, nil
+}
+
+type filterParser struct {
+	s    scanner.Scanner
+	tok  rune
+	text string
+	col  int
+	err  error
+
+	usesNode bool
+	usesEdge bool
+}
+
+func (p *filterParser) next() {
+	p.tok = p.s.Scan()
+	p.text = p.s.TokenText()
+	p.col = p.s.Position.Column
+	if p.tok == scanner.EOF {
+		p.col = p.s.Pos().Column
+	}
+	// combine two-character operators
+	c := p.s.Peek()
+	switch {
+	case p.tok == '&' && c == '&',
+		p.tok == '|' && c == '|',
+		(p.tok == '=' || p.tok == '!' || p.tok == '<' || p.tok == '>') && c == '=',
+		(p.tok == '=' || p.tok == '!') && c == '~':
+		p.s.Next()
+		p.text += string(c)
+	}
+}
+
+// errorf reports an error at the current token.
+func (p *filterParser) errorf(format string, args ...any) error {
+	return errorAt(p.col, format, args...)
+}
+
+func errorAt(col int, format string, args ...any) error {
+	return fmt.Errorf(format+" at column %d", append(args, col)...)
+}
+
+func (p *filterParser) parseOr() (compiledExpr, error) {
+	return p.parseBinary("||", p.parseAnd, func(x, y func(*filterEnv) any) func(*filterEnv) any {
+		return func(env *filterEnv) any { return x(env).(bool) || y(env).(bool) }
+	})
+}
+
+func (p *filterParser) parseAnd() (compiledExpr, error) {
+	return p.parseBinary("&&", p.parseUnary, func(x, y func(*filterEnv) any) func(*filterEnv) any {
+		return func(env *filterEnv) any { return x(env).(bool) && y(env).(bool) }
+	})
+}
+
+// parseBinary parses operands of a left-associative logical operator.
+func (p *filterParser) parseBinary(op string, operand func() (compiledExpr, error), combine func(x, y func(*filterEnv) any) func(*filterEnv) any) (compiledExpr, error) {
+	x, err := operand()
+	if err != nil {
+		return x, err
+	}
+	for p.text == op {
+		col := p.col
+		p.next()
+		y, err := operand()
+		if err != nil {
+			return y, err
+		}
+		if x.typ != typeBool || y.typ != typeBool {
+			return x, errorAt(col, "operands of %s must be bools", op)
+		}
+		x = compiledExpr{typ: typeBool, eval: combine(x.eval, y.eval)}
+	}
+	return x, nil
+}
+
+func (p *filterParser) parseUnary() (compiledExpr, error) {
+	if p.text != "!" {
+		return p.parseComparison()
+	}
+	col := p.col
+	p.next()
+	x, err := p.parseUnary()
+	if err != nil {
+		return x, err
+	}
+	if x.typ != typeBool {
+		return x, errorAt(col, "operand of ! must be a bool")
+	}
+	eval := x.eval
+	return compiledExpr{typ: typeBool, eval: func(env *filterEnv) any { return !eval(env).(bool) }}, nil
+}
+
+func (p *filterParser) parseComparison() (compiledExpr, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return x, err
+	}
+	op, col := p.text, p.col
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~":
+	default:
+		return x, nil
+	}
+	p.next()
+	y, err := p.parsePrimary()
+	if err != nil {
+		return y, err
+	}
+
+	if op == "=~" || op == "!~" {
+		if x.typ != typeString || y.typ != typeString || !y.literal {
+			return x, errorAt(col, "%s needs a string and a string literal", op)
+		}
+		re, err := regexp.Compile(y.eval(nil).(string))
+		if err != nil {
+			return x, errorAt(col, "%v", err)
+		}
+		want := op == "=~"
+		return compiledExpr{typ: typeBool, eval: func(env *filterEnv) any {
+			return re.MatchString(x.eval(env).(string)) == want
+		}}, nil
+	}
+
+	if x.typ != y.typ {
+		return x, errorAt(col, "cannot compare %s with %s", x.typ, y.typ)
+	}
+	if op == "==" || op == "!=" {
+		want := op == "=="
+		return compiledExpr{typ: typeBool, eval: func(env *filterEnv) any {
+			return (x.eval(env) == y.eval(env)) == want
+		}}, nil
+	}
+	if x.typ != typeNumber {
+		return x, errorAt(col, "%s needs numbers", op)
+	}
+	var cmp func(a, b float64) bool
+	switch op {
+	case "<":
+		cmp = func(a, b float64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b float64) bool { return a <= b }
+	case ">":
+		cmp = func(a, b float64) bool { return a > b }
+	default:
+		cmp = func(a, b float64) bool { return a >= b }
+	}
+	return compiledExpr{typ: typeBool, eval: func(env *filterEnv) any {
+		return cmp(x.eval(env).(float64), y.eval(env).(float64))
+	}}, nil
+}
+
+func (p *filterParser) parsePrimary() (compiledExpr, error) {
+	switch p.tok {
+	case '(':
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return x, err
+		}
+		if p.tok != ')' {
+			return x, p.errorf("missing )")
+		}
+		p.next()
+		return x, nil
+
+	case scanner.String, scanner.RawString:
+		s, err := strconv.Unquote(p.text)
+		if err != nil {
+			return compiledExpr{}, p.errorf("invalid string %s", p.text)
+		}
+		p.next()
+		return compiledExpr{typ: typeString, literal: true, eval: func(*filterEnv) any { return s }}, nil
+
+	case scanner.Int, scanner.Float:
+		f, err := strconv.ParseFloat(p.text, 64)
+		if err != nil {
+			return compiledExpr{}, p.errorf("invalid number %s", p.text)
+		}
+		p.next()
+		return compiledExpr{typ: typeNumber, literal: true, eval: func(*filterEnv) any { return f }}, nil
+
+	case scanner.Ident:
+		return p.parseField()
+	}
+	if p.tok == scanner.EOF {
+		return compiledExpr{}, p.errorf("unexpected end")
+	}
+	return compiledExpr{}, p.errorf("unexpected %s", p.text)
+}
+
+func (p *filterParser) parseField() (compiledExpr, error) {
+	name, col := p.text, p.col
+	p.next()
+
+	switch name {
+	case "true", "false":
+		b := name == "true"
+		return compiledExpr{typ: typeBool, literal: true, eval: func(*filterEnv) any { return b }}, nil
+
+	case "caller", "callee":
+		if p.tok != '.' {
+			return compiledExpr{}, errorAt(col, "missing field of %s", name)
+		}
+		p.next()
+		field, ok := nodeFields[p.text]
+		if p.tok != scanner.Ident || !ok {
+			return compiledExpr{}, p.errorf("unknown field %s.%s", name, p.text)
+		}
+		p.next()
+		p.usesEdge = true
+		end := func(e *callgraph.Edge) *callgraph.Node { return e.Caller }
+		if name == "callee" {
+			end = func(e *callgraph.Edge) *callgraph.Node { return e.Callee }
+		}
+		return compiledExpr{typ: field.typ, eval: func(env *filterEnv) any {
+			return field.get(end(env.edge))
+		}}, nil
+	}
+
+	if field, ok := nodeFields[name]; ok {
+		p.usesNode = true
+		return compiledExpr{typ: field.typ, eval: func(env *filterEnv) any {
+			return field.get(env.node)
+		}}, nil
+	}
+	if get, ok := edgeFields[name]; ok {
+		p.usesEdge = true
+		return compiledExpr{typ: typeBool, eval: func(env *filterEnv) any {
+			return get(env.edge)
+		}}, nil
+	}
+	return compiledExpr{}, errorAt(col, "unknown field %s", name)
+}
@@ -0,0 +1,209 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A finding is a diagnostic of a static analyzer at a line of a file.
+type finding struct {
+	file    string
+	line    int
+	check   string
+	message string
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", filepath.Base(f.file), f.line, f.message, f.check)
+}
+
+// A CodeError is an error of the analyzed code, like a type error, at a
+// position like file.go:12:5, badging the function around it as findings
+// do.
+type CodeError struct {
+	Pos string
+	Msg string
+}
+
+// errorFindings returns the errors as findings of the compiler.
+func errorFindings(errs []CodeError) []finding {
+	var findings []finding
+	for _, e := range errs {
+		if file, line, ok := splitPosn(e.Pos); ok {
+			findings = append(findings, finding{file: file, line: line, check: "error", message: e.Msg})
+		}
+	}
+	return findings
+}
+
+// vetDiagnostic is a diagnostic of `go vet -json`, keyed by package path
+// and analyzer.
+type vetDiagnostic struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// staticcheckProblem is a problem of `staticcheck -f json`.
+type staticcheckProblem struct {
+	Code     string `json:"code"`
+	Location struct {
+		File string `json:"file"`
+		Line int    `json:"line"`
+	} `json:"location"`
+	Message string `json:"message"`
+}
+
+// loadFindings reads the JSON report of `go vet -json`, or of other
+// analysis drivers like `gopls check`, or of `staticcheck -f json`.
+func loadFindings(path string) ([]finding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// go vet prefixes the reports of packages with comment lines
+	var report bytes.Buffer
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			report.Write(line)
+		}
+	}
+
+	var findings []finding
+	dec := json.NewDecoder(&report)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid analyzer report %s: %v", path, err)
+		}
+		var problem staticcheckProblem
+		if err := json.Unmarshal(raw, &problem); err == nil && problem.Code != "" {
+			findings = append(findings, finding{
+				file:    problem.Location.File,
+				line:    problem.Location.Line,
+				check:   problem.Code,
+				message: problem.Message,
+			})
+			continue
+		}
+		// analyzers failing on a package report an error object instead
+		// of diagnostics, which are skipped
+		var pkgs map[string]map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &pkgs); err != nil {
+			return nil, fmt.Errorf("invalid analyzer report %s: %v", path, err)
+		}
+		for _, analyzers := range pkgs {
+			for analyzer, msg := range analyzers {
+				var diags []vetDiagnostic
+				if json.Unmarshal(msg, &diags) != nil {
+					continue
+				}
+				for _, d := range diags {
+					file, line, ok := splitPosn(d.Posn)
+					if !ok {
+						continue
+					}
+					findings = append(findings, finding{file: file, line: line, check: analyzer, message: d.Message})
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// splitPosn splits a position like file.go:12:5 or file.go:12 into its
+// file and line.
+func splitPosn(posn string) (string, int, bool) {
+	file, last, ok := cutLast(posn, ":")
+	if !ok {
+		return "", 0, false
+	}
+	if f, l, ok := cutLast(file, ":"); ok {
+		if line, err := strconv.Atoi(l); err == nil {
+			return f, line, true
+		}
+	}
+	line, err := strconv.Atoi(last)
+	return file, line, err == nil
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// overlayFindings badges the nodes of the functions with findings with
+// their count, listing them in the tooltip. A finding belongs to the
+// innermost function declared around its line.
+func overlayFindings(findings []finding, nodeFunc map[*dot.DotNode]*ssa.Function, theme *Theme) {
+	byFile := make(map[string][]finding)
+	for _, f := range findings {
+		file, err := filepath.Abs(f.file)
+		if err != nil {
+			continue
+		}
+		byFile[file] = append(byFile[file], f)
+	}
+
+	type span struct {
+		node       *dot.DotNode
+		start, end int
+	}
+	// the spans of the functions of each file, innermost first
+	spans := make(map[string][]span)
+	for n, fn := range nodeFunc {
+		syntax := fn.Syntax()
+		if syntax == nil || fn.Prog == nil {
+			continue
+		}
+		if _, ok := syntax.(*ast.FuncDecl); !ok {
+			if _, ok := syntax.(*ast.FuncLit); !ok {
+				continue
+			}
+		}
+		start := fn.Prog.Fset.Position(syntax.Pos())
+		end := fn.Prog.Fset.Position(syntax.End())
+		if _, ok := byFile[start.Filename]; ok {
+			spans[start.Filename] = append(spans[start.Filename], span{n, start.Line, end.Line})
+		}
+	}
+
+	found := make(map[*dot.DotNode][]finding)
+	for file, ss := range spans {
+		slices.SortFunc(ss, func(a, b span) int { return (a.end - a.start) - (b.end - b.start) })
+		for _, f := range byFile[file] {
+			for _, s := range ss {
+				if s.start <= f.line && f.line <= s.end {
+					found[s.node] = append(found[s.node], f)
+					break
+				}
+			}
+		}
+	}
+
+	for n, fs := range found {
+		slices.SortFunc(fs, func(a, b finding) int { return a.line - b.line })
+		lines := make([]string, len(fs))
+		for i, f := range fs {
+			lines[i] = f.String()
+		}
+		n.Attrs["xlabel"] = fmt.Sprintf("⚠ %d", len(fs))
+		n.Attrs["color"] = theme.Nodes[ThemeFinding]
+		n.Attrs["penwidth"] = "2"
+		n.Attrs["tooltip"] += "\n" + strings.Join(lines, "\n")
+	}
+}
@@ -0,0 +1,56 @@
+package output
+
+import (
+	"go/types"
+
+	"golang.org/x/tools/go/callgraph"
+)
+
+// focusEdges returns the edges of cg that may be part of a focused view,
+// without visiting the rest of the graph: the edges from and to functions
+// of the focused packages, plus the outgoing edges of the functions they
+// call, which can close a detour back into the focused packages. Each edge
+// is returned once. The functions of focusPkgs are looked up in index if
+// it covers cg.
+func focusEdges(cg *callgraph.Graph, index *GraphIndex, focusPkgs []*types.Package, isFocusPkg func(*types.Package) bool) []*callgraph.Edge {
+	seen := make(map[*callgraph.Edge]bool)
+	var edges []*callgraph.Edge
+	add := func(e *callgraph.Edge) {
+		if !seen[e] {
+			seen[e] = true
+			edges = append(edges, e)
+		}
+	}
+
+	var focused []*callgraph.Node
+	if index != nil && index.Graph() == cg {
+		for _, pkg := range focusPkgs {
+			focused = append(focused, index.Funcs(pkg)...)
+		}
+	} else {
+		for fn, n := range cg.Nodes {
+			if fn != nil && fn.Pkg != nil && isFocusPkg(fn.Pkg.Pkg) {
+				focused = append(focused, n)
+			}
+		}
+	}
+
+	expanded := make(map[*callgraph.Node]bool)
+	for _, n := range focused {
+		for _, e := range n.In {
+			add(e)
+		}
+		for _, e := range n.Out {
+			add(e)
+			// one step further, for the semi-focused edges
+			if expanded[e.Callee] {
+				continue
+			}
+			expanded[e.Callee] = true
+			for _, out := range e.Callee.Out {
+				add(out)
+			}
+		}
+	}
+	return edges
+}
@@ -0,0 +1,32 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+func TestStripFooterTime(t *testing.T) {
+	render := func(epoch string) []byte {
+		t.Setenv("SOURCE_DATE_EPOCH", epoch)
+		c := dot.NewDotCluster("footer")
+		c.Attrs["label"] = footerLabel("package: ./...\ngo-callvis dev", true)
+		g := &dot.DotGraph{Cluster: c, Options: map[string]string{}}
+		var buf bytes.Buffer
+		if err := g.WriteDot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	a, b := render("1700000000"), render("1800000000")
+	if bytes.Equal(a, b) {
+		t.Fatal("footers of different times render the same")
+	}
+	if !bytes.Equal(StripFooterTime(a), StripFooterTime(b)) {
+		t.Errorf("footers differ without their time:\n%s\n%s", StripFooterTime(a), StripFooterTime(b))
+	}
+	if !bytes.Contains(StripFooterTime(a), []byte(`go-callvis dev"`)) {
+		t.Errorf("StripFooterTime removed more than the time:\n%s", StripFooterTime(a))
+	}
+}
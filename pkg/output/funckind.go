@@ -0,0 +1,32 @@
+package output
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Function kinds used as keys of Theme.Shapes.
+const (
+	FuncFunction  = "function"
+	FuncMethod    = "method"
+	FuncClosure   = "closure"
+	FuncInit      = "init"
+	FuncSynthetic = "synthetic"
+)
+
+// funcKind returns the kind of fn: a closure, a package initializer, a
+// synthetic wrapper, a method or a plain function.
+func funcKind(fn *ssa.Function) string {
+	switch {
+	case fn.Parent() != nil:
+		return FuncClosure
+	case fn.Signature.Recv() == nil && (fn.Name() == "init" || strings.HasPrefix(fn.Name(), "init#")):
+		return FuncInit
+	case fn.Synthetic != "":
+		return FuncSynthetic
+	case fn.Signature.Recv() != nil:
+		return FuncMethod
+	}
+	return FuncFunction
+}
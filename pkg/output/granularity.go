@@ -0,0 +1,182 @@
+package output
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Granularities accepted by the "granularity" print option: one node per
+// function, per package, or per receiver type of methods, the other
+// functions per package.
+const (
+	GranularityFunc = "func"
+	GranularityPkg  = "pkg"
+	GranularityType = "type"
+)
+
+// Granularities lists the granularities, the default first.
+var Granularities = []string{GranularityFunc, GranularityPkg, GranularityType}
+
+// maxAggregatedCalls is the number of calls listed in the tooltip of an
+// aggregated edge.
+const maxAggregatedCalls = 10
+
+// A unit is a node of an aggregated view, a package or a type, collapsing
+// functions.
+type unit struct {
+	id, label, pkg string
+	typ            bool
+}
+
+// unitOf returns the unit of fn at the granularity, pkg or type. Closures
+// belong to the unit of the function declaring them.
+func unitOf(fn *ssa.Function, granularity string) unit {
+	path := fn.Pkg.Pkg.Path()
+	if granularity == GranularityType {
+		for fn.Parent() != nil {
+			fn = fn.Parent()
+		}
+		if named := receiverNamed(fn); named != nil {
+			name := named.Obj().Name()
+			return unit{id: path + "." + name, label: fn.Pkg.Pkg.Name() + "." + name, pkg: path, typ: true}
+		}
+	}
+	return unit{id: path, label: path, pkg: path}
+}
+
+// unitView collapses the functions of each unit of the granularity, pkg or
+// type, into one node and the calls between two units into one edge
+// labeled with their count, the wider the more calls. Calls within a unit
+// are counted in the tooltip of its node, which links to the view focusing
+// its package. It returns the rewritten nodes and edges; the clusters of
+// the collapsed functions are removed, the unit nodes added to the root
+// cluster.
+func unitView(
+	cluster *dot.DotCluster,
+	nodes []*dot.DotNode,
+	edges []*dot.DotEdge,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	granularity string,
+	theme *Theme,
+) ([]*dot.DotNode, []*dot.DotEdge) {
+	units := make(map[string]unit)
+	funcs := make(map[string]int)
+	for _, fn := range nodeFunc {
+		if fn.Pkg != nil {
+			u := unitOf(fn, granularity)
+			units[u.id] = u
+			funcs[u.id]++
+		}
+	}
+
+	unitNodes := make(map[string]*dot.DotNode)
+	within := make(map[string]int)
+	var unitNode = func(n *dot.DotNode) *dot.DotNode {
+		fn, ok := nodeFunc[n]
+		if !ok || fn.Pkg == nil {
+			return n
+		}
+		u := unitOf(fn, granularity)
+		if un, ok := unitNodes[u.id]; ok {
+			return un
+		}
+		shape, fill := "tab", theme.Clusters[ThemePkg]
+		if isGoroot(u.pkg) {
+			fill = theme.Clusters[ThemeStd]
+		}
+		if u.typ {
+			shape, fill = "box", theme.Clusters[ThemeType]
+			if isGoroot(u.pkg) {
+				fill = theme.Clusters[ThemeTypeStd]
+			}
+		}
+		un := &dot.DotNode{
+			ID: u.id,
+			Attrs: dot.DotAttrs{
+				"label":     u.label,
+				"shape":     shape,
+				"style":     "filled",
+				"fillcolor": fill,
+				"URL":       "/?" + url.Values{"f": {u.pkg}}.Encode(),
+			},
+		}
+		unitNodes[u.id] = un
+		return un
+	}
+
+	used := make(map[*dot.DotNode]bool)
+	for n := range nodeFunc {
+		used[unitNode(n)] = true
+	}
+	view := aggregateEdges(edges, unitNode, func(n *dot.DotNode) { within[n.ID]++ })
+	for _, e := range view {
+		used[e.From] = true
+		used[e.To] = true
+	}
+	for id, un := range unitNodes {
+		kind, members := "package", "functions"
+		if units[id].typ {
+			kind, members = "type", "methods"
+		}
+		un.Attrs["tooltip"] = fmt.Sprintf("%s: %s\n%d %s, %d calls within, click to focus its package", kind, id, funcs[id], members, within[id])
+	}
+
+	nodes = pruneNodes(nodes, used)
+	pruneCluster(cluster, used)
+	for _, id := range slices.Sorted(maps.Keys(unitNodes)) {
+		cluster.Nodes = append(cluster.Nodes, unitNodes[id])
+	}
+	return nodes, view
+}
+
+// aggregateEdges maps the ends of edges by unit, merging the edges between
+// the same units into one labeled with the number of calls and listing
+// them in its tooltip. Edges within a unit are dropped, reported to
+// inner with the unit.
+func aggregateEdges(
+	edges []*dot.DotEdge,
+	unit func(n *dot.DotNode) *dot.DotNode,
+	inner func(n *dot.DotNode),
+) []*dot.DotEdge {
+	var view []*dot.DotEdge
+	merged := make(map[[2]*dot.DotNode]*dot.DotEdge)
+	calls := make(map[*dot.DotEdge][]string)
+	for _, e := range edges {
+		from, to := unit(e.From), unit(e.To)
+		if from == e.From && to == e.To {
+			view = append(view, e)
+			continue
+		}
+		if from == to {
+			inner(from)
+			continue
+		}
+		key := [2]*dot.DotNode{from, to}
+		m, ok := merged[key]
+		if !ok {
+			m = &dot.DotEdge{From: from, To: to, Attrs: dot.DotAttrs{}}
+			merged[key] = m
+			view = append(view, m)
+		}
+		calls[m] = append(calls[m], e.From.ID+" → "+e.To.ID)
+	}
+	for m, list := range calls {
+		slices.Sort(list)
+		m.Attrs["label"] = strconv.Itoa(len(list))
+		m.Attrs["penwidth"] = strconv.FormatFloat(1+math.Log2(float64(len(list))), 'f', 1, 64)
+		tooltip := fmt.Sprintf("%d calls from %s to %s", len(list), m.From.ID, m.To.ID)
+		if len(list) > maxAggregatedCalls {
+			list = append(list[:maxAggregatedCalls], fmt.Sprintf("and %d more", len(list)-maxAggregatedCalls))
+		}
+		m.Attrs["tooltip"] = tooltip + "\n" + strings.Join(list, "\n")
+	}
+	return view
+}
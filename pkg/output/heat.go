@@ -0,0 +1,231 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/cover"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Metrics accepted by the "heatby" print option, on top of SizeMetrics.
+const (
+	MetricCoverage = "coverage"
+	MetricSamples  = "samples"
+)
+
+// HeatMetrics lists all valid heatmap metrics.
+var HeatMetrics = []string{MetricCoverage, MetricComplexity, MetricFanIn, MetricFanOut, MetricLOC, MetricSamples}
+
+// heatData holds the external measurements used by the coverage and
+// samples heatmaps.
+type heatData struct {
+	profiles []*cover.Profile
+	samples  *cpuProfile
+}
+
+// loadHeatData reads the file needed by metric: a coverage profile written
+// by `go test -coverprofile` or a pprof CPU profile.
+func loadHeatData(metric, path string) (*heatData, error) {
+	switch metric {
+	case MetricCoverage, MetricSamples:
+	default:
+		return &heatData{}, nil
+	}
+	if path == "" {
+		return nil, fmt.Errorf("heatmap by %s requires a heat file", metric)
+	}
+	if metric == MetricCoverage {
+		profiles, err := cover.ParseProfiles(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coverage profile %s: %v", path, err)
+		}
+		return &heatData{profiles: profiles}, nil
+	}
+
+	prof, err := loadCPUProfile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &heatData{samples: prof}, nil
+}
+
+// coverage returns the fraction of statements of fn covered by the
+// coverage profiles, or false if fn is not part of them.
+func (h *heatData) coverage(fn *ssa.Function) (float64, bool) {
+	syntax := fn.Syntax()
+	if syntax == nil || fn.Pkg == nil {
+		return 0, false
+	}
+	start := fn.Prog.Fset.Position(syntax.Pos())
+	end := fn.Prog.Fset.Position(syntax.End())
+	name := fn.Pkg.Pkg.Path() + "/" + filepath.Base(start.Filename)
+	var covered, total int
+	for _, p := range h.profiles {
+		if p.FileName != name {
+			continue
+		}
+		for _, b := range p.Blocks {
+			if b.StartLine < start.Line || b.EndLine > end.Line {
+				continue
+			}
+			total += b.NumStmt
+			if b.Count > 0 {
+				covered += b.NumStmt
+			}
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(covered) / float64(total), true
+}
+
+// runtimeName returns the name the runtime, and so pprof, uses for fn,
+// e.g. pkg.(*T).Method, pkg.Func.func1, pkg.init.0 or main.Func, with the
+// type arguments elided as in pkg.Map[...].
+func runtimeName(fn *ssa.Function) string {
+	name := fn.RelString(fn.Pkg.Pkg)
+	if i := strings.Index(name, "$"); i >= 0 {
+		parts := strings.Split(name[i+1:], "$")
+		name = name[:i] + ".func" + strings.Join(parts, ".")
+	}
+	if i := strings.Index(name, "init#"); i == 0 {
+		rest := name[len("init#"):]
+		suffix := ""
+		if j := strings.Index(rest, "."); j >= 0 {
+			rest, suffix = rest[:j], rest[j:]
+		}
+		if n, err := strconv.Atoi(rest); err == nil {
+			name = fmt.Sprintf("init.%d%s", n-1, suffix)
+		}
+	}
+	path := fn.Pkg.Pkg.Path()
+	if fn.Pkg.Pkg.Name() == "main" {
+		// the linker names main packages main, whatever their path
+		path = "main"
+	}
+	return path + "." + elideTypeArgs(name)
+}
+
+// elideTypeArgs replaces the type arguments and parameters in name by
+// "...", matching generic functions with their instances in profiles,
+// named by their shape like pkg.Map[go.shape.int] or pkg.Map[...].
+func elideTypeArgs(name string) string {
+	if !strings.Contains(name, "[") {
+		return name
+	}
+	var b strings.Builder
+	depth := 0
+	for _, r := range name {
+		switch {
+		case r == '[':
+			if depth == 0 {
+				b.WriteString("[...]")
+			}
+			depth++
+		case r == ']':
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// heatValues measures every node referenced by edges for the heatmap and
+// maps the values onto [0, 1]. Coverage is inverted, so that uncovered
+// functions are hot.
+func heatValues(edges []*dot.DotEdge, nodeFunc map[*dot.DotNode]*ssa.Function, metric string, data *heatData) map[*dot.DotNode]float64 {
+	switch metric {
+	case MetricCoverage:
+		values := make(map[*dot.DotNode]float64)
+		for n, fn := range nodeFunc {
+			if c, ok := data.coverage(fn); ok {
+				values[n] = 1 - c
+			}
+		}
+		return values
+	case MetricSamples:
+		values := make(map[*dot.DotNode]float64)
+		for n, fn := range nodeFunc {
+			if s, ok := data.samples.funcSamples(fn); ok {
+				values[n] = float64(s)
+			}
+		}
+		return normalize(values)
+	}
+	return normalize(nodeMetrics(edges, nodeFunc, metric))
+}
+
+// heatNodes fills nodes with the color of their value along the theme's
+// heat gradient.
+func heatNodes(values map[*dot.DotNode]float64, gradient []string) {
+	for n, t := range values {
+		color, ok := gradientColor(gradient, t)
+		if !ok {
+			continue
+		}
+		n.Attrs["fillcolor"] = color
+		if luminance(color) < 0.45 {
+			n.Attrs["fontcolor"] = "white"
+		}
+	}
+}
+
+// gradientColor interpolates the color at t in [0, 1] between the hex
+// color stops of gradient.
+func gradientColor(gradient []string, t float64) (string, bool) {
+	if len(gradient) == 0 {
+		return "", false
+	}
+	if len(gradient) == 1 {
+		return gradient[0], true
+	}
+	t = math.Max(0, math.Min(1, t))
+	pos := t * float64(len(gradient)-1)
+	i := int(pos)
+	if i == len(gradient)-1 {
+		i--
+	}
+	from, ok1 := parseHexColor(gradient[i])
+	to, ok2 := parseHexColor(gradient[i+1])
+	if !ok1 || !ok2 {
+		return "", false
+	}
+	f := pos - float64(i)
+	var rgb [3]uint8
+	for c := range rgb {
+		rgb[c] = uint8(math.Round(float64(from[c]) + f*(float64(to[c])-float64(from[c]))))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), true
+}
+
+// parseHexColor parses a color of the form #rrggbb.
+func parseHexColor(s string) ([3]uint8, bool) {
+	var rgb [3]uint8
+	if len(s) != 7 || s[0] != '#' {
+		return rgb, false
+	}
+	for c := range rgb {
+		v, err := strconv.ParseUint(s[1+2*c:3+2*c], 16, 8)
+		if err != nil {
+			return rgb, false
+		}
+		rgb[c] = uint8(v)
+	}
+	return rgb, true
+}
+
+// luminance returns the relative luminance of a #rrggbb color in [0, 1].
+func luminance(s string) float64 {
+	rgb, ok := parseHexColor(s)
+	if !ok {
+		return 1
+	}
+	return (0.2126*float64(rgb[0]) + 0.7152*float64(rgb[1]) + 0.0722*float64(rgb[2])) / 255
+}
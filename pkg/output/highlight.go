@@ -0,0 +1,114 @@
+package output
+
+import (
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// matchSymbol reports whether fn is named by sym. A symbol is either the
+// fully qualified name (e.g. "(*example.com/pkg.T).Method"), the name
+// qualified with the package name (e.g. "pkg.Func", "pkg.(*T).Method"), or
+// the name relative to its package (e.g. "Func", "(*T).Method").
+func matchSymbol(fn *ssa.Function, sym string) bool {
+	if fn.Pkg == nil {
+		return fn.String() == sym
+	}
+	rel := fn.RelString(fn.Pkg.Pkg)
+	return fn.String() == sym ||
+		rel == sym ||
+		fn.Pkg.Pkg.Name()+"."+rel == sym
+}
+
+// matchSymbols reports whether fn is named by any of syms.
+func matchSymbols(fn *ssa.Function, syms []string) bool {
+	for _, sym := range syms {
+		if matchSymbol(fn, sym) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitSymbols splits a comma-separated list of symbols.
+func splitSymbols(s string) []string {
+	var syms []string
+	for _, sym := range strings.Split(s, ",") {
+		if sym = strings.TrimSpace(sym); sym != "" {
+			syms = append(syms, sym)
+		}
+	}
+	return syms
+}
+
+// highlightNodes colors the given target nodes. With paths set, every node
+// and edge on a call path leading to a target is highlighted as well.
+func highlightNodes(targets map[*dot.DotNode]bool, edges []*dot.DotEdge, paths bool, theme *Theme) {
+	reaches := targets
+	if paths {
+		reaches = reachingNodes(targets, edges)
+		for _, e := range edges {
+			if reaches[e.To] {
+				e.Attrs["color"] = theme.Edges[ThemeHighlight]
+				e.Attrs["penwidth"] = "2"
+			}
+		}
+	}
+
+	for n := range reaches {
+		n.Attrs["color"] = theme.Nodes[ThemeHighlightBdr]
+		n.Attrs["penwidth"] = "2"
+		if targets[n] {
+			n.Attrs["fillcolor"] = theme.Nodes[ThemeHighlight]
+		}
+	}
+}
+
+// markChanged colors the changed nodes and outlines their blast radius:
+// the nodes calling them, directly or not, and the calls in between.
+func markChanged(changed map[*dot.DotNode]bool, edges []*dot.DotEdge, theme *Theme) {
+	affected := reachingNodes(changed, edges)
+	for _, e := range edges {
+		if affected[e.To] {
+			e.Attrs["color"] = theme.Edges[ThemeChanged]
+			e.Attrs["penwidth"] = "2"
+		}
+	}
+	for n := range affected {
+		n.Attrs["color"] = theme.Nodes[ThemeChangedBdr]
+		n.Attrs["penwidth"] = "2"
+		if changed[n] {
+			n.Attrs["fillcolor"] = theme.Nodes[ThemeChanged]
+			n.Attrs["tooltip"] += "\nchanged"
+		} else {
+			n.Attrs["tooltip"] += "\naffected by changes"
+		}
+	}
+}
+
+// reachingNodes returns the targets and the nodes on a call path leading
+// to them.
+func reachingNodes(targets map[*dot.DotNode]bool, edges []*dot.DotEdge) map[*dot.DotNode]bool {
+	reaches := make(map[*dot.DotNode]bool)
+	var queue []*dot.DotNode
+	for n := range targets {
+		reaches[n] = true
+		queue = append(queue, n)
+	}
+	callers := make(map[*dot.DotNode][]*dot.DotNode)
+	for _, e := range edges {
+		callers[e.To] = append(callers[e.To], e.From)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, c := range callers[n] {
+			if !reaches[c] {
+				reaches[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	return reaches
+}
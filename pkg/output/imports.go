@@ -0,0 +1,150 @@
+package output
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// importsView collapses the functions of each package into one node, but
+// those of the expanded packages, showing the import graph of the packages
+// with the call graph of the expanded ones nested in it. Calls between
+// collapsed packages are merged into one edge, and imports without calls
+// are added dashed. Collapsed packages link to the view expanding them,
+// and expanded package clusters to the view collapsing them again. It
+// returns the rewritten nodes and edges; the cluster tree is updated in
+// place.
+func importsView(
+	cluster *dot.DotCluster,
+	nodes []*dot.DotNode,
+	edges []*dot.DotEdge,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	expanded []string,
+	theme *Theme,
+) ([]*dot.DotNode, []*dot.DotEdge) {
+	pkgs := make(map[string]*types.Package)
+	funcs := make(map[string]int)
+	for _, fn := range nodeFunc {
+		if fn.Pkg != nil {
+			pkgs[fn.Pkg.Pkg.Path()] = fn.Pkg.Pkg
+			funcs[fn.Pkg.Pkg.Path()]++
+		}
+	}
+
+	pkgNodes := make(map[string]*dot.DotNode)
+	var pkgNode = func(n *dot.DotNode) *dot.DotNode {
+		fn, ok := nodeFunc[n]
+		if !ok || fn.Pkg == nil {
+			return n
+		}
+		path := fn.Pkg.Pkg.Path()
+		if slices.Contains(expanded, path) {
+			return n
+		}
+		if pn, ok := pkgNodes[path]; ok {
+			return pn
+		}
+		fill := theme.Clusters[ThemePkg]
+		if isGoroot(path) {
+			fill = theme.Clusters[ThemeStd]
+		}
+		pn := &dot.DotNode{
+			ID: path,
+			Attrs: dot.DotAttrs{
+				"label":     path,
+				"shape":     "tab",
+				"style":     "filled",
+				"fillcolor": fill,
+				"URL":       expandURL(append(slices.Clone(expanded), path)),
+				"tooltip":   fmt.Sprintf("package: %s\n%d functions, click to expand", path, funcs[path]),
+			},
+		}
+		pkgNodes[path] = pn
+		return pn
+	}
+
+	used := make(map[*dot.DotNode]bool)
+	var view []*dot.DotEdge
+	merged := make(map[[2]*dot.DotNode]*dot.DotEdge)
+	for _, e := range edges {
+		from, to := pkgNode(e.From), pkgNode(e.To)
+		used[from] = true
+		used[to] = true
+		if from == e.From && to == e.To {
+			view = append(view, e)
+			continue
+		}
+		if from == to {
+			continue
+		}
+		key := [2]*dot.DotNode{from, to}
+		if m, ok := merged[key]; ok {
+			m.Attrs["tooltip"] += "\n" + e.Attrs["tooltip"]
+			continue
+		}
+		m := &dot.DotEdge{
+			From:  from,
+			To:    to,
+			Attrs: dot.DotAttrs{"tooltip": e.Attrs["tooltip"]},
+		}
+		merged[key] = m
+		view = append(view, m)
+	}
+
+	// the imports of collapsed packages without calls
+	paths := slices.Sorted(maps.Keys(pkgNodes))
+	for _, path := range paths {
+		from := pkgNodes[path]
+		for _, imp := range pkgs[path].Imports() {
+			to, ok := pkgNodes[imp.Path()]
+			if !ok {
+				continue
+			}
+			if _, ok := merged[[2]*dot.DotNode{from, to}]; ok {
+				continue
+			}
+			view = append(view, &dot.DotEdge{
+				From: from,
+				To:   to,
+				Attrs: dot.DotAttrs{
+					"style":   "dashed",
+					"tooltip": fmt.Sprintf("%s imports %s", path, imp.Path()),
+				},
+			})
+		}
+	}
+
+	nodes = pruneNodes(nodes, used)
+	pruneCluster(cluster, used)
+	var link func(c *dot.DotCluster)
+	link = func(c *dot.DotCluster) {
+		if slices.Contains(expanded, c.ID) {
+			path := c.ID
+			c.Attrs["URL"] = expandURL(slices.DeleteFunc(slices.Clone(expanded), func(p string) bool { return p == path }))
+			c.Attrs["tooltip"] = fmt.Sprintf("package: %s, click to collapse", path)
+		}
+		for _, sub := range c.Clusters {
+			link(sub)
+		}
+	}
+	link(cluster)
+	for _, path := range paths {
+		cluster.Nodes = append(cluster.Nodes, pkgNodes[path])
+	}
+	return nodes, view
+}
+
+// expandURL returns the URL of the imports view expanding the packages.
+func expandURL(expanded []string) string {
+	q := url.Values{"imports": {"true"}}
+	if len(expanded) > 0 {
+		q.Set("expand", strings.Join(expanded, ","))
+	}
+	return "/?" + q.Encode()
+}
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A GraphIndex answers lookups on a call graph without iterating over all
+// of its nodes. It is built once per analysis and must not be modified.
+type GraphIndex struct {
+	graph   *callgraph.Graph
+	byPkg   map[string][]*callgraph.Node
+	byName  map[string][]string
+	symbols map[string][]*ssa.Function
+	callers map[*ssa.Function][]*ssa.Function
+	callees map[*ssa.Function][]*ssa.Function
+}
+
+// NewGraphIndex indexes the functions of cg by package and symbol, their
+// distinct callers and callees, and the packages of pkgs by name.
+func NewGraphIndex(cg *callgraph.Graph, pkgs []*ssa.Package) *GraphIndex {
+	x := &GraphIndex{
+		graph:   cg,
+		byPkg:   make(map[string][]*callgraph.Node),
+		byName:  make(map[string][]string),
+		symbols: make(map[string][]*ssa.Function),
+		callers: make(map[*ssa.Function][]*ssa.Function),
+		callees: make(map[*ssa.Function][]*ssa.Function),
+	}
+
+	// with -tests the same package can be loaded more than once
+	seenPaths := make(map[string]bool)
+	for _, p := range pkgs {
+		if p != nil && !seenPaths[p.Pkg.Path()] {
+			seenPaths[p.Pkg.Path()] = true
+			x.byName[p.Pkg.Name()] = append(x.byName[p.Pkg.Name()], p.Pkg.Path())
+		}
+	}
+	for _, paths := range x.byName {
+		sort.Strings(paths)
+	}
+
+	for fn, n := range cg.Nodes {
+		if fn == nil {
+			continue
+		}
+		x.callers[fn] = distinctFuncs(n.In, func(e *callgraph.Edge) *ssa.Function { return e.Caller.Func })
+		x.callees[fn] = distinctFuncs(n.Out, func(e *callgraph.Edge) *ssa.Function { return e.Callee.Func })
+		x.addSymbol(fn.String(), fn)
+		if fn.Pkg == nil {
+			continue
+		}
+		key := pkgKey(fn.Pkg.Pkg)
+		x.byPkg[key] = append(x.byPkg[key], n)
+		rel := fn.RelString(fn.Pkg.Pkg)
+		x.addSymbol(rel, fn)
+		x.addSymbol(fn.Pkg.Pkg.Name()+"."+rel, fn)
+	}
+	return x
+}
+
+func (x *GraphIndex) addSymbol(sym string, fn *ssa.Function) {
+	fns := x.symbols[sym]
+	if len(fns) > 0 && fns[len(fns)-1] == fn {
+		return
+	}
+	x.symbols[sym] = append(fns, fn)
+}
+
+func distinctFuncs(edges []*callgraph.Edge, end func(*callgraph.Edge) *ssa.Function) []*ssa.Function {
+	seen := make(map[*ssa.Function]bool)
+	var fns []*ssa.Function
+	for _, e := range edges {
+		if fn := end(e); !seen[fn] {
+			seen[fn] = true
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// Graph returns the indexed call graph.
+func (x *GraphIndex) Graph() *callgraph.Graph {
+	return x.graph
+}
+
+// Funcs returns the nodes of the functions of pkg and of its test
+// variants, see pkgKey.
+func (x *GraphIndex) Funcs(pkg *types.Package) []*callgraph.Node {
+	return x.byPkg[pkgKey(pkg)]
+}
+
+// pkgKey returns the key of the functions of pkg in the index: its import
+// path, without the _test suffix of external test packages. With -tests,
+// a package is loaded again with its tests, as pkg [pkg.test], and its
+// external tests as pkg_test, all of them sharing the key of pkg.
+func pkgKey(pkg *types.Package) string {
+	return strings.TrimSuffix(pkg.Path(), "_test")
+}
+
+// PackagePaths returns the sorted import paths of the packages named name.
+func (x *GraphIndex) PackagePaths(name string) []string {
+	return x.byName[name]
+}
+
+// Lookup returns the functions named by sym, see matchSymbol.
+func (x *GraphIndex) Lookup(sym string) []*ssa.Function {
+	return x.symbols[sym]
+}
+
+// Callers returns the distinct functions calling fn.
+func (x *GraphIndex) Callers(fn *ssa.Function) []*ssa.Function {
+	return x.callers[fn]
+}
+
+// Callees returns the distinct functions called by fn.
+func (x *GraphIndex) Callees(fn *ssa.Function) []*ssa.Function {
+	return x.callees[fn]
+}
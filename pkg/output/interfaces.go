@@ -0,0 +1,227 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// interfacesView replaces the call graph by the interfaces declared in the
+// packages of its functions, listing their methods, and the concrete types
+// of these packages implementing them, by their value or their pointer.
+// Interfaces extending others point to them as well. With calls, the
+// dynamic calls of the functions through an interface are added from the
+// interface to the types whose methods they reach, labeled with the
+// methods and counted. It returns the new nodes and edges; the clusters
+// are emptied, the type nodes added to the root cluster.
+func interfacesView(
+	cluster *dot.DotCluster,
+	nodes []*dot.DotNode,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	cg *callgraph.Graph,
+	calls bool,
+	theme *Theme,
+) ([]*dot.DotNode, []*dot.DotEdge) {
+	pkgs := make(map[*types.Package]bool)
+	for _, fn := range nodeFunc {
+		if fn.Pkg != nil {
+			pkgs[fn.Pkg.Pkg] = true
+		}
+	}
+	var ifaces, concrete []*types.Named
+	for pkg := range pkgs {
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				if iface.NumMethods() > 0 {
+					ifaces = append(ifaces, named)
+				}
+			} else {
+				concrete = append(concrete, named)
+			}
+		}
+	}
+	byName := func(x, y *types.Named) int { return cmp.Compare(typeID(x), typeID(y)) }
+	slices.SortFunc(ifaces, byName)
+	slices.SortFunc(concrete, byName)
+
+	typeNodes := make(map[*types.Named]*dot.DotNode)
+	var typeNode = func(t *types.Named) *dot.DotNode {
+		if n, ok := typeNodes[t]; ok {
+			return n
+		}
+		n := &dot.DotNode{
+			ID: typeID(t),
+			Attrs: dot.DotAttrs{
+				"label":     t.Obj().Pkg().Name() + "." + t.Obj().Name(),
+				"shape":     "box",
+				"style":     "filled",
+				"fillcolor": theme.Clusters[ThemeType],
+				"tooltip":   "type: " + typeID(t),
+			},
+		}
+		if iface, ok := t.Underlying().(*types.Interface); ok {
+			var names, sigs []string
+			for i := range iface.NumMethods() {
+				m := iface.Method(i)
+				names = append(names, m.Name())
+				sigs = append(sigs, m.Name()+strings.TrimPrefix(types.TypeString(m.Type(), types.RelativeTo(t.Obj().Pkg())), "func"))
+			}
+			n.Attrs["label"] = "«interface»\n" + n.Attrs["label"] + "\n\n" + strings.Join(names, "\n")
+			n.Attrs["fillcolor"] = theme.Clusters[ThemeTypeFocus]
+			n.Attrs["tooltip"] = "interface: " + typeID(t) + "\n" + strings.Join(sigs, "\n")
+		}
+		typeNodes[t] = n
+		return n
+	}
+
+	var edges []*dot.DotEdge
+	for _, i := range ifaces {
+		iface := i.Underlying().(*types.Interface)
+		for _, t := range concrete {
+			via := ""
+			if types.Implements(t, iface) {
+				via = typeID(t)
+			} else if types.Implements(types.NewPointer(t), iface) {
+				via = "*" + typeID(t)
+			} else {
+				continue
+			}
+			edges = append(edges, &dot.DotEdge{
+				From: typeNode(t),
+				To:   typeNode(i),
+				Attrs: dot.DotAttrs{
+					"style":     "dashed",
+					"arrowhead": "empty",
+					"tooltip":   fmt.Sprintf("%s implements %s", via, typeID(i)),
+				},
+			})
+		}
+		for _, j := range ifaces {
+			if j != i && types.Implements(j, iface) {
+				edges = append(edges, &dot.DotEdge{
+					From: typeNode(j),
+					To:   typeNode(i),
+					Attrs: dot.DotAttrs{
+						"arrowhead": "empty",
+						"tooltip":   fmt.Sprintf("%s extends %s", typeID(j), typeID(i)),
+					},
+				})
+			}
+		}
+	}
+	for _, i := range ifaces {
+		typeNode(i)
+	}
+	if calls {
+		edges = append(edges, interfaceCalls(typeNodes, nodeFunc, cg, theme)...)
+	}
+
+	used := make(map[*dot.DotNode]bool)
+	nodes = pruneNodes(nodes, used)
+	pruneCluster(cluster, used)
+	for _, t := range slices.SortedFunc(maps.Keys(typeNodes), byName) {
+		cluster.Nodes = append(cluster.Nodes, typeNodes[t])
+	}
+	return nodes, edges
+}
+
+// interfaceCalls returns the edges from the interfaces of typeNodes to the
+// concrete types of typeNodes whose methods the dynamic calls of the
+// functions of nodeFunc through them reach.
+func interfaceCalls(
+	typeNodes map[*types.Named]*dot.DotNode,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	cg *callgraph.Graph,
+	theme *Theme,
+) []*dot.DotEdge {
+	type dispatch struct{ iface, recv *types.Named }
+	methods := make(map[dispatch]map[string]int)
+	for _, fn := range nodeFunc {
+		n := cg.Nodes[fn]
+		if n == nil {
+			continue
+		}
+		for _, e := range n.Out {
+			if e.Site == nil || !e.Site.Common().IsInvoke() {
+				continue
+			}
+			iface, ok := e.Site.Common().Value.Type().(*types.Named)
+			if !ok || typeNodes[iface] == nil {
+				continue
+			}
+			recv := receiverNamed(e.Callee.Func)
+			if recv == nil || typeNodes[recv] == nil {
+				continue
+			}
+			d := dispatch{iface, recv}
+			if methods[d] == nil {
+				methods[d] = make(map[string]int)
+			}
+			methods[d][e.Site.Common().Method.Name()]++
+		}
+	}
+
+	var edges []*dot.DotEdge
+	for d, counts := range methods {
+		total := 0
+		var lines []string
+		for _, m := range slices.Sorted(maps.Keys(counts)) {
+			total += counts[m]
+			lines = append(lines, fmt.Sprintf("%s: %d calls", m, counts[m]))
+		}
+		edges = append(edges, &dot.DotEdge{
+			From: typeNodes[d.iface],
+			To:   typeNodes[d.recv],
+			Attrs: dot.DotAttrs{
+				"label":   strings.Join(slices.Sorted(maps.Keys(counts)), ", "),
+				"color":   theme.Edges[ThemeHighlight],
+				"weight":  strconv.Itoa(total),
+				"tooltip": fmt.Sprintf("calls through %s reaching %s\n%s", typeID(d.iface), typeID(d.recv), strings.Join(lines, "\n")),
+			},
+		})
+	}
+	return edges
+}
+
+// receiverNamed returns the named type of the receiver of the method fn,
+// the generic type for instantiations, nil for functions.
+func receiverNamed(fn *ssa.Function) *types.Named {
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return nil
+	}
+	t := recv.Type()
+	if ptr, ok := t.(*types.Pointer); ok {
+		t = ptr.Elem()
+	}
+	named, ok := t.(*types.Named)
+	if !ok {
+		return nil
+	}
+	return named.Origin()
+}
+
+// typeID returns the import path qualified name of t.
+func typeID(t *types.Named) string {
+	if t.Obj().Pkg() == nil {
+		return t.Obj().Name()
+	}
+	return t.Obj().Pkg().Path() + "." + t.Obj().Name()
+}
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"golang.org/x/tools/go/callgraph"
+)
+
+// NodeLabel is the data available to node label templates.
+type NodeLabel struct {
+	Pkg       string // package name
+	PkgPath   string // package import path
+	Func      string // function name relative to its package, e.g. (*T).Method
+	Name      string // bare function name
+	Qualified string // fully qualified function name
+	Signature string // function signature
+	File      string // base name of the file declaring the function
+	Line      int    // line the function is declared at
+	FanIn     int    // number of incoming call edges
+	FanOut    int    // number of outgoing call edges
+}
+
+// parseNodeLabel parses a node label template. A literal `\n` in text is
+// turned into a newline, so templates can be passed on the command line.
+func parseNodeLabel(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	text = strings.ReplaceAll(text, `\n`, "\n")
+	tmpl, err := template.New("nodelabel").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node label template: %v", err)
+	}
+	// catch references to unknown fields before rendering any node
+	if err := tmpl.Execute(io.Discard, NodeLabel{}); err != nil {
+		return nil, fmt.Errorf("invalid node label template: %v", err)
+	}
+	return tmpl, nil
+}
+
+// nodeLabel renders the label of node using tmpl.
+func nodeLabel(tmpl *template.Template, node *callgraph.Node) (string, error) {
+	fn := node.Func
+	pos := fn.Prog.Fset.Position(fn.Pos())
+	data := NodeLabel{
+		Pkg:       fn.Pkg.Pkg.Name(),
+		PkgPath:   fn.Pkg.Pkg.Path(),
+		Func:      fn.RelString(fn.Pkg.Pkg),
+		Name:      fn.Name(),
+		Qualified: fn.String(),
+		Signature: fn.Signature.String(),
+		File:      filepath.Base(pos.Filename),
+		Line:      pos.Line,
+		FanIn:     len(node.In),
+		FanOut:    len(node.Out),
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,187 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// Metrics accepted by Options.RankBy.
+const (
+	RankPageRank = "pagerank"
+	RankFanIn    = "fanin"
+	RankFanOut   = "fanout"
+	RankDegree   = "degree"
+)
+
+// RankMetrics lists all valid node ranking metrics.
+var RankMetrics = []string{RankPageRank, RankFanIn, RankFanOut, RankDegree}
+
+// rankNodes scores every node referenced by edges using the given metric.
+func rankNodes(edges []*dot.DotEdge, metric string) map[*dot.DotNode]float64 {
+	score := make(map[*dot.DotNode]float64)
+	out := make(map[*dot.DotNode][]*dot.DotNode)
+	for _, e := range edges {
+		score[e.From] += 0
+		score[e.To] += 0
+		out[e.From] = append(out[e.From], e.To)
+	}
+
+	switch metric {
+	case RankFanIn:
+		for _, e := range edges {
+			score[e.To]++
+		}
+	case RankFanOut:
+		for _, e := range edges {
+			score[e.From]++
+		}
+	case RankDegree:
+		for _, e := range edges {
+			score[e.From]++
+			score[e.To]++
+		}
+	default:
+		const (
+			damping    = 0.85
+			iterations = 20
+		)
+		n := float64(len(score))
+		for node := range score {
+			score[node] = 1 / n
+		}
+		for i := 0; i < iterations; i++ {
+			next := make(map[*dot.DotNode]float64, len(score))
+			var dangling float64
+			for node, s := range score {
+				if len(out[node]) == 0 {
+					dangling += s
+				}
+			}
+			for node := range score {
+				next[node] = (1-damping)/n + damping*dangling/n
+			}
+			for node, targets := range out {
+				share := score[node] / float64(len(targets))
+				for _, t := range targets {
+					next[t] += damping * share
+				}
+			}
+			score = next
+		}
+	}
+
+	return score
+}
+
+// limitNodes keeps the max most important nodes referenced by edges and
+// elides the others into one summary node per package. It returns the
+// rewritten edges; the cluster tree is updated in place.
+func limitNodes(
+	cluster *dot.DotCluster,
+	edges []*dot.DotEdge,
+	nodePkg map[*dot.DotNode]string,
+	max uint,
+	metric string,
+	theme *Theme,
+) []*dot.DotEdge {
+	score := rankNodes(edges, metric)
+	if uint(len(score)) <= max {
+		return edges
+	}
+
+	ranked := make([]*dot.DotNode, 0, len(score))
+	for n := range score {
+		ranked = append(ranked, n)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if score[ranked[i]] != score[ranked[j]] {
+			return score[ranked[i]] > score[ranked[j]]
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+
+	kept := make(map[*dot.DotNode]bool)
+	for _, n := range ranked[:max] {
+		kept[n] = true
+	}
+
+	// one summary node per package for everything elided
+	summaries := make(map[string]*dot.DotNode)
+	elided := make(map[string][]string)
+	for _, n := range ranked[max:] {
+		pkg := nodePkg[n]
+		elided[pkg] = append(elided[pkg], n.ID)
+	}
+	var pkgs []string
+	for pkg := range elided {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		names := elided[pkg]
+		sort.Strings(names)
+		tooltip := strings.Join(names, "\n")
+		if len(names) > 20 {
+			tooltip = strings.Join(names[:20], "\n") + "\n..."
+		}
+		n := &dot.DotNode{
+			ID: fmt.Sprintf("elided: %s", pkg),
+			Attrs: dot.DotAttrs{
+				"label":     fmt.Sprintf("%s\n(%d more)", pkg, len(names)),
+				"shape":     "folder",
+				"style":     "filled,dashed",
+				"fillcolor": theme.Nodes[ThemeElided],
+				"fontcolor": theme.FontColors[ThemeElided],
+				"tooltip":   tooltip,
+			},
+		}
+		summaries[pkg] = n
+		kept[n] = true
+	}
+
+	var replace = func(n *dot.DotNode) *dot.DotNode {
+		if s, ok := summaries[nodePkg[n]]; ok && !kept[n] {
+			return s
+		}
+		return n
+	}
+
+	var limited []*dot.DotEdge
+	merged := make(map[[2]*dot.DotNode]*dot.DotEdge)
+	for _, e := range edges {
+		from, to := replace(e.From), replace(e.To)
+		if from == e.From && to == e.To {
+			limited = append(limited, e)
+			continue
+		}
+		if from == to {
+			continue
+		}
+		key := [2]*dot.DotNode{from, to}
+		if m, ok := merged[key]; ok {
+			m.Attrs["tooltip"] = fmt.Sprintf("%s\n%s", m.Attrs["tooltip"], e.Attrs["tooltip"])
+			continue
+		}
+		m := &dot.DotEdge{
+			From: from,
+			To:   to,
+			Attrs: dot.DotAttrs{
+				"style":   "dotted",
+				"color":   theme.Edges[ThemeElided],
+				"tooltip": e.Attrs["tooltip"],
+			},
+		}
+		merged[key] = m
+		limited = append(limited, m)
+	}
+
+	pruneCluster(cluster, kept)
+	for _, pkg := range pkgs {
+		cluster.Nodes = append(cluster.Nodes, summaries[pkg])
+	}
+
+	return limited
+}
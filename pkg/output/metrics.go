@@ -0,0 +1,113 @@
+package output
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Metrics accepted by the "nodesizeby" print option.
+const (
+	MetricLOC        = "loc"
+	MetricFanIn      = "fanin"
+	MetricFanOut     = "fanout"
+	MetricComplexity = "complexity"
+)
+
+// SizeMetrics lists all valid node size metrics.
+var SizeMetrics = []string{MetricLOC, MetricFanIn, MetricFanOut, MetricComplexity}
+
+// defaultFontSize is the Graphviz default font size of node labels.
+const defaultFontSize = 14.0
+
+// funcLOC returns the number of source lines spanned by fn, or 0 if its
+// syntax is not available.
+func funcLOC(fn *ssa.Function) int {
+	syntax := fn.Syntax()
+	if syntax == nil || fn.Prog == nil {
+		return 0
+	}
+	start := fn.Prog.Fset.Position(syntax.Pos())
+	end := fn.Prog.Fset.Position(syntax.End())
+	return end.Line - start.Line + 1
+}
+
+// funcComplexity returns the cyclomatic complexity of fn computed from its
+// control flow graph, or 0 for functions without a body.
+func funcComplexity(fn *ssa.Function) int {
+	if len(fn.Blocks) == 0 {
+		return 0
+	}
+	edges := 0
+	for _, b := range fn.Blocks {
+		edges += len(b.Succs)
+	}
+	return edges - len(fn.Blocks) + 2
+}
+
+// nodeMetrics measures every node referenced by edges using the given
+// metric. Nodes without a function, like elided summary nodes, are only
+// measured by fan-in and fan-out.
+func nodeMetrics(edges []*dot.DotEdge, nodeFunc map[*dot.DotNode]*ssa.Function, metric string) map[*dot.DotNode]float64 {
+	switch metric {
+	case MetricFanIn:
+		return rankNodes(edges, RankFanIn)
+	case MetricFanOut:
+		return rankNodes(edges, RankFanOut)
+	}
+	values := make(map[*dot.DotNode]float64)
+	for _, e := range edges {
+		for _, n := range []*dot.DotNode{e.From, e.To} {
+			fn, ok := nodeFunc[n]
+			if !ok {
+				continue
+			}
+			switch metric {
+			case MetricLOC:
+				values[n] = float64(funcLOC(fn))
+			case MetricComplexity:
+				values[n] = float64(funcComplexity(fn))
+			}
+		}
+	}
+	return values
+}
+
+// normalize maps values onto [0, 1] using a square root scale, so a few
+// outliers do not flatten all other nodes.
+func normalize[K comparable](values map[K]float64) map[K]float64 {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, math.Sqrt(v))
+		hi = math.Max(hi, math.Sqrt(v))
+	}
+	norm := make(map[K]float64, len(values))
+	for n, v := range values {
+		if hi > lo {
+			norm[n] = (math.Sqrt(v) - lo) / (hi - lo)
+		} else {
+			norm[n] = 0
+		}
+	}
+	return norm
+}
+
+// scaleNodes grows the width, height and font size of nodes by their
+// normalized metric, see resizeNodes.
+func scaleNodes(values map[*dot.DotNode]float64, fontSize float64) {
+	resizeNodes(normalize(values), fontSize)
+}
+
+// resizeNodes grows the width, height and font size of nodes by t in
+// [0, 1], up to three times the default size and twice the given font
+// size.
+func resizeNodes(values map[*dot.DotNode]float64, fontSize float64) {
+	for n, t := range values {
+		factor := 1 + 2*t
+		n.Attrs["width"] = strconv.FormatFloat(0.75*factor, 'f', 2, 64)
+		n.Attrs["height"] = strconv.FormatFloat(0.5*factor, 'f', 2, 64)
+		n.Attrs["fontsize"] = strconv.FormatFloat(fontSize*(1+t), 'f', 1, 64)
+	}
+}
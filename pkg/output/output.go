@@ -2,11 +2,20 @@ package output
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"go/build"
 	"go/types"
+	"io"
+	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ofabry/go-callvis/pkg/dot"
 	"github.com/ofabry/go-callvis/pkg/logger"
@@ -14,31 +23,254 @@ import (
 	"golang.org/x/tools/go/ssa"
 )
 
+// Same rank modes accepted by the "samerank" print option.
+const (
+	SameRankNone     = "none"
+	SameRankExported = "exported"
+	SameRankEntry    = "entry"
+)
+
+// Edge routings accepted by the "splines" print option.
+const (
+	SplinesSpline   = "spline"
+	SplinesOrtho    = "ortho"
+	SplinesPolyline = "polyline"
+	SplinesCurved   = "curved"
+)
+
+// Edge label modes accepted by the "edgelabel" print option.
+const (
+	EdgeLabelNone  = "none"
+	EdgeLabelFirst = "first"
+	EdgeLabelAll   = "all"
+)
+
 func isSynthetic(edge *callgraph.Edge) bool {
 	return edge.Caller.Func.Pkg == nil || edge.Callee.Func.Synthetic != ""
 }
 
 func inStd(node *callgraph.Node) bool {
-	pkg, _ := build.Import(node.Func.Pkg.Pkg.Path(), "", 0)
+	return isGoroot(node.Func.Pkg.Pkg.Path())
+}
+
+// goroot caches whether package paths belong to GOROOT, as looking them up
+// hits the file system and is done for every edge of every render.
+var goroot sync.Map
+
+// isGoroot reports whether the package with the given path is located in
+// GOROOT, i.e. it is part of the standard library.
+func isGoroot(path string) bool {
+	if v, ok := goroot.Load(path); ok {
+		return v.(bool)
+	}
+	pkg, _ := build.Import(path, "", 0)
+	goroot.Store(path, pkg.Goroot)
 	return pkg.Goroot
 }
 
+// MarkStd records whether the package with the given path belongs to the
+// standard library, for loaders finding packages out of GOROOT.
+func MarkStd(path string, std bool) {
+	goroot.Store(path, std)
+}
+
+// Options controls which calls PrintOutput keeps and how they are rendered.
+type Options struct {
+	LimitPaths    []string
+	IgnorePaths   []string
+	IncludePaths  []string
+	GroupBy       []string
+	NoStd         bool
+	NoInter       bool
+	ExportedOnly  bool
+	NoTestHelpers bool
+	TestCluster   bool
+	CrossPkgOnly  bool
+	MinWeight     uint
+	UnfocusPaths  []string
+	EdgeKinds     []string
+	MaxNodes      uint
+	RankBy        string
+	// NodeFilters and EdgeFilters omit the functions and calls they
+	// reject, after all other filters.
+	NodeFilters []NodeFilter
+	EdgeFilters []EdgeFilter
+	// NodeDecorators and EdgeDecorators add attributes to the nodes and
+	// edges, after all other styling.
+	NodeDecorators []NodeDecorator
+	EdgeDecorators []EdgeDecorator
+	// Changed reports whether fn changed, marking it and its callers.
+	Changed func(fn *ssa.Function) bool
+	// Unused are the exported functions never called within the module,
+	// dimmed.
+	Unused []*ssa.Function
+	// Errors are the errors of the analyzed code, if analyzed anyway.
+	Errors []CodeError
+	Theme  *Theme
+	// DocLinks maps package paths to their documentation page.
+	DocLinks map[string]string
+	// Modules maps package paths to the path of their module.
+	Modules map[string]string
+	// Index is the index of the analyzed call graph, if any.
+	Index        *GraphIndex
+	Minlen       uint
+	PrintOptions map[string]string
+}
+
+// PrintOutput renders the call graph cg as DOT, see WriteOutput.
 func PrintOutput(
+	ctx context.Context,
 	prog *ssa.Program,
 	mainPkg *ssa.Package,
 	cg *callgraph.Graph,
-	focusPkg *types.Package,
-	limitPaths,
-	ignorePaths,
-	includePaths []string,
-	groupBy []string,
-	nostd,
-	nointer bool,
-	minlen uint,
-	options map[string]string,
+	focusPkgs []*types.Package,
+	opts *Options,
 ) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteOutput(ctx, &buf, prog, mainPkg, cg, focusPkgs, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteOutput renders the call graph cg as DOT, streaming it to w, see
+// BuildGraph.
+func WriteOutput(
+	ctx context.Context,
+	w io.Writer,
+	prog *ssa.Program,
+	mainPkg *ssa.Package,
+	cg *callgraph.Graph,
+	focusPkgs []*types.Package,
+	opts *Options,
+) error {
+	g, err := BuildGraph(ctx, prog, mainPkg, cg, focusPkgs, opts)
+	if err != nil {
+		return err
+	}
+	return g.WriteDot(w)
+}
+
+// BuildGraph builds the DOT graph of the call graph cg. The call graph is
+// expected to have its synthetic nodes deleted and is not modified, so the
+// same call graph can be rendered repeatedly with different options.
+// Building is aborted when ctx is done.
+func BuildGraph(
+	ctx context.Context,
+	prog *ssa.Program,
+	mainPkg *ssa.Package,
+	cg *callgraph.Graph,
+	focusPkgs []*types.Package,
+	opts *Options,
+) (*dot.DotGraph, error) {
+	var (
+		limitPaths   = opts.LimitPaths
+		ignorePaths  = opts.IgnorePaths
+		includePaths = opts.IncludePaths
+		nostd        = opts.NoStd
+		nointer      = opts.NoInter
+		testCluster  = opts.TestCluster
+	)
+
+	theme := opts.Theme
+	if theme == nil {
+		theme = DefaultTheme()
+	}
+
+	labelTmpl, err := parseNodeLabel(opts.PrintOptions["nodelabel"])
+	if err != nil {
+		return nil, err
+	}
+
+	sameRank := opts.PrintOptions["samerank"]
+	switch sameRank {
+	case "", SameRankNone, SameRankExported, SameRankEntry:
+	default:
+		return nil, fmt.Errorf("invalid same rank mode: %q", sameRank)
+	}
+
+	// reproducible graphs leave out the time of the footer
+	reproducible := opts.PrintOptions["reproducible"] == "true"
+
+	edgeLabel := opts.PrintOptions["edgelabel"]
+	switch edgeLabel {
+	case "", EdgeLabelNone, EdgeLabelFirst, EdgeLabelAll:
+	default:
+		return nil, fmt.Errorf("invalid edge label mode: %q", edgeLabel)
+	}
+
+	sizeBy := opts.PrintOptions["nodesizeby"]
+	if sizeBy != "" && !slices.Contains(SizeMetrics, sizeBy) {
+		return nil, fmt.Errorf("invalid node size metric: %q", sizeBy)
+	}
+
+	heatBy := opts.PrintOptions["heatby"]
+	if heatBy != "" && !slices.Contains(HeatMetrics, heatBy) {
+		return nil, fmt.Errorf("invalid heatmap metric: %q", heatBy)
+	}
+	heat, err := loadHeatData(heatBy, opts.PrintOptions["heatfile"])
+	if err != nil {
+		return nil, err
+	}
+	var prof *cpuProfile
+	if path := opts.PrintOptions["profile"]; path != "" {
+		if prof, err = loadCPUProfile(path); err != nil {
+			return nil, err
+		}
+	}
+	var execTrace *execTrace
+	if path := opts.PrintOptions["exectrace"]; path != "" {
+		if execTrace, err = loadExecTrace(path); err != nil {
+			return nil, err
+		}
+	}
+	var findings []finding
+	if path := opts.PrintOptions["findings"]; path != "" {
+		if findings, err = loadFindings(path); err != nil {
+			return nil, err
+		}
+	}
+	findings = append(findings, errorFindings(opts.Errors)...)
+	var spans map[string][]string
+	if path := opts.PrintOptions["otelspans"]; path != "" {
+		if spans, err = loadSpanMap(path); err != nil {
+			return nil, err
+		}
+	}
+
+	switch splines := opts.PrintOptions["splines"]; splines {
+	case "", SplinesSpline, SplinesOrtho, SplinesPolyline, SplinesCurved:
+	default:
+		return nil, fmt.Errorf("invalid edge routing: %q", splines)
+	}
+
+	granularity := opts.PrintOptions["granularity"]
+	switch granularity {
+	case "", GranularityFunc:
+	case GranularityPkg, GranularityType:
+		if opts.PrintOptions["imports"] == "true" {
+			return nil, fmt.Errorf("granularity %s and the imports view are exclusive", granularity)
+		}
+	default:
+		return nil, fmt.Errorf("invalid granularity: %q", granularity)
+	}
+	interfaces := opts.PrintOptions["interfaces"] == "true" || opts.PrintOptions["interfacecalls"] == "true"
+	if interfaces && (opts.PrintOptions["imports"] == "true" || granularity == GranularityPkg || granularity == GranularityType) {
+		return nil, fmt.Errorf("the interfaces view excludes the imports view and granularity %s", granularity)
+	}
+
+	clusterTmpl, err := parseClusterLabel(opts.PrintOptions["clusterlabel"])
+	if err != nil {
+		return nil, err
+	}
+
+	clusterFont := theme.Fonts[ThemeCluster]
+	if f := opts.PrintOptions["clusterfontname"]; f != "" {
+		clusterFont = f
+	}
+
 	var groupType, groupPkg bool
-	for _, g := range groupBy {
+	for _, g := range opts.GroupBy {
 		switch g {
 		case "pkg":
 			groupPkg = true
@@ -49,15 +281,30 @@ func PrintOutput(
 
 	cluster := dot.NewDotCluster("focus")
 	cluster.Attrs = dot.DotAttrs{
-		"bgcolor":   "white",
+		"bgcolor":   theme.Clusters[ThemeRoot],
 		"label":     "",
 		"labelloc":  "t",
 		"labeljust": "c",
 		"fontsize":  "18",
 	}
-	if focusPkg != nil {
-		cluster.Attrs["bgcolor"] = "#e6ecfa"
-		cluster.Attrs["label"] = focusPkg.Name()
+	focusPenwidth := opts.PrintOptions["focuspenwidth"]
+	if len(focusPkgs) == 1 {
+		cluster.Attrs["bgcolor"] = theme.Clusters[ThemeFocus]
+		cluster.Attrs["label"] = clusterLabel(clusterTmpl, focusPkgs[0], opts.Modules, focusPkgs[0].Name())
+		emphasize(cluster.Attrs, "pencolor", theme.Clusters[ThemeFocusBdr], focusPenwidth)
+	}
+	// with multiple focus packages each one gets its own cluster
+	multiFocus := len(focusPkgs) > 1
+	// dim everything outside the focused packages
+	dim := multiFocus || (len(focusPkgs) > 0 && opts.PrintOptions["dim"] == "true")
+
+	var isFocusPkg = func(pkg *types.Package) bool {
+		for _, p := range focusPkgs {
+			if pkg.Path() == p.Path() {
+				return true
+			}
+		}
+		return false
 	}
 
 	var (
@@ -65,34 +312,53 @@ func PrintOutput(
 		edges []*dot.DotEdge
 	)
 
-	nodeMap := make(map[string]*dot.DotNode)
-	edgeMap := make(map[string]*dot.DotEdge)
+	highlighted := make(map[*dot.DotNode]bool)
+	changed := make(map[*dot.DotNode]bool)
+	exportedFocus := make(map[*dot.DotNode]bool)
+	highlight := splitSymbols(opts.PrintOptions["highlight"])
+	trace := splitSymbols(opts.PrintOptions["trace"])
+	traceTargets := make(map[*dot.DotNode]bool)
+
+	if opts.ExportedOnly {
+		cg = collapse(cg, isExportedFunc)
+	}
+	if opts.NoTestHelpers {
+		cg = collapse(cg, func(fn *ssa.Function) bool {
+			return !isTestHelper(fn)
+		})
+	}
 
-	cg.DeleteSyntheticNodes()
+	removed := unfocused(cg, opts.UnfocusPaths)
 
 	logger.LogDebug("%d limit prefixes: %v", len(limitPaths), limitPaths)
 	logger.LogDebug("%d ignore prefixes: %v", len(ignorePaths), ignorePaths)
 	logger.LogDebug("%d include prefixes: %v", len(includePaths), includePaths)
 	logger.LogDebug("no std packages: %v", nostd)
+	logger.LogDebug("exported only: %v", opts.ExportedOnly)
+	logger.LogDebug("no test helpers: %v, test cluster: %v", opts.NoTestHelpers, testCluster)
+	logger.LogDebug("cross-package only: %v", opts.CrossPkgOnly)
+	logger.LogDebug("min edge weight: %d", opts.MinWeight)
+	logger.LogDebug("edge kinds: %v", opts.EdgeKinds)
+	logger.LogDebug("max nodes: %d (ranked by %s)", opts.MaxNodes, opts.RankBy)
+	logger.LogDebug("%d node filters, %d edge filters", len(opts.NodeFilters), len(opts.EdgeFilters))
+	logger.LogDebug("%d unfocus prefixes: %v (%d functions removed)", len(opts.UnfocusPaths), opts.UnfocusPaths, len(removed))
 
 	var isFocused = func(edge *callgraph.Edge) bool {
 		caller := edge.Caller
 		callee := edge.Callee
-		if focusPkg != nil && (caller.Func.Pkg.Pkg.Path() == focusPkg.Path() || callee.Func.Pkg.Pkg.Path() == focusPkg.Path()) {
+		if isFocusPkg(caller.Func.Pkg.Pkg) || isFocusPkg(callee.Func.Pkg.Pkg) {
 			return true
 		}
 		fromFocused := false
 		toFocused := false
 		for _, e := range caller.In {
-			if !isSynthetic(e) && focusPkg != nil &&
-				e.Caller.Func.Pkg.Pkg.Path() == focusPkg.Path() {
+			if !isSynthetic(e) && isFocusPkg(e.Caller.Func.Pkg.Pkg) {
 				fromFocused = true
 				break
 			}
 		}
 		for _, e := range callee.Out {
-			if !isSynthetic(e) && focusPkg != nil &&
-				e.Callee.Func.Pkg.Pkg.Path() == focusPkg.Path() {
+			if !isSynthetic(e) && isFocusPkg(e.Callee.Func.Pkg.Pkg) {
 				toFocused = true
 				break
 			}
@@ -143,42 +409,55 @@ func PrintOutput(
 		return false
 	}
 
-	count := 0
-	err := callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
-		count++
-
+	// keepEdge reports whether edge passes all filters. It is called
+	// concurrently, so it must not modify any state.
+	var keepEdge = func(edge *callgraph.Edge) bool {
 		caller := edge.Caller
 		callee := edge.Callee
 
-		posCaller := prog.Fset.Position(caller.Func.Pos())
-		posCallee := prog.Fset.Position(callee.Func.Pos())
-		posEdge := prog.Fset.Position(edge.Pos())
-		//fileCaller := fmt.Sprintf("%s:%d", posCaller.Filename, posCaller.Line)
-		filenameCaller := filepath.Base(posCaller.Filename)
-
 		// omit synthetic calls
 		if isSynthetic(edge) {
-			return nil
+			return false
 		}
 
 		callerPkg := caller.Func.Pkg.Pkg
 		calleePkg := callee.Func.Pkg.Pkg
 
+		// omit unfocused pkgs and their exclusive callees
+		if removed[caller.Func] || removed[callee.Func] {
+			return false
+		}
+
 		// focus specific pkg
-		if focusPkg != nil &&
+		if len(focusPkgs) > 0 &&
 			!isFocused(edge) {
-			return nil
+			return false
 		}
 
 		// omit std
 		if nostd &&
 			(inStd(caller) || inStd(callee)) {
-			return nil
+			return false
 		}
 
 		// omit inter
 		if nointer && isInter(edge) {
-			return nil
+			return false
+		}
+
+		// omit unwanted kinds of calls
+		if len(opts.EdgeKinds) > 0 && !hasEdgeKind(edge, opts.EdgeKinds) {
+			return false
+		}
+
+		// omit calls within the same package
+		if opts.CrossPkgOnly && callerPkg.Path() == calleePkg.Path() {
+			return false
+		}
+
+		// omit functions and calls rejected by custom filters
+		if !passFilters(edge, opts.NodeFilters, opts.EdgeFilters) {
+			return false
 		}
 
 		include := false
@@ -194,17 +473,65 @@ func PrintOutput(
 			if len(limitPaths) > 0 &&
 				(!inLimits(caller) || !inLimits(callee)) {
 				logger.LogDebug("NOT in limit: %s -> %s", caller, callee)
-				return nil
+				return false
 			}
 
 			// ignore path prefixes
 			if len(ignorePaths) > 0 &&
 				(inIgnores(caller) || inIgnores(callee)) {
 				logger.LogDebug("IS ignored: %s -> %s", caller, callee)
-				return nil
+				return false
 			}
 		}
+		return true
+	}
+
+	// collect the edges first, so they can be filtered concurrently,
+	// visiting only the neighborhood of the focused packages if any
+	var all []*callgraph.Edge
+	if len(focusPkgs) > 0 {
+		all = focusEdges(cg, opts.Index, focusPkgs, isFocusPkg)
+	} else {
+		err = callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
+			all = append(all, edge)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	count := len(all)
+	kept := filterEdges(all, keepEdge)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// sized for the kept edges, which mostly connect distinct functions
+	nodeMap := make(map[string]*dot.DotNode, len(kept))
+	funcNode := make(map[*ssa.Function]*dot.DotNode, len(kept))
+	nodePkg := make(map[*dot.DotNode]string, len(kept))
+	nodeFunc := make(map[*dot.DotNode]*ssa.Function, len(kept))
+	nodeTips := make(map[*dot.DotNode][]string, len(kept))
+	edgeMap := make(map[edgeKey]*dot.DotEdge, len(kept))
+	edgeWeight := make(map[edgeKey]uint, len(kept))
+	edgeSites := make(map[edgeKey][]string, len(kept))
+	edgeTips := make(map[edgeKey][]string, len(kept))
+	edgeCall := make(map[*dot.DotEdge]*callgraph.Edge, len(kept))
+
+	for _, edge := range kept {
+		caller := edge.Caller
+		callee := edge.Callee
+		callerPkg := caller.Func.Pkg.Pkg
+		calleePkg := callee.Func.Pkg.Pkg
 
+		posCaller := prog.Fset.Position(caller.Func.Pos())
+		posCallee := prog.Fset.Position(callee.Func.Pos())
+		posEdge := prog.Fset.Position(edge.Pos())
+		//fileCaller := fmt.Sprintf("%s:%d", posCaller.Filename, posCaller.Line)
+		filenameCaller := filepath.Base(posCaller.Filename)
 		//var buf bytes.Buffer
 		//data, _ := json.MarshalIndent(caller.Func, "", " ")
 		//logf("call node: %s -> %s\n %v", caller, callee, string(data))
@@ -212,25 +539,23 @@ func PrintOutput(
 
 		var sprintNode = func(node *callgraph.Node, isCaller bool) *dot.DotNode {
 			// only once
-			key := node.Func.String()
-			nodeTooltip := ""
-
-			fileCaller := fmt.Sprintf("%s:%d", filepath.Base(posCaller.Filename), posCaller.Line)
-			fileCallee := fmt.Sprintf("%s:%d", filepath.Base(posCallee.Filename), posCallee.Line)
-
-			if isCaller {
-				nodeTooltip = fmt.Sprintf("%s | defined in %s", node.Func.String(), fileCaller)
-			} else {
-				nodeTooltip = fmt.Sprintf("%s | defined in %s", node.Func.String(), fileCallee)
+			if n, ok := funcNode[node.Func]; ok {
+				return n
 			}
-
+			key := node.Func.String()
 			if n, ok := nodeMap[key]; ok {
+				funcNode[node.Func] = n
 				return n
 			}
 
+			pos := posCallee
+			if isCaller {
+				pos = posCaller
+			}
+			nodeTooltip := key + " | defined in " + filepath.Base(pos.Filename) + ":" + strconv.Itoa(pos.Line)
+
 			// is focused
-			isFocused := focusPkg != nil &&
-				node.Func.Pkg.Pkg.Path() == focusPkg.Path()
+			isFocused := isFocusPkg(node.Func.Pkg.Pkg)
 			attrs := make(dot.DotAttrs)
 
 			// node label
@@ -248,14 +573,21 @@ func PrintOutput(
 				label = parts[len(parts)-1]
 			}
 
-			pkg, _ := build.Import(node.Func.Pkg.Pkg.Path(), "", 0)
+			isStd := isGoroot(node.Func.Pkg.Pkg.Path())
+			isTest := testCluster && isTestFunc(node.Func)
 			// set node color
-			if isFocused {
-				attrs["fillcolor"] = "lightblue"
-			} else if pkg.Goroot {
-				attrs["fillcolor"] = "#adedad"
+			if isTest {
+				attrs["fillcolor"] = theme.Nodes[ThemeTest]
+			} else if isFocused {
+				attrs["fillcolor"] = theme.Nodes[ThemeFocus]
+				emphasize(attrs, "color", theme.Nodes[ThemeFocusBdr], "")
+			} else if dim {
+				attrs["fillcolor"] = theme.Nodes[ThemeDimmed]
+				attrs["fontcolor"] = theme.FontColors[ThemeDimmed]
+			} else if isStd {
+				attrs["fillcolor"] = theme.Nodes[ThemeStd]
 			} else {
-				attrs["fillcolor"] = "moccasin"
+				attrs["fillcolor"] = theme.Nodes[ThemeDefault]
 			}
 
 			// include pkg name
@@ -263,6 +595,14 @@ func PrintOutput(
 				label = fmt.Sprintf("%s\n%s", node.Func.Pkg.Pkg.Name(), label)
 			}
 
+			if labelTmpl != nil {
+				if l, err := nodeLabel(labelTmpl, node); err == nil {
+					label = l
+				} else {
+					logger.LogWarn("node label for %s: %v", node.Func, err)
+				}
+			}
+
 			attrs["label"] = label
 
 			// func styles
@@ -274,14 +614,63 @@ func PrintOutput(
 				attrs["penwidth"] = "0.5"
 			}
 
+			// func kind shapes
+			if shape := theme.Shapes[funcKind(node.Func)]; shape != "" {
+				attrs["shape"] = shape
+			}
+
 			c := cluster
 
+			// group test code
+			if isTest {
+				if _, ok := c.Clusters["tests"]; !ok {
+					c.Clusters["tests"] = &dot.DotCluster{
+						ID:       "tests",
+						Clusters: make(map[string]*dot.DotCluster),
+						Attrs: dot.DotAttrs{
+							"penwidth":  "0.8",
+							"fontsize":  "16",
+							"label":     "tests",
+							"style":     "filled",
+							"fillcolor": theme.Clusters[ThemeTest],
+							"fontname":  clusterFont,
+							"tooltip":   "test code",
+						},
+					}
+				}
+				c = c.Clusters["tests"]
+			}
+
+			// one cluster per focused package
+			if multiFocus && isFocused {
+				key := node.Func.Pkg.Pkg.Path()
+				if _, ok := c.Clusters[key]; !ok {
+					c.Clusters[key] = &dot.DotCluster{
+						ID:       key,
+						Clusters: make(map[string]*dot.DotCluster),
+						Attrs: dot.DotAttrs{
+							"penwidth":  "1.2",
+							"fontsize":  "18",
+							"label":     clusterLabel(clusterTmpl, node.Func.Pkg.Pkg, opts.Modules, node.Func.Pkg.Pkg.Name()),
+							"style":     "filled",
+							"fillcolor": theme.Clusters[ThemeFocus],
+							"URL":       fmt.Sprintf("/?f=%s", key),
+							"fontname":  clusterFont,
+							"tooltip":   fmt.Sprintf("focus: %s", key),
+						},
+					}
+					emphasize(c.Clusters[key].Attrs, "pencolor", theme.Clusters[ThemeFocusBdr], focusPenwidth)
+				}
+				c = c.Clusters[key]
+			}
+
 			// group by pkg
 			if groupPkg && !isFocused {
 				label := node.Func.Pkg.Pkg.Name()
-				if pkg.Goroot {
+				if isStd {
 					label = node.Func.Pkg.Pkg.Path()
 				}
+				label = clusterLabel(clusterTmpl, node.Func.Pkg.Pkg, opts.Modules, label)
 				key := node.Func.Pkg.Pkg.Path()
 				if _, ok := c.Clusters[key]; !ok {
 					c.Clusters[key] = &dot.DotCluster{
@@ -292,15 +681,18 @@ func PrintOutput(
 							"fontsize":  "16",
 							"label":     label,
 							"style":     "filled",
-							"fillcolor": "lightyellow",
+							"fillcolor": theme.Clusters[ThemePkg],
 							"URL":       fmt.Sprintf("/?f=%s", key),
-							"fontname":  "Tahoma bold",
+							"fontname":  clusterFont,
 							"tooltip":   fmt.Sprintf("package: %s", key),
 							"rank":      "sink",
 						},
 					}
-					if pkg.Goroot {
-						c.Clusters[key].Attrs["fillcolor"] = "#E0FFE1"
+					if dim {
+						c.Clusters[key].Attrs["fillcolor"] = theme.Clusters[ThemeDimmed]
+						c.Clusters[key].Attrs["fontcolor"] = theme.FontColors[ThemeDimmed]
+					} else if isStd {
+						c.Clusters[key].Attrs["fillcolor"] = theme.Clusters[ThemeStd]
 					}
 				}
 				c = c.Clusters[key]
@@ -317,18 +709,21 @@ func PrintOutput(
 						Attrs: dot.DotAttrs{
 							"penwidth":  "0.5",
 							"fontsize":  "15",
-							"fontcolor": "#222222",
+							"fontcolor": theme.FontColors[ThemeType],
 							"label":     label,
 							"labelloc":  "b",
 							"style":     "rounded,filled",
-							"fillcolor": "wheat2",
+							"fillcolor": theme.Clusters[ThemeType],
 							"tooltip":   fmt.Sprintf("type: %s", key),
 						},
 					}
 					if isFocused {
-						c.Clusters[key].Attrs["fillcolor"] = "lightsteelblue"
-					} else if pkg.Goroot {
-						c.Clusters[key].Attrs["fillcolor"] = "#c2e3c2"
+						c.Clusters[key].Attrs["fillcolor"] = theme.Clusters[ThemeTypeFocus]
+					} else if dim {
+						c.Clusters[key].Attrs["fillcolor"] = theme.Clusters[ThemeDimmed]
+						c.Clusters[key].Attrs["fontcolor"] = theme.FontColors[ThemeDimmed]
+					} else if isStd {
+						c.Clusters[key].Attrs["fillcolor"] = theme.Clusters[ThemeTypeStd]
 					}
 				}
 				c = c.Clusters[key]
@@ -336,6 +731,12 @@ func PrintOutput(
 
 			attrs["tooltip"] = nodeTooltip
 
+			// link external code to its documentation
+			if link, ok := opts.DocLinks[node.Func.Pkg.Pkg.Path()]; ok {
+				attrs["URL"] = link + docAnchor(node.Func)
+				attrs["target"] = "_blank"
+			}
+
 			n := &dot.DotNode{
 				ID:    node.Func.String(),
 				Attrs: attrs,
@@ -348,79 +749,169 @@ func PrintOutput(
 			}
 
 			nodeMap[key] = n
+			funcNode[node.Func] = n
+			nodePkg[n] = node.Func.Pkg.Pkg.Path()
+			nodeFunc[n] = node.Func
+			if matchSymbols(node.Func, highlight) {
+				highlighted[n] = true
+			}
+			if matchSymbols(node.Func, trace) {
+				traceTargets[n] = true
+			}
+			if opts.Changed != nil && opts.Changed(node.Func) {
+				changed[n] = true
+			}
+			if isFocused && isExportedFunc(node.Func) {
+				exportedFocus[n] = true
+			}
 			return n
 		}
 		callerNode := sprintNode(edge.Caller, true)
 		calleeNode := sprintNode(edge.Callee, false)
 
-		// edges
-		attrs := make(dot.DotAttrs)
+		// use position in file where callee is called as tooltip for the edge
+		site := filepath.Base(posEdge.Filename) + ":" + strconv.Itoa(posEdge.Line)
+		fileEdge := "at " + site + ": calling [" + calleeNode.ID + "]"
 
-		// dynamic call
-		if edge.Site != nil && edge.Site.Common().StaticCallee() == nil {
-			attrs["style"] = "dashed"
+		// omit duplicate calls, except for tooltip enhancements
+		key := edgeKey{from: callerNode, to: calleeNode, desc: edge.Description()}
+		edgeWeight[key]++
+		edgeSites[key] = append(edgeSites[key], site)
+		edgeTips[key] = append(edgeTips[key], fileEdge)
+		if _, ok := edgeMap[key]; ok {
+			continue
 		}
 
-		// go & defer calls
-		switch edge.Site.(type) {
-		case *ssa.Go:
-			attrs["arrowhead"] = "normalnoneodot"
-		case *ssa.Defer:
-			attrs["arrowhead"] = "normalnoneodiamond"
-		}
+		// edges
+		attrs := make(dot.DotAttrs)
 
 		// colorize calls outside focused pkg
-		if focusPkg != nil &&
-			(calleePkg.Path() != focusPkg.Path() || callerPkg.Path() != focusPkg.Path()) {
-			attrs["color"] = "saddlebrown"
+		if multiFocus && isFocusPkg(callerPkg) && isFocusPkg(calleePkg) &&
+			callerPkg.Path() != calleePkg.Path() {
+			// calls between two focused packages
+			attrs["color"] = theme.Edges[ThemeBetween]
+			attrs["penwidth"] = "1.5"
+		} else if len(focusPkgs) > 0 &&
+			(!isFocusPkg(calleePkg) || !isFocusPkg(callerPkg)) {
+			attrs["color"] = theme.Edges[ThemeOutside]
 		}
 
-		// use position in file where callee is called as tooltip for the edge
-		fileEdge := fmt.Sprintf(
-			"at %s:%d: calling [%s]",
-			filepath.Base(posEdge.Filename),
-			posEdge.Line,
-			edge.Callee.Func.String(),
-		)
-
-		// omit duplicate calls, except for tooltip enhancements
-		key := fmt.Sprintf("%s = %s => %s", caller.Func, edge.Description(), callee.Func)
-		if _, ok := edgeMap[key]; !ok {
-			attrs["tooltip"] = fileEdge
-			e := &dot.DotEdge{
-				From:  callerNode,
-				To:    calleeNode,
-				Attrs: attrs,
-			}
-			edgeMap[key] = e
-		} else {
-			// make sure, tooltip is created correctly
-			if _, okk := edgeMap[key].Attrs["tooltip"]; !okk {
-				edgeMap[key].Attrs["tooltip"] = fileEdge
-			} else {
-				edgeMap[key].Attrs["tooltip"] = fmt.Sprintf(
-					"%s\n%s",
-					edgeMap[key].Attrs["tooltip"],
-					fileEdge,
-				)
+		// style by call kind: dynamic, go & defer calls
+		kinds := edgeKinds(edge)
+		if callerPkg.Path() != calleePkg.Path() {
+			kinds = append(kinds, ThemeCrossPkg)
+		}
+		for _, k := range kinds {
+			for name, value := range theme.EdgeStyles[k] {
+				attrs[name] = value
 			}
 		}
 
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		edgeMap[key] = &dot.DotEdge{
+			From:  callerNode,
+			To:    calleeNode,
+			Attrs: attrs,
+		}
+		edgeCall[edgeMap[key]] = edge
 	}
 
 	// get edges form edgeMap
-	for _, e := range edgeMap {
-		e.From.Attrs["tooltip"] = fmt.Sprintf(
-			"%s\n%s",
-			e.From.Attrs["tooltip"],
-			e.Attrs["tooltip"],
-		)
+	usedNodes := make(map[*dot.DotNode]bool)
+	for key, e := range edgeMap {
+		// omit edges with too few call sites
+		if edgeWeight[key] < opts.MinWeight {
+			continue
+		}
+		usedNodes[e.From] = true
+		usedNodes[e.To] = true
+		// the call sites are found concurrently, in any order
+		slices.Sort(edgeSites[key])
+		slices.Sort(edgeTips[key])
+		// label edges with their call sites
+		switch sites := edgeSites[key]; edgeLabel {
+		case EdgeLabelFirst:
+			e.Attrs["label"] = sites[0]
+		case EdgeLabelAll:
+			e.Attrs["label"] = strings.Join(uniqueStrings(sites), "\n")
+		}
+		// joined once, as appending to the tooltips is quadratic
+		e.Attrs["tooltip"] = strings.Join(edgeTips[key], "\n")
+		nodeTips[e.From] = append(nodeTips[e.From], e.Attrs["tooltip"])
 		edges = append(edges, e)
 	}
+	for n, tips := range nodeTips {
+		slices.Sort(tips)
+		n.Attrs["tooltip"] += "\n" + strings.Join(tips, "\n")
+	}
+
+	if opts.MinWeight > 1 {
+		nodes = pruneNodes(nodes, usedNodes)
+		pruneCluster(cluster, usedNodes)
+	}
+
+	if heatBy != "" {
+		heatNodes(heatValues(edges, nodeFunc, heatBy, heat), theme.Heat)
+	}
+
+	if len(changed) > 0 {
+		markChanged(changed, edges, theme)
+	}
+	if len(highlighted) > 0 {
+		highlightNodes(highlighted, edges, opts.PrintOptions["highlightpaths"] == "true", theme)
+	}
+	if len(trace) > 0 {
+		if len(traceTargets) == 0 {
+			return nil, fmt.Errorf("trace target %s not in the graph", strings.Join(trace, ", "))
+		}
+		if tracePaths(traceTargets, nodeFunc, edges, theme) == 0 {
+			logger.LogWarn("no call path from the entry points to %s", strings.Join(trace, ", "))
+		}
+	}
+
+	// keep only the most important nodes
+	if opts.MaxNodes > 0 {
+		edges = limitNodes(cluster, edges, nodePkg, opts.MaxNodes, opts.RankBy, theme)
+	}
+	if interfaces {
+		nodes, edges = interfacesView(cluster, nodes, nodeFunc, cg, opts.PrintOptions["interfacecalls"] == "true", theme)
+	}
+	if granularity == GranularityPkg || granularity == GranularityType {
+		nodes, edges = unitView(cluster, nodes, edges, nodeFunc, granularity, theme)
+	}
+	if opts.PrintOptions["imports"] == "true" {
+		nodes, edges = importsView(cluster, nodes, edges, nodeFunc, splitSymbols(opts.PrintOptions["expand"]), theme)
+	}
+
+	fontSize := defaultFontSize
+	if size, err := strconv.ParseFloat(opts.PrintOptions["nodefontsize"], 64); err == nil {
+		fontSize = size
+	}
+	if sizeBy != "" {
+		scaleNodes(nodeMetrics(edges, nodeFunc, sizeBy), fontSize)
+	}
+	if prof != nil {
+		overlayProfile(prof, nodeFunc, edges, edgeCall, theme.Heat, fontSize, heatBy == "", sizeBy == "")
+	}
+	if execTrace != nil {
+		overlayTrace(execTrace, edges, edgeCall, theme)
+	}
+	if len(findings) > 0 {
+		overlayFindings(findings, nodeFunc, theme)
+	}
+	if opts.PrintOptions["otel"] == "true" || spans != nil {
+		overlaySpans(spans, nodeFunc, edges, prof, theme)
+	}
+	if opts.PrintOptions["recursion"] == "true" {
+		overlayRecursion(RecursiveGroups(cg), nodeFunc, edges, theme)
+	}
+	if opts.PrintOptions["panics"] == "true" {
+		overlayPanics(nodeFunc, edges, theme)
+	}
+	if len(opts.Unused) > 0 {
+		overlayUnused(opts.Unused, nodeFunc, theme)
+	}
+
+	decorate(nodeFunc, edges, edgeCall, opts.NodeDecorators, opts.EdgeDecorators)
 
 	logger.LogDebug("%d/%d edges", len(edges), count)
 
@@ -428,19 +919,178 @@ func PrintOutput(
 	if mainPkg != nil && mainPkg.Pkg != nil {
 		title = mainPkg.Pkg.Path()
 	}
-	dot := &dot.DotGraph{
+	printOptions := make(map[string]string)
+	for k, v := range opts.PrintOptions {
+		printOptions[k] = v
+	}
+	if t := opts.PrintOptions["title"]; t != "" {
+		title = t
+		printOptions["labelloc"] = "t"
+	}
+	if printOptions["bgcolor"] == "" {
+		printOptions["bgcolor"] = theme.Background
+	}
+	printOptions["fontname"] = theme.Fonts[ThemeGraph]
+	if printOptions["nodefontname"] == "" {
+		printOptions["nodefontname"] = theme.Fonts[ThemeNode]
+	}
+	printOptions["nodefillcolor"] = theme.Nodes[ThemeGraph]
+
+	for attr, option := range map[string]string{
+		"fontsize":  "clusterfontsize",
+		"labelloc":  "clusterlabelloc",
+		"labeljust": "clusterlabeljust",
+	} {
+		if value := opts.PrintOptions[option]; value != "" {
+			setClusterAttr(cluster, attr, value)
+		}
+	}
+
+	// wrap the graph into a borderless cluster labeled with the footer
+	if footer := opts.PrintOptions["footer"]; footer != "" {
+		outer := dot.NewDotCluster("footer")
+		outer.Attrs = dot.DotAttrs{
+			"label":       footerLabel(footer, reproducible),
+			"labelloc":    "b",
+			"labeljust":   "l",
+			"fontsize":    "10",
+			"fontname":    theme.Fonts[ThemeGraph],
+			"peripheries": "0",
+		}
+		outer.Clusters[cluster.ID] = cluster
+		cluster = outer
+	}
+
+	g := &dot.DotGraph{
 		Title:   title,
-		Minlen:  minlen,
+		Minlen:  opts.Minlen,
 		Cluster: cluster,
 		Nodes:   nodes,
 		Edges:   edges,
-		Options: options,
+		Ranks:   sameRankGroups(sameRank, edges, exportedFocus),
+		Options: printOptions,
 	}
+	// the same input always prints the same, in every format
+	g.Sort()
+	return g, nil
+}
 
-	var buf bytes.Buffer
-	if err := dot.WriteDot(&buf); err != nil {
-		return nil, err
+// footerLabel appends the generation time to the footer. Reproducible
+// graphs take it from SOURCE_DATE_EPOCH, or leave it out if unset.
+func footerLabel(footer string, reproducible bool) string {
+	generated := time.Now()
+	if reproducible {
+		epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+		if err != nil {
+			return footer
+		}
+		generated = time.Unix(epoch, 0).UTC()
 	}
+	return fmt.Sprintf("%s\ngenerated: %s", footer, generated.Format(time.RFC3339))
+}
 
-	return buf.Bytes(), nil
+// footerTimeRe matches the generation time of the footer in DOT, where
+// the newline of the label is escaped.
+var footerTimeRe = regexp.MustCompile(`\\ngenerated: [^"\\]*`)
+
+// StripFooterTime removes the generation time of the footer from the DOT
+// source, so that renders of the same graph at other times compare equal.
+func StripFooterTime(dot []byte) []byte {
+	return footerTimeRe.ReplaceAll(dot, nil)
+}
+
+// pruneNodes keeps only the nodes referenced by an edge.
+func pruneNodes(nodes []*dot.DotNode, used map[*dot.DotNode]bool) []*dot.DotNode {
+	var keep []*dot.DotNode
+	for _, n := range nodes {
+		if used[n] {
+			keep = append(keep, n)
+		}
+	}
+	return keep
+}
+
+// pruneCluster removes unreferenced nodes from the cluster tree and drops
+// clusters left empty.
+func pruneCluster(c *dot.DotCluster, used map[*dot.DotNode]bool) {
+	c.Nodes = pruneNodes(c.Nodes, used)
+	for key, sub := range c.Clusters {
+		pruneCluster(sub, used)
+		if len(sub.Nodes) == 0 && len(sub.Clusters) == 0 {
+			delete(c.Clusters, key)
+		}
+	}
+}
+
+// uniqueStrings returns list without duplicates, keeping the first
+// occurrence of each element.
+func uniqueStrings(list []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// setClusterAttr overrides the attribute key of c and all of its
+// sub-clusters.
+func setClusterAttr(c *dot.DotCluster, key, value string) {
+	c.Attrs[key] = value
+	for _, sub := range c.Clusters {
+		setClusterAttr(sub, key, value)
+	}
+}
+
+// emphasize sets the border color attribute key and, if given, the pen
+// width used to make focused elements stand out. Empty values are skipped.
+func emphasize(attrs dot.DotAttrs, key, color, penwidth string) {
+	if color != "" {
+		attrs[key] = color
+	}
+	if penwidth != "" {
+		attrs["penwidth"] = penwidth
+	}
+}
+
+// sameRankGroups returns the groups of nodes placed on the same rank:
+// either the exported functions of the focused packages, or the entry
+// points of the graph, i.e. nodes without incoming edges.
+func sameRankGroups(mode string, edges []*dot.DotEdge, exportedFocus map[*dot.DotNode]bool) [][]*dot.DotNode {
+	var group []*dot.DotNode
+	switch mode {
+	case SameRankExported:
+		for _, e := range edges {
+			for _, n := range []*dot.DotNode{e.From, e.To} {
+				if exportedFocus[n] && !slices.Contains(group, n) {
+					group = append(group, n)
+				}
+			}
+		}
+	case SameRankEntry:
+		called := make(map[*dot.DotNode]bool)
+		for _, e := range edges {
+			called[e.To] = true
+		}
+		for _, e := range edges {
+			if !called[e.From] && !slices.Contains(group, e.From) {
+				group = append(group, e.From)
+			}
+		}
+	}
+	if len(group) < 2 {
+		return nil
+	}
+	sort.Slice(group, func(i, j int) bool { return group[i].ID < group[j].ID })
+	return [][]*dot.DotNode{group}
+}
+
+// edgeKey identifies the DOT edge of a call. Calls of the same kind between
+// the same nodes share an edge.
+type edgeKey struct {
+	from, to *dot.DotNode
+	desc     string
 }
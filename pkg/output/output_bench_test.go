@@ -0,0 +1,108 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/ssa"
+)
+
+// syntheticGraph builds the call graph of generated packages with edges/10
+// functions calling 10 others each. The functions are spread over packages
+// of 100, as type-checking calls between many functions of one package
+// takes quadratic time.
+func syntheticGraph(b *testing.B, edges int) (*ssa.Program, *callgraph.Graph) {
+	if g, ok := syntheticGraphs[edges]; ok {
+		return g.prog, g.cg
+	}
+
+	const calls, perPkg = 10, 100
+	funcs := edges / calls
+
+	fset := token.NewFileSet()
+	prog := ssa.NewProgram(fset, 0)
+	for p := 0; p*perPkg < funcs; p++ {
+		n := min(perPkg, funcs-p*perPkg)
+		var src strings.Builder
+		fmt.Fprintf(&src, "package bench%d\n", p)
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(&src, "func F%d() {\n", i)
+			for j := 1; j <= calls; j++ {
+				fmt.Fprintf(&src, "\tF%d()\n", (i+j*7919)%n)
+			}
+			src.WriteString("}\n")
+		}
+
+		f, err := parser.ParseFile(fset, fmt.Sprintf("bench%d.go", p), src.String(), 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		info := &types.Info{
+			Types:      make(map[ast.Expr]types.TypeAndValue),
+			Defs:       make(map[*ast.Ident]types.Object),
+			Uses:       make(map[*ast.Ident]types.Object),
+			Implicits:  make(map[ast.Node]types.Object),
+			Selections: make(map[*ast.SelectorExpr]*types.Selection),
+			Scopes:     make(map[ast.Node]*types.Scope),
+		}
+		path := fmt.Sprintf("example.com/bench%d", p)
+		pkg, err := new(types.Config).Check(path, fset, []*ast.File{f}, info)
+		if err != nil {
+			b.Fatal(err)
+		}
+		prog.CreatePackage(pkg, []*ast.File{f}, info, true)
+	}
+	prog.Build()
+
+	cg := static.CallGraph(prog)
+	cg.DeleteSyntheticNodes()
+	syntheticGraphs[edges] = syntheticProgram{prog, cg}
+	return prog, cg
+}
+
+type syntheticProgram struct {
+	prog *ssa.Program
+	cg   *callgraph.Graph
+}
+
+// syntheticGraphs keeps the generated graphs by their number of edges, as
+// the benchmarks run repeatedly.
+var syntheticGraphs = make(map[int]syntheticProgram)
+
+func BenchmarkWriteOutput(b *testing.B) {
+	logger.InitializeLogger(logger.ErrorLevel)
+	for _, edges := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("edges=%d", edges), func(b *testing.B) {
+			prog, cg := syntheticGraph(b, edges)
+			opts := &Options{
+				GroupBy: []string{"pkg"},
+				Theme:   DefaultTheme(),
+				Minlen:  2,
+				PrintOptions: map[string]string{
+					"minlen":    "2",
+					"nodesep":   "0.35",
+					"nodeshape": "box",
+					"nodestyle": "filled,rounded",
+					"rankdir":   "LR",
+				},
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := WriteOutput(context.Background(), io.Discard, prog, nil, cg, nil, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
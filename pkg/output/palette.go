@@ -0,0 +1,132 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// palettes maps palette names to the theme they produce.
+var palettes = map[string]func() *Theme{
+	"default":   DefaultTheme,
+	"okabe-ito": okabeItoTheme,
+	"grayscale": grayscaleTheme,
+}
+
+// PaletteNames returns the names of all built-in palettes.
+func PaletteNames() []string {
+	var names []string
+	for name := range palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PaletteTheme returns the theme of the named palette.
+func PaletteTheme(name string) (*Theme, error) {
+	if name == "" {
+		return DefaultTheme(), nil
+	}
+	palette, ok := palettes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown palette %q, valid palettes: %s", name, strings.Join(PaletteNames(), ", "))
+	}
+	return palette(), nil
+}
+
+// okabeItoTheme uses the colorblind-safe palette by Okabe and Ito, with
+// lightened tints for fills so labels stay readable.
+func okabeItoTheme() *Theme {
+	theme := DefaultTheme()
+	theme.Background = "#f2f2f2"
+	theme.Clusters[ThemeRoot] = "#ffffff"
+	theme.Clusters[ThemeFocus] = "#d6ecf9"    // sky blue
+	theme.Clusters[ThemeFocusBdr] = "#0072B2" // blue
+	theme.Clusters[ThemePkg] = "#fbf8d0"      // yellow
+	theme.Clusters[ThemeStd] = "#ccebe2"      // bluish green
+	theme.Clusters[ThemeType] = "#f8e2b3"     // orange
+	theme.Clusters[ThemeTypeFocus] = "#abd9f4"
+	theme.Clusters[ThemeTypeStd] = "#99d8c7"
+	theme.Clusters[ThemeTest] = "#f2dbe9" // reddish purple
+	theme.Nodes[ThemeGraph] = "#ffffff"
+	theme.Nodes[ThemeDefault] = "#f5c866"
+	theme.Nodes[ThemeFocus] = "#56B4E9"
+	theme.Nodes[ThemeFocusBdr] = "#0072B2"
+	theme.Nodes[ThemeStd] = "#66c4aa"
+	theme.Nodes[ThemeTest] = "#e0afcd"
+	theme.Nodes[ThemeHighlight] = "#F0E442"
+	theme.Nodes[ThemeHighlightBdr] = "#D55E00"
+	theme.Nodes[ThemeChanged] = "#CC79A7" // reddish purple
+	theme.Nodes[ThemeChangedBdr] = "#7a3d63"
+	theme.Nodes[ThemeAdded] = "#99d8c7"   // bluish green
+	theme.Nodes[ThemeRemoved] = "#f2b9a3" // vermillion
+	theme.Nodes[ThemeFinding] = "#D55E00"
+	theme.Nodes[ThemeTraced] = "#0072B2"
+	theme.Nodes[ThemeUntraced] = "#E69F00"
+	theme.Nodes[ThemeRecursive] = "#009E73"
+	theme.Nodes[ThemePath] = "#56B4E9" // sky blue
+	theme.Nodes[ThemePanic] = "#D55E00"
+	theme.Nodes[ThemeRecover] = "#009E73"
+	theme.Edges[ThemeOutside] = "#E69F00"
+	theme.Edges[ThemeBetween] = "#D55E00"
+	theme.Edges[ThemeHighlight] = "#D55E00"
+	theme.Edges[ThemeExercised] = "#009E73" // bluish green
+	theme.Edges[ThemeChanged] = "#CC79A7"
+	theme.Edges[ThemeAdded] = "#009E73"
+	theme.Edges[ThemeRemoved] = "#D55E00"
+	theme.Edges[ThemeTraced] = "#0072B2"
+	theme.Edges[ThemeRecursive] = "#009E73"
+	theme.Edges[ThemePath] = "#56B4E9"
+	theme.Edges[ThemePanic] = "#D55E00"
+	theme.Edges[ThemeDimmed] = "#bbbbbb"
+	return theme
+}
+
+// grayscaleTheme renders without any hue, e.g. for printing.
+func grayscaleTheme() *Theme {
+	theme := DefaultTheme()
+	theme.Background = "#e0e0e0"
+	theme.Clusters[ThemeRoot] = "#ffffff"
+	theme.Clusters[ThemeFocus] = "#d0d0d0"
+	theme.Clusters[ThemeFocusBdr] = "#000000"
+	theme.Clusters[ThemePkg] = "#f4f4f4"
+	theme.Clusters[ThemeStd] = "#e8e8e8"
+	theme.Clusters[ThemeType] = "#dcdcdc"
+	theme.Clusters[ThemeTypeFocus] = "#bdbdbd"
+	theme.Clusters[ThemeTypeStd] = "#d0d0d0"
+	theme.Clusters[ThemeTest] = "#ececec"
+	theme.Nodes[ThemeGraph] = "#ffffff"
+	theme.Nodes[ThemeDefault] = "#ffffff"
+	theme.Nodes[ThemeFocus] = "#bdbdbd"
+	theme.Nodes[ThemeFocusBdr] = "#000000"
+	theme.Nodes[ThemeStd] = "#e0e0e0"
+	theme.Nodes[ThemeTest] = "#d6d6d6"
+	theme.Nodes[ThemeHighlight] = "#757575"
+	theme.Nodes[ThemeHighlightBdr] = "#000000"
+	theme.Nodes[ThemeChanged] = "#9e9e9e"
+	theme.Nodes[ThemeChangedBdr] = "#000000"
+	theme.Nodes[ThemeAdded] = "#9e9e9e"
+	theme.Nodes[ThemeRemoved] = "#f5f5f5"
+	theme.Nodes[ThemeFinding] = "#000000"
+	theme.Nodes[ThemeTraced] = "#000000"
+	theme.Nodes[ThemeUntraced] = "#9e9e9e"
+	theme.Nodes[ThemeRecursive] = "#000000"
+	theme.Nodes[ThemePath] = "#000000"
+	theme.Nodes[ThemePanic] = "#000000"
+	theme.Nodes[ThemeRecover] = "#757575"
+	theme.Edges[ThemeOutside] = "#616161"
+	theme.Edges[ThemeBetween] = "#000000"
+	theme.Edges[ThemeHighlight] = "#000000"
+	theme.Edges[ThemeExercised] = "#000000"
+	theme.Edges[ThemeChanged] = "#000000"
+	theme.Edges[ThemeAdded] = "#000000"
+	theme.Edges[ThemeRemoved] = "#9e9e9e"
+	theme.Edges[ThemeTraced] = "#000000"
+	theme.Edges[ThemeRecursive] = "#000000"
+	theme.Edges[ThemePath] = "#000000"
+	theme.Edges[ThemePanic] = "#000000"
+	theme.Edges[ThemeDimmed] = "#bdbdbd"
+	theme.Heat = []string{"#f5f5f5", "#9e9e9e", "#212121"}
+	return theme
+}
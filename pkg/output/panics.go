@@ -0,0 +1,147 @@
+package output
+
+import (
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// panics reports whether fn calls panic.
+func panics(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if _, ok := instr.(*ssa.Panic); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// callsRecover reports whether fn calls recover.
+func callsRecover(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			if call, ok := instr.(*ssa.Call); ok {
+				if b, ok := call.Call.Value.(*ssa.Builtin); ok && b.Name() == "recover" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// recovers reports whether fn stops the panics of its callees, i.e. it
+// defers a function calling recover.
+func recovers(fn *ssa.Function) bool {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			d, ok := instr.(*ssa.Defer)
+			if !ok {
+				continue
+			}
+			callee := d.Call.StaticCallee()
+			if c, ok := d.Call.Value.(*ssa.MakeClosure); ok {
+				callee, _ = c.Fn.(*ssa.Function)
+			}
+			if callee != nil && callsRecover(callee) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlayPanics badges the nodes of the functions calling panic and of
+// those calling recover or deferring a function which does, and colors the
+// call paths from the exported functions to the panics not recovered on
+// the way: a function recovering stops the panics of the functions it
+// calls, as well as its own.
+func overlayPanics(nodeFunc map[*dot.DotNode]*ssa.Function, edges []*dot.DotEdge, theme *Theme) {
+	panicking := make(map[*dot.DotNode]bool)
+	recovering := make(map[*dot.DotNode]bool)
+	for n, fn := range nodeFunc {
+		switch {
+		case recovers(fn):
+			recovering[n] = true
+			badge(n, "recover")
+			n.Attrs["color"] = theme.Nodes[ThemeRecover]
+			n.Attrs["penwidth"] = "2"
+			n.Attrs["tooltip"] += "\nrecovers the panics of its callees"
+		case callsRecover(fn):
+			badge(n, "recover")
+			n.Attrs["tooltip"] += "\ncalls recover"
+		}
+		if panics(fn) {
+			panicking[n] = true
+			badge(n, "panic")
+			if !recovering[n] {
+				n.Attrs["color"] = theme.Nodes[ThemePanic]
+				n.Attrs["penwidth"] = "2"
+			}
+			n.Attrs["tooltip"] += "\ncalls panic"
+		}
+	}
+
+	// the callers of unrecovered panics, up to the functions recovering
+	callers := make(map[*dot.DotNode][]*dot.DotEdge)
+	for _, e := range edges {
+		callers[e.To] = append(callers[e.To], e)
+	}
+	reaches := make(map[*dot.DotNode]bool)
+	var queue []*dot.DotNode
+	for n := range panicking {
+		if !recovering[n] {
+			reaches[n] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range callers[n] {
+			if c := e.From; !reaches[c] && !recovering[c] {
+				reaches[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	// the paths start at the exported functions reaching them
+	callees := make(map[*dot.DotNode][]*dot.DotEdge)
+	for _, e := range edges {
+		callees[e.From] = append(callees[e.From], e)
+	}
+	onPath := make(map[*dot.DotNode]bool)
+	for n := range reaches {
+		if isExportedFunc(nodeFunc[n]) {
+			onPath[n] = true
+			queue = append(queue, n)
+			n.Attrs["tooltip"] += "\ncan panic without recovering"
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range callees[n] {
+			if !reaches[e.To] {
+				continue
+			}
+			e.Attrs["color"] = theme.Edges[ThemePanic]
+			e.Attrs["penwidth"] = "2"
+			if !onPath[e.To] {
+				onPath[e.To] = true
+				queue = append(queue, e.To)
+			}
+		}
+	}
+}
+
+// badge appends label to the external label of n.
+func badge(n *dot.DotNode, label string) {
+	if x := n.Attrs["xlabel"]; x != "" {
+		n.Attrs["xlabel"] = x + " " + label
+	} else {
+		n.Attrs["xlabel"] = label
+	}
+}
@@ -0,0 +1,47 @@
+package output
+
+import (
+	"runtime"
+	"sync"
+
+	"golang.org/x/tools/go/callgraph"
+)
+
+// minParallelEdges is the number of edges from which filtering is spread
+// over multiple goroutines.
+const minParallelEdges = 4096
+
+// filterEdges returns the edges for which keep returns true, in their
+// original order. Large graphs are split into one chunk per CPU filtered
+// concurrently, so keep must be safe for concurrent use.
+func filterEdges(edges []*callgraph.Edge, keep func(*callgraph.Edge) bool) []*callgraph.Edge {
+	kept := make([]bool, len(edges))
+	workers := runtime.GOMAXPROCS(0)
+	if len(edges) < minParallelEdges || workers == 1 {
+		for i, e := range edges {
+			kept[i] = keep(e)
+		}
+	} else {
+		chunk := (len(edges) + workers - 1) / workers
+		var wg sync.WaitGroup
+		for start := 0; start < len(edges); start += chunk {
+			end := min(start+chunk, len(edges))
+			wg.Add(1)
+			go func(start, end int) {
+				defer wg.Done()
+				for i := start; i < end; i++ {
+					kept[i] = keep(edges[i])
+				}
+			}(start, end)
+		}
+		wg.Wait()
+	}
+
+	var result []*callgraph.Edge
+	for i, e := range edges {
+		if kept[i] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
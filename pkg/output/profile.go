@@ -0,0 +1,150 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/google/pprof/profile"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// cpuProfile holds the samples of a pprof CPU profile, cumulated by
+// function and by call between two functions, keyed by the names the
+// runtime uses, see runtimeName.
+type cpuProfile struct {
+	funcs map[string]int64
+	calls map[[2]string]int64
+}
+
+// loadCPUProfile reads a pprof CPU profile, counting the value of the last
+// sample type, e.g. cpu/nanoseconds.
+func loadCPUProfile(path string) (*cpuProfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CPU profile %s: %v", path, err)
+	}
+
+	prof := &cpuProfile{
+		funcs: make(map[string]int64),
+		calls: make(map[[2]string]int64),
+	}
+	for _, s := range p.Sample {
+		value := s.Value[len(s.Value)-1]
+		// the frames from the leaf up, inlined functions included
+		var frames []string
+		for _, loc := range s.Location {
+			for _, line := range loc.Line {
+				if line.Function != nil {
+					frames = append(frames, elideTypeArgs(line.Function.Name))
+				}
+			}
+		}
+		// recursive functions and calls count once per sample
+		seenFuncs := make(map[string]bool)
+		seenCalls := make(map[[2]string]bool)
+		for i, name := range frames {
+			if !seenFuncs[name] {
+				seenFuncs[name] = true
+				prof.funcs[name] += value
+			}
+			if i+1 < len(frames) {
+				call := [2]string{frames[i+1], name}
+				if !seenCalls[call] {
+					seenCalls[call] = true
+					prof.calls[call] += value
+				}
+			}
+		}
+	}
+	return prof, nil
+}
+
+// funcSamples returns the cumulative samples of fn, or false if fn is not
+// part of the profile.
+func (p *cpuProfile) funcSamples(fn *ssa.Function) (int64, bool) {
+	if fn.Pkg == nil {
+		return 0, false
+	}
+	s, ok := p.funcs[runtimeName(fn)]
+	return s, ok
+}
+
+// callSamples returns the samples of the calls from caller to callee.
+func (p *cpuProfile) callSamples(caller, callee *ssa.Function) int64 {
+	if caller.Pkg == nil || callee.Pkg == nil {
+		return 0
+	}
+	return p.calls[[2]string{runtimeName(caller), runtimeName(callee)}]
+}
+
+// overlayProfile merges the runtime cost of the profile into the graph:
+// nodes are colored along the theme's heat gradient and scaled by their
+// samples, unless recolor or resize are false as other metrics drive them;
+// edges are colored and thickened by the samples of their calls. Nodes and
+// edges without samples are left as they are.
+func overlayProfile(
+	prof *cpuProfile,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	edges []*dot.DotEdge,
+	edgeCall map[*dot.DotEdge]*callgraph.Edge,
+	gradient []string,
+	fontSize float64,
+	recolor, resize bool,
+) {
+	nodeValues := make(map[*dot.DotNode]float64)
+	for n, fn := range nodeFunc {
+		if s, ok := prof.funcSamples(fn); ok && s > 0 {
+			nodeValues[n] = float64(s)
+			n.Attrs["tooltip"] += fmt.Sprintf("\nsamples: %d", s)
+		}
+	}
+	shares := shareOfMax(nodeValues)
+	if recolor {
+		heatNodes(shares, gradient)
+	}
+	if resize {
+		resizeNodes(shares, fontSize)
+	}
+
+	edgeValues := make(map[*dot.DotEdge]float64)
+	for _, e := range edges {
+		call, ok := edgeCall[e]
+		if !ok {
+			continue
+		}
+		if s := prof.callSamples(call.Caller.Func, call.Callee.Func); s > 0 {
+			edgeValues[e] = float64(s)
+			e.Attrs["tooltip"] += fmt.Sprintf("\nsamples: %d", s)
+		}
+	}
+	for e, t := range shareOfMax(edgeValues) {
+		if color, ok := gradientColor(gradient, t); ok {
+			e.Attrs["color"] = color
+		}
+		e.Attrs["penwidth"] = strconv.FormatFloat(1+4*t, 'f', 2, 64)
+	}
+}
+
+// shareOfMax maps the samples onto [0, 1] relative to the largest, using a
+// square root scale like normalize. Unlike normalize, functions taking
+// about as long as the hottest one are hot too.
+func shareOfMax[K comparable](values map[K]float64) map[K]float64 {
+	hi := 0.0
+	for _, v := range values {
+		hi = max(hi, v)
+	}
+	shares := make(map[K]float64, len(values))
+	for k, v := range values {
+		shares[k] = math.Sqrt(v / hi)
+	}
+	return shares
+}
@@ -0,0 +1,130 @@
+package output
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// maxRecursionPeers is the number of the other functions of a recursive
+// group named in the tooltip of its nodes.
+const maxRecursionPeers = 5
+
+// RecursiveGroups returns the groups of functions of cg calling each other
+// in a cycle, i.e. its strongly connected components of more than one
+// function, and the functions calling themselves, alone. The functions of
+// each group and the groups are sorted by name.
+func RecursiveGroups(cg *callgraph.Graph) [][]*ssa.Function {
+	// Tarjan's algorithm, visiting the nodes in order of their function
+	// names for a stable order of the components
+	var nodes []*callgraph.Node
+	for fn, n := range cg.Nodes {
+		if fn != nil {
+			nodes = append(nodes, n)
+		}
+	}
+	slices.SortFunc(nodes, func(a, b *callgraph.Node) int { return cmp.Compare(a.Func.String(), b.Func.String()) })
+
+	index := make(map[*callgraph.Node]int)
+	low := make(map[*callgraph.Node]int)
+	onStack := make(map[*callgraph.Node]bool)
+	var stack []*callgraph.Node
+	var groups [][]*ssa.Function
+
+	var visit func(n *callgraph.Node)
+	visit = func(n *callgraph.Node) {
+		index[n] = len(index)
+		low[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		self := false
+		for _, e := range n.Out {
+			m := e.Callee
+			if m == n {
+				self = true
+			}
+			if _, ok := index[m]; !ok {
+				visit(m)
+				low[n] = min(low[n], low[m])
+			} else if onStack[m] {
+				low[n] = min(low[n], index[m])
+			}
+		}
+		if low[n] != index[n] {
+			return
+		}
+		var group []*ssa.Function
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			group = append(group, m.Func)
+			if m == n {
+				break
+			}
+		}
+		if len(group) > 1 || self {
+			slices.SortFunc(group, func(a, b *ssa.Function) int { return cmp.Compare(a.String(), b.String()) })
+			groups = append(groups, group)
+		}
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	slices.SortFunc(groups, func(a, b []*ssa.Function) int { return cmp.Compare(a[0].String(), b[0].String()) })
+	return groups
+}
+
+// overlayRecursion badges the nodes of the recursive functions, naming the
+// other functions of their group in the tooltip, and colors the calls
+// within the groups.
+func overlayRecursion(groups [][]*ssa.Function, nodeFunc map[*dot.DotNode]*ssa.Function, edges []*dot.DotEdge, theme *Theme) {
+	groupOf := make(map[*ssa.Function][]*ssa.Function)
+	for _, g := range groups {
+		for _, fn := range g {
+			groupOf[fn] = g
+		}
+	}
+
+	for n, fn := range nodeFunc {
+		group, ok := groupOf[fn]
+		if !ok {
+			continue
+		}
+		badge(n, "↻")
+		n.Attrs["color"] = theme.Nodes[ThemeRecursive]
+		n.Attrs["penwidth"] = "2"
+		n.Attrs["tooltip"] += "\n" + recursionNote(fn, group)
+	}
+	for _, e := range edges {
+		from, to := nodeFunc[e.From], nodeFunc[e.To]
+		if group, ok := groupOf[from]; ok && slices.Contains(group, to) {
+			e.Attrs["color"] = theme.Edges[ThemeRecursive]
+		}
+	}
+}
+
+// recursionNote describes the recursion of fn, a function of group.
+func recursionNote(fn *ssa.Function, group []*ssa.Function) string {
+	if len(group) == 1 {
+		return "recursive"
+	}
+	var peers []string
+	for _, other := range group {
+		if other != fn && len(peers) < maxRecursionPeers {
+			peers = append(peers, other.RelString(fn.Pkg.Pkg))
+		}
+	}
+	note := "mutually recursive with " + strings.Join(peers, ", ")
+	if more := len(group) - 1 - len(peers); more > 0 {
+		note += fmt.Sprintf(" and %d more", more)
+	}
+	return note
+}
@@ -0,0 +1,202 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"go/constant"
+	"go/types"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+	"gopkg.in/yaml.v3"
+)
+
+// Packages of the OpenTelemetry API recognized as starting spans.
+const (
+	otelTracePkg = "go.opentelemetry.io/otel/trace"
+	otelHTTPPkg  = "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// spanMap maps span names to the functions starting them, as symbols
+// separated by comma, see matchSymbol.
+type spanMap struct {
+	Spans map[string]string `yaml:"spans"`
+}
+
+// loadSpanMap reads a YAML file mapping span names to functions.
+func loadSpanMap(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m spanMap
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid span mapping %s: %v", path, err)
+	}
+	spans := make(map[string][]string, len(m.Spans))
+	for name, syms := range m.Spans {
+		spans[name] = splitSymbols(syms)
+	}
+	return spans, nil
+}
+
+// conventionalSpans returns the spans started by fn following the
+// conventions of OpenTelemetry, by the functions starting them: fn itself
+// when it calls Tracer.Start, and the handlers it wraps with otelhttp.
+// Span names are known when given as constants.
+func conventionalSpans(fn *ssa.Function) map[*ssa.Function][]string {
+	spans := make(map[*ssa.Function][]string)
+	add := func(fn *ssa.Function, name ssa.Value) {
+		if fn == nil {
+			return
+		}
+		s := "?"
+		if c, ok := name.(*ssa.Const); ok && c.Value != nil && c.Value.Kind() == constant.String {
+			s = constant.StringVal(c.Value)
+		}
+		if !slices.Contains(spans[fn], s) {
+			spans[fn] = append(spans[fn], s)
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(ssa.CallInstruction)
+			if !ok {
+				continue
+			}
+			common := call.Common()
+			if common.IsInvoke() {
+				// tracer.Start(ctx, name, ...)
+				if common.Method.Name() == "Start" && isNamedType(common.Value.Type(), otelTracePkg, "Tracer") && len(common.Args) > 1 {
+					add(fn, common.Args[1])
+				}
+				continue
+			}
+			callee := common.StaticCallee()
+			if callee == nil || callee.Pkg == nil || callee.Pkg.Pkg.Path() != otelHTTPPkg {
+				continue
+			}
+			switch args := common.Args; {
+			case callee.Name() == "NewHandler" && len(args) > 1:
+				// otelhttp.NewHandler(handler, operation, ...)
+				add(handlerFunc(args[0]), args[1])
+			case callee.Name() == "WithRouteTag" && len(args) > 1:
+				// otelhttp.WithRouteTag(route, handler)
+				add(handlerFunc(args[1]), args[0])
+			}
+		}
+	}
+	return spans
+}
+
+// handlerFunc returns the function of an http.Handler made of a function,
+// like http.HandlerFunc(f), or nil.
+func handlerFunc(v ssa.Value) *ssa.Function {
+	for {
+		switch x := v.(type) {
+		case *ssa.MakeInterface:
+			v = x.X
+		case *ssa.ChangeType:
+			v = x.X
+		case *ssa.MakeClosure:
+			v = x.Fn
+		case *ssa.Function:
+			return x
+		default:
+			return nil
+		}
+	}
+}
+
+func isNamedType(t types.Type, pkg, name string) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkg && obj.Name() == name
+}
+
+// overlaySpans shows the tracing coverage: the functions starting spans,
+// by convention or as mapped by spans, are outlined as traced with their
+// span names, like the calls made within spans. The functions running
+// outside of all spans are outlined as untraced, only the hot ones if a
+// profile is given.
+func overlaySpans(
+	spans map[string][]string,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	edges []*dot.DotEdge,
+	prof *cpuProfile,
+	theme *Theme,
+) {
+	started := make(map[*dot.DotNode][]string)
+	funcNode := make(map[*ssa.Function]*dot.DotNode, len(nodeFunc))
+	for n, fn := range nodeFunc {
+		funcNode[fn] = n
+	}
+	for n, fn := range nodeFunc {
+		for name, syms := range spans {
+			if matchSymbols(fn, syms) {
+				started[n] = append(started[n], name)
+			}
+		}
+		for target, names := range conventionalSpans(fn) {
+			if tn, ok := funcNode[target]; ok {
+				started[tn] = append(started[tn], names...)
+			}
+		}
+	}
+
+	// the functions called within spans, directly or not
+	callees := make(map[*dot.DotNode][]*dot.DotNode)
+	for _, e := range edges {
+		callees[e.From] = append(callees[e.From], e.To)
+	}
+	covered := make(map[*dot.DotNode]bool)
+	var queue []*dot.DotNode
+	for n := range started {
+		covered[n] = true
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, c := range callees[n] {
+			if !covered[c] {
+				covered[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	for _, e := range edges {
+		if covered[e.From] {
+			e.Attrs["color"] = theme.Edges[ThemeTraced]
+		}
+	}
+	for n, fn := range nodeFunc {
+		if names, ok := started[n]; ok {
+			slices.Sort(names)
+			n.Attrs["color"] = theme.Nodes[ThemeTraced]
+			n.Attrs["penwidth"] = "2"
+			n.Attrs["tooltip"] += "\nspans: " + strings.Join(slices.Compact(names), ", ")
+			continue
+		}
+		if covered[n] {
+			continue
+		}
+		if prof != nil {
+			if s, _ := prof.funcSamples(fn); s == 0 {
+				continue
+			}
+		}
+		n.Attrs["color"] = theme.Nodes[ThemeUntraced]
+		n.Attrs["penwidth"] = "2"
+		n.Attrs["tooltip"] += "\nnot traced"
+	}
+}
@@ -0,0 +1,46 @@
+package output
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+var testRootPrefixes = []string{"Test", "Benchmark", "Example", "Fuzz"}
+
+// isTestFunc reports whether fn is test code, i.e. it is declared in a
+// _test.go file or belongs to a generated test main package.
+func isTestFunc(fn *ssa.Function) bool {
+	if fn == nil || fn.Pkg == nil {
+		return false
+	}
+	if strings.HasSuffix(fn.Pkg.Pkg.Path(), ".test") {
+		return true
+	}
+	if fn.Prog == nil || !fn.Pos().IsValid() {
+		return false
+	}
+	return strings.HasSuffix(fn.Prog.Fset.Position(fn.Pos()).Filename, "_test.go")
+}
+
+// isTestRoot reports whether fn is a test, benchmark, example or fuzz
+// function that the test runner calls directly.
+func isTestRoot(fn *ssa.Function) bool {
+	if !isTestFunc(fn) || fn.Parent() != nil || fn.Signature.Recv() != nil {
+		return false
+	}
+	if strings.HasSuffix(fn.Pkg.Pkg.Path(), ".test") {
+		return false
+	}
+	for _, p := range testRootPrefixes {
+		if strings.HasPrefix(fn.Name(), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestHelper reports whether fn is test code that is not a test root.
+func isTestHelper(fn *ssa.Function) bool {
+	return isTestFunc(fn) && !isTestRoot(fn)
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Theme controls the colors and fonts of the generated graph. Every map is
+// keyed by the kind of element it styles, see the Theme* constants.
+type Theme struct {
+	Background string            `json:"background"`
+	Fonts      map[string]string `json:"fonts"`
+	FontColors map[string]string `json:"fontColors"`
+	Clusters   map[string]string `json:"clusters"`
+	Nodes      map[string]string `json:"nodes"`
+	Edges      map[string]string `json:"edges"`
+	// EdgeStyles holds Graphviz edge attributes (color, penwidth, arrowhead,
+	// style, ...) per call kind, see the Edge* constants and ThemeCrossPkg.
+	EdgeStyles map[string]map[string]string `json:"edgeStyles"`
+	// Shapes holds the node shape per function kind, see the Func*
+	// constants. Kinds without a shape use the -nodeshape default.
+	Shapes map[string]string `json:"shapes"`
+	// Heat holds the #rrggbb color stops of the heatmap gradient, from cold
+	// to hot.
+	Heat []string `json:"heat"`
+}
+
+// Keys of the Theme maps.
+const (
+	ThemeGraph        = "graph"
+	ThemeNode         = "node"
+	ThemeCluster      = "cluster"
+	ThemeRoot         = "root"
+	ThemeFocus        = "focus"
+	ThemeFocusBdr     = "focusBorder"
+	ThemeHighlight    = "highlight"
+	ThemeHighlightBdr = "highlightBorder"
+	ThemeStd          = "std"
+	ThemePkg          = "pkg"
+	ThemeType         = "type"
+	ThemeTypeFocus    = "typeFocus"
+	ThemeTypeStd      = "typeStd"
+	ThemeTest         = "test"
+	ThemeDimmed       = "dimmed"
+	ThemeElided       = "elided"
+	ThemeDefault      = "default"
+	ThemeOutside      = "outside"
+	ThemeBetween      = "betweenFocus"
+	ThemeCrossPkg     = "crossPackage"
+	ThemeExercised    = "exercised"
+	ThemeChanged      = "changed"
+	ThemeChangedBdr   = "changedBorder"
+	ThemeAdded        = "added"
+	ThemeRemoved      = "removed"
+	ThemeFinding      = "finding"
+	ThemeTraced       = "traced"
+	ThemeUntraced     = "untraced"
+	ThemeRecursive    = "recursive"
+	ThemePath         = "path"
+	ThemePanic        = "panic"
+	ThemeRecover      = "recover"
+)
+
+// DefaultTheme returns the built-in theme.
+func DefaultTheme() *Theme {
+	return &Theme{
+		Background: "lightgray",
+		Fonts: map[string]string{
+			ThemeGraph:   "Arial",
+			ThemeNode:    "Verdana",
+			ThemeCluster: "Tahoma bold",
+		},
+		FontColors: map[string]string{
+			ThemeType:   "#222222",
+			ThemeDimmed: "gray45",
+			ThemeElided: "gray30",
+		},
+		Clusters: map[string]string{
+			ThemeRoot:      "white",
+			ThemeFocus:     "#e6ecfa",
+			ThemePkg:       "lightyellow",
+			ThemeStd:       "#E0FFE1",
+			ThemeType:      "wheat2",
+			ThemeTypeFocus: "lightsteelblue",
+			ThemeTypeStd:   "#c2e3c2",
+			ThemeTest:      "#f3e6f5",
+			ThemeFocusBdr:  "#4a74c9",
+			ThemeDimmed:    "#f4f4f4",
+		},
+		Nodes: map[string]string{
+			ThemeGraph:        "honeydew",
+			ThemeDefault:      "moccasin",
+			ThemeFocus:        "lightblue",
+			ThemeFocusBdr:     "#2b5fb8",
+			ThemeHighlight:    "#ffd54f",
+			ThemeHighlightBdr: "#e65100",
+			ThemeChanged:      "#ffb3ae",
+			ThemeChangedBdr:   "#cf222e",
+			ThemeAdded:        "#aceebb",
+			ThemeRemoved:      "#ffcecb",
+			ThemeFinding:      "#d1242f",
+			ThemeTraced:       "#8250df",
+			ThemeUntraced:     "#bf8700",
+			ThemeRecursive:    "#0a7ea4",
+			ThemePath:         "#d63384",
+			ThemePanic:        "#b31d28",
+			ThemeRecover:      "#2da44e",
+			ThemeStd:          "#adedad",
+			ThemeTest:         "thistle",
+			ThemeDimmed:       "#eeeeee",
+			ThemeElided:       "#dddddd",
+		},
+		Edges: map[string]string{
+			ThemeOutside:   "saddlebrown",
+			ThemeBetween:   "crimson",
+			ThemeElided:    "gray40",
+			ThemeHighlight: "#e65100",
+			ThemeExercised: "#1a7f37",
+			ThemeChanged:   "#cf222e",
+			ThemeAdded:     "#1a7f37",
+			ThemeRemoved:   "#cf222e",
+			ThemeTraced:    "#8250df",
+			ThemeRecursive: "#0a7ea4",
+			ThemePath:      "#d63384",
+			ThemePanic:     "#b31d28",
+			ThemeDimmed:    "gray65",
+		},
+		EdgeStyles: map[string]map[string]string{
+			EdgeDynamic: {"style": "dashed"},
+			EdgeGo:      {"arrowhead": "normalnoneodot"},
+			EdgeDefer:   {"arrowhead": "normalnoneodiamond"},
+		},
+		Shapes: map[string]string{
+			FuncMethod:    "component",
+			FuncClosure:   "ellipse",
+			FuncInit:      "hexagon",
+			FuncSynthetic: "octagon",
+		},
+		Heat: []string{"#ffffb2", "#fd8d3c", "#bd0026"},
+	}
+}
+
+// LoadTheme reads a JSON theme file on top of base. Settings missing from
+// the file keep the values of base.
+func LoadTheme(path string, base *Theme) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, base); err != nil {
+		return nil, fmt.Errorf("invalid theme file %s: %v", path, err)
+	}
+	return base, nil
+}
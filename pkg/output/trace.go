@@ -0,0 +1,152 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/exp/trace"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// execTrace counts the calls seen in the stacks of the events of a runtime
+// execution trace, keyed by the names the runtime uses, see runtimeName.
+type execTrace struct {
+	calls map[[2]string]int64
+}
+
+// loadExecTrace reads an execution trace written by runtime/trace or
+// `go test -trace`, as read by `go tool trace`.
+func loadExecTrace(path string) (*execTrace, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := trace.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution trace %s: %v", path, err)
+	}
+
+	t := &execTrace{calls: make(map[[2]string]int64)}
+	// events often share stacks, which are split into calls once
+	stackCalls := make(map[trace.Stack][][2]string)
+	count := func(stk trace.Stack) {
+		if stk == trace.NoStack {
+			return
+		}
+		calls, ok := stackCalls[stk]
+		if !ok {
+			calls = callsOf(stk)
+			stackCalls[stk] = calls
+		}
+		for _, call := range calls {
+			t.calls[call]++
+		}
+	}
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid execution trace %s: %v", path, err)
+		}
+		count(ev.Stack())
+		if ev.Kind() != trace.EventStateTransition {
+			continue
+		}
+		// goroutine creations also carry the stack of the new goroutine,
+		// started by a go statement of the creating function
+		st := ev.StateTransition()
+		if st.Stack == ev.Stack() {
+			continue
+		}
+		count(st.Stack)
+		if st.Resource.Kind != trace.ResourceGoroutine {
+			continue
+		}
+		if from, to := st.Goroutine(); from == trace.GoNotExist && to == trace.GoRunnable {
+			creator, start := frames(ev.Stack()), frames(st.Stack)
+			if len(creator) > 0 && len(start) > 0 {
+				t.calls[[2]string{creator[0], start[len(start)-1]}]++
+			}
+		}
+	}
+	return t, nil
+}
+
+// frames returns the function names of the frames of stk, from the leaf
+// up.
+func frames(stk trace.Stack) []string {
+	var names []string
+	stk.Frames(func(f trace.StackFrame) bool {
+		names = append(names, elideTypeArgs(f.Func))
+		return true
+	})
+	return names
+}
+
+// callsOf returns the distinct calls between the frames of stk, as caller
+// and callee names.
+func callsOf(stk trace.Stack) [][2]string {
+	names := frames(stk)
+	seen := make(map[[2]string]bool)
+	var calls [][2]string
+	for i := 0; i+1 < len(names); i++ {
+		call := [2]string{names[i+1], names[i]}
+		if !seen[call] {
+			seen[call] = true
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// callCount returns the number of events with stacks containing calls
+// from caller to callee.
+func (t *execTrace) callCount(caller, callee *ssa.Function) int64 {
+	if caller.Pkg == nil || callee.Pkg == nil {
+		return 0
+	}
+	return t.calls[[2]string{runtimeName(caller), runtimeName(callee)}]
+}
+
+// overlayTrace distinguishes the real call paths from the theoretical ones:
+// the calls seen in the trace are colored as exercised, labeled by and
+// thickened with their count, while the calls never seen are dimmed.
+// Summary edges standing for no single call are left as they are.
+func overlayTrace(
+	t *execTrace,
+	edges []*dot.DotEdge,
+	edgeCall map[*dot.DotEdge]*callgraph.Edge,
+	theme *Theme,
+) {
+	counts := make(map[*dot.DotEdge]float64)
+	for _, e := range edges {
+		call, ok := edgeCall[e]
+		if !ok {
+			continue
+		}
+		n := t.callCount(call.Caller.Func, call.Callee.Func)
+		if n == 0 {
+			e.Attrs["color"] = theme.Edges[ThemeDimmed]
+			e.Attrs["style"] = "dotted"
+			e.Attrs["tooltip"] += "\nnot exercised"
+			continue
+		}
+		counts[e] = float64(n)
+		e.Attrs["color"] = theme.Edges[ThemeExercised]
+		e.Attrs["tooltip"] += fmt.Sprintf("\nexercised: %d", n)
+		if e.Attrs["label"] == "" {
+			e.Attrs["label"] = fmt.Sprintf("×%d", n)
+		}
+	}
+	for e, t := range shareOfMax(counts) {
+		e.Attrs["penwidth"] = strconv.FormatFloat(1+3*t, 'f', 2, 64)
+	}
+}
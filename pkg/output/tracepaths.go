@@ -0,0 +1,90 @@
+package output
+
+import (
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/ssa"
+)
+
+// tracePaths colors the nodes and calls on the call paths from the entry
+// points to the targets, fading the other calls so that the flow stands
+// out of the graph around it. The entry points are the main functions,
+// package initializers and test roots of the graph, or its nodes without
+// callers when it has none. It returns the number of entry points reaching
+// a target.
+func tracePaths(
+	targets map[*dot.DotNode]bool,
+	nodeFunc map[*dot.DotNode]*ssa.Function,
+	edges []*dot.DotEdge,
+	theme *Theme,
+) int {
+	entries := make(map[*dot.DotNode]bool)
+	for n, fn := range nodeFunc {
+		if isEntryPoint(fn) || isTestRoot(fn) {
+			entries[n] = true
+		}
+	}
+	if len(entries) == 0 {
+		called := make(map[*dot.DotNode]bool)
+		for _, e := range edges {
+			called[e.To] = true
+		}
+		for _, e := range edges {
+			if !called[e.From] {
+				entries[e.From] = true
+			}
+		}
+	}
+
+	// the nodes on a path are reached from an entry and reach a target
+	reaches := reachingNodes(targets, edges)
+	callees := make(map[*dot.DotNode][]*dot.DotNode)
+	for _, e := range edges {
+		callees[e.From] = append(callees[e.From], e.To)
+	}
+	onPath := make(map[*dot.DotNode]bool)
+	var queue []*dot.DotNode
+	found := 0
+	for n := range entries {
+		if reaches[n] {
+			onPath[n] = true
+			queue = append(queue, n)
+			found++
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, c := range callees[n] {
+			if reaches[c] && !onPath[c] {
+				onPath[c] = true
+				queue = append(queue, c)
+			}
+		}
+	}
+	if found == 0 {
+		return 0
+	}
+
+	for _, e := range edges {
+		if onPath[e.From] && onPath[e.To] {
+			e.Attrs["color"] = theme.Edges[ThemePath]
+			e.Attrs["penwidth"] = "2.5"
+		} else {
+			e.Attrs["color"] = theme.Edges[ThemeDimmed]
+		}
+	}
+	for n := range onPath {
+		n.Attrs["color"] = theme.Nodes[ThemePath]
+		n.Attrs["penwidth"] = "2.5"
+		switch {
+		case targets[n]:
+			n.Attrs["fillcolor"] = theme.Nodes[ThemeHighlight]
+			n.Attrs["tooltip"] += "\ntrace target"
+		case entries[n]:
+			n.Attrs["tooltip"] += "\nentry point of the trace"
+		default:
+			n.Attrs["tooltip"] += "\non a path to the trace target"
+		}
+	}
+	return found
+}
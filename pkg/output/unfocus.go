@@ -0,0 +1,85 @@
+package output
+
+import (
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// inPaths reports whether fn belongs to a package matching one of the
+// given path prefixes.
+func inPaths(fn *ssa.Function, paths []string) bool {
+	if fn == nil || fn.Pkg == nil {
+		return false
+	}
+	for _, p := range paths {
+		if strings.HasPrefix(fn.Pkg.Pkg.Path(), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEntryPoint reports whether fn is called by the runtime, i.e. it is the
+// main function of a main package or a package initializer.
+func isEntryPoint(fn *ssa.Function) bool {
+	if fn.Pkg == nil || fn.Parent() != nil || fn.Signature.Recv() != nil {
+		return false
+	}
+	return fn.Name() == "init" || (fn.Pkg.Pkg.Name() == "main" && fn.Name() == "main")
+}
+
+// unfocused returns the functions removed by unfocusing the packages with
+// the given path prefixes: the functions of those packages plus every
+// function that is only reachable through them.
+func unfocused(cg *callgraph.Graph, paths []string) map[*ssa.Function]bool {
+	removed := make(map[*ssa.Function]bool)
+	if len(paths) == 0 {
+		return removed
+	}
+
+	// everything reachable from an entry point without passing through
+	// an unfocused package stays
+	reachable := make(map[*callgraph.Node]bool)
+	var queue []*callgraph.Node
+	for fn, n := range cg.Nodes {
+		if fn == nil || inPaths(fn, paths) || (len(n.In) > 0 && !isEntryPoint(fn)) {
+			continue
+		}
+		reachable[n] = true
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range n.Out {
+			if reachable[e.Callee] || inPaths(e.Callee.Func, paths) {
+				continue
+			}
+			reachable[e.Callee] = true
+			queue = append(queue, e.Callee)
+		}
+	}
+
+	// drop the unfocused packages and whatever they exclusively reach
+	for fn, n := range cg.Nodes {
+		if inPaths(fn, paths) {
+			removed[fn] = true
+			queue = append(queue, n)
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range n.Out {
+			if reachable[e.Callee] || removed[e.Callee.Func] {
+				continue
+			}
+			removed[e.Callee.Func] = true
+			queue = append(queue, e.Callee)
+		}
+	}
+
+	return removed
+}
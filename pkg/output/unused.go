@@ -0,0 +1,149 @@
+package output
+
+import (
+	"cmp"
+	"go/types"
+	"slices"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// UnusedExported returns the exported functions, and the exported methods
+// of exported types, of the packages of prog accepted by inModule that no
+// function of these packages calls, sorted by name. Calls of a function
+// to itself, test code loaded with the packages calling it, and the
+// functions of main packages are left out of the report. The calls of
+// generic functions count for their declaration. Methods implementing an
+// interface of the program, like String or ServeHTTP, are left out too,
+// as they are called through the interface, often by other modules.
+func UnusedExported(prog *ssa.Program, cg *callgraph.Graph, inModule func(path string) bool) []*ssa.Function {
+	called := make(map[*ssa.Function]bool)
+	for fn, n := range cg.Nodes {
+		if fn == nil {
+			continue
+		}
+		caller := declaring(fn)
+		if caller == nil || caller.Pkg == nil || !inModule(caller.Pkg.Pkg.Path()) {
+			continue
+		}
+		for _, e := range n.Out {
+			if callee := declaring(e.Callee.Func); callee != caller {
+				called[callee] = true
+			}
+		}
+	}
+
+	ifaces := interfacesByMethod(prog)
+	var unused []*ssa.Function
+	var check = func(fn *ssa.Function) {
+		if fn != nil && !called[fn] && !isTestFunc(fn) {
+			unused = append(unused, fn)
+		}
+	}
+	for _, pkg := range prog.AllPackages() {
+		if pkg.Pkg.Name() == "main" || !inModule(pkg.Pkg.Path()) {
+			continue
+		}
+		for _, m := range pkg.Members {
+			switch m := m.(type) {
+			case *ssa.Function:
+				if m.Object() != nil && m.Object().Exported() {
+					check(m)
+				}
+			case *ssa.Type:
+				named, ok := m.Type().(*types.Named)
+				if !ok || !m.Object().Exported() {
+					continue
+				}
+				for i := range named.NumMethods() {
+					if method := named.Method(i); method.Exported() && !implements(named, method.Name(), ifaces) {
+						check(prog.FuncValue(method))
+					}
+				}
+			}
+		}
+	}
+	slices.SortFunc(unused, func(x, y *ssa.Function) int { return cmp.Compare(x.String(), y.String()) })
+	return unused
+}
+
+// interfacesByMethod returns the interfaces declared in the packages of
+// prog, and error, by the names of their methods. Generic interfaces are
+// left out.
+func interfacesByMethod(prog *ssa.Program) map[string][]*types.Interface {
+	ifaces := make(map[string][]*types.Interface)
+	add := func(iface *types.Interface) {
+		for i := range iface.NumMethods() {
+			name := iface.Method(i).Name()
+			ifaces[name] = append(ifaces[name], iface)
+		}
+	}
+	add(types.Universe.Lookup("error").Type().Underlying().(*types.Interface))
+	for _, pkg := range prog.AllPackages() {
+		for _, m := range pkg.Members {
+			t, ok := m.(*ssa.Type)
+			if !ok {
+				continue
+			}
+			named, ok := t.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if iface, ok := named.Underlying().(*types.Interface); ok {
+				add(iface)
+			}
+		}
+	}
+	return ifaces
+}
+
+// implements reports whether the method of named, or of a pointer to it,
+// implements one of the interfaces with a method of that name. Generic
+// types are not checked.
+func implements(named *types.Named, method string, ifaces map[string][]*types.Interface) bool {
+	if named.TypeParams().Len() > 0 {
+		return false
+	}
+	ptr := types.NewPointer(named)
+	for _, iface := range ifaces[method] {
+		if types.Implements(named, iface) || types.Implements(ptr, iface) {
+			return true
+		}
+	}
+	return false
+}
+
+// declaring returns the function declaring fn: the outermost function of
+// closures, the generic function of instantiations.
+func declaring(fn *ssa.Function) *ssa.Function {
+	for fn.Parent() != nil {
+		fn = fn.Parent()
+	}
+	if fn.Origin() != nil {
+		fn = fn.Origin()
+	}
+	return fn
+}
+
+// overlayUnused dims the nodes of the unused exported functions, noting it
+// in their tooltip.
+func overlayUnused(unused []*ssa.Function, nodeFunc map[*dot.DotNode]*ssa.Function, theme *Theme) {
+	set := make(map[*ssa.Function]bool, len(unused))
+	for _, fn := range unused {
+		set[fn] = true
+	}
+	for n, fn := range nodeFunc {
+		if fn.Origin() != nil {
+			fn = fn.Origin()
+		}
+		if !set[fn] {
+			continue
+		}
+		n.Attrs["fillcolor"] = theme.Nodes[ThemeDimmed]
+		n.Attrs["fontcolor"] = theme.FontColors[ThemeDimmed]
+		n.Attrs["style"] = "dashed,filled,rounded"
+		n.Attrs["tooltip"] += "\nexported, never called within the module"
+	}
+}
@@ -0,0 +1,36 @@
+package output
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ofabry/go-callvis/internal/ssatest"
+	"golang.org/x/tools/go/callgraph/static"
+)
+
+func TestUnusedExportedSkipsInterfaceMethods(t *testing.T) {
+	pkg := ssatest.Build(t, ssatest.Package{Path: "example.com/p", Src: `package p
+
+import "fmt"
+
+type T struct{}
+
+func (T) String() string { return "t" }
+
+func (*T) Error() string { return "t" }
+
+func (T) Other() {}
+
+func Print(v any) { fmt.Println(v) }
+`})[0]
+	cg := static.CallGraph(pkg.Prog)
+
+	var got []string
+	for _, fn := range UnusedExported(pkg.Prog, cg, func(path string) bool { return path == "example.com/p" }) {
+		got = append(got, fn.String())
+	}
+	want := []string{"(example.com/p.T).Other", "example.com/p.Print"}
+	if !slices.Equal(got, want) {
+		t.Errorf("UnusedExported = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,279 @@
+// Package policy checks call graphs against rules forbidding calls, like
+// HTTP handlers calling the database package directly, making the call
+// graph enforceable in CI.
+package policy
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/token"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+	"gopkg.in/yaml.v3"
+)
+
+// A Policy is a set of rules and architecture layers, read from YAML like:
+//
+//	rules:
+//	  - name: handlers must not call the database directly
+//	    from: [example.com/app/handlers/...]
+//	    to: [example.com/app/db]
+//	  - name: no dynamic calls into internal packages
+//	    match: callee.pkg =~ "/internal/" && dynamic
+//	layers:
+//	  - name: api
+//	    packages: [example.com/app/api/...]
+//	  - name: service
+//	    packages: [example.com/app/service/...]
+//	  - name: store
+//	    packages: [example.com/app/store/...]
+type Policy struct {
+	Rules  []*Rule  `yaml:"rules"`
+	Layers []*Layer `yaml:"layers"`
+
+	// allowed maps the layers to the layers they may call
+	allowed map[*Layer]map[*Layer]bool
+}
+
+// A Rule forbids the calls matching all of its conditions.
+type Rule struct {
+	Name string `yaml:"name"`
+	// From and To match the packages of the caller and the callee by
+	// import path, with a /... suffix matching subpackages too.
+	From []string `yaml:"from"`
+	To   []string `yaml:"to"`
+	// Match is a filter expression on calls, see output.ParseFilter.
+	Match string `yaml:"match"`
+
+	match output.EdgeFilter
+}
+
+// A Layer is a group of packages of the architecture. Layers are listed
+// from the top down and may call the layers below them, or only those
+// listed in Calls if given. Calls within a layer are always allowed, calls
+// of packages outside of all layers are not checked.
+type Layer struct {
+	Name string `yaml:"name"`
+	// Packages are import paths, with a /... suffix matching subpackages
+	// too. A package in several layers belongs to the first.
+	Packages []string `yaml:"packages"`
+	// Calls are the names of the layers this layer may call.
+	Calls []string `yaml:"calls"`
+}
+
+// Load reads a policy from a YAML file.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Parse reads a policy from YAML, rejecting unknown keys and rules without
+// conditions.
+func Parse(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	for i, r := range p.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if len(r.From) == 0 && len(r.To) == 0 && r.Match == "" {
+			return nil, fmt.Errorf("%s: no from, to or match condition", r.Name)
+		}
+		if r.Match != "" {
+			nf, ef, err := output.ParseFilter(r.Match)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", r.Name, err)
+			}
+			if nf != nil {
+				return nil, fmt.Errorf("%s: match must select calls, use caller.<field> and callee.<field>", r.Name)
+			}
+			r.match = ef
+		}
+	}
+	if err := p.resolveLayers(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// resolveLayers checks the layers and computes the calls allowed between
+// them.
+func (p *Policy) resolveLayers() error {
+	byName := make(map[string]*Layer)
+	for _, l := range p.Layers {
+		if l.Name == "" {
+			return fmt.Errorf("layer without name")
+		}
+		if byName[l.Name] != nil {
+			return fmt.Errorf("duplicate layer %s", l.Name)
+		}
+		if len(l.Packages) == 0 {
+			return fmt.Errorf("layer %s: no packages", l.Name)
+		}
+		byName[l.Name] = l
+	}
+	p.allowed = make(map[*Layer]map[*Layer]bool)
+	for i, l := range p.Layers {
+		allowed := make(map[*Layer]bool)
+		if l.Calls == nil {
+			for _, below := range p.Layers[i+1:] {
+				allowed[below] = true
+			}
+		}
+		for _, name := range l.Calls {
+			callee := byName[name]
+			if callee == nil {
+				return fmt.Errorf("layer %s: calls unknown layer %s", l.Name, name)
+			}
+			allowed[callee] = true
+		}
+		p.allowed[l] = allowed
+	}
+	return nil
+}
+
+// layerOf returns the layer of fn, or nil if it is in none.
+func (p *Policy) layerOf(fn *ssa.Function) *Layer {
+	for _, l := range p.Layers {
+		if inPackages(fn, l.Packages) {
+			return l
+		}
+	}
+	return nil
+}
+
+// layerRule returns the name of the layer rule violated by the call, or
+// "" if the call is allowed.
+func (p *Policy) layerRule(edge *callgraph.Edge) string {
+	if len(p.Layers) == 0 {
+		return ""
+	}
+	from, to := p.layerOf(edge.Caller.Func), p.layerOf(edge.Callee.Func)
+	if from == nil || to == nil || from == to || p.allowed[from][to] {
+		return ""
+	}
+	return fmt.Sprintf("layer %s must not call layer %s", from.Name, to.Name)
+}
+
+// matches reports whether the rule forbids the call.
+func (r *Rule) matches(edge *callgraph.Edge) bool {
+	if len(r.From) > 0 && !inPackages(edge.Caller.Func, r.From) {
+		return false
+	}
+	if len(r.To) > 0 && !inPackages(edge.Callee.Func, r.To) {
+		return false
+	}
+	return r.match == nil || r.match.KeepEdge(edge)
+}
+
+// inPackages reports whether fn is in one of the packages, given as
+// import paths or patterns with a /... suffix.
+func inPackages(fn *ssa.Function, pkgs []string) bool {
+	if fn.Pkg == nil {
+		return false
+	}
+	path := fn.Pkg.Pkg.Path()
+	for _, p := range pkgs {
+		if prefix, ok := strings.CutSuffix(p, "/..."); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if path == p {
+			return true
+		}
+	}
+	return false
+}
+
+// A Violation is a call forbidden by a rule.
+type Violation struct {
+	Rule   string
+	Caller *ssa.Function
+	Callee *ssa.Function
+	// Pos is the position of the call site, invalid for calls without
+	// one.
+	Pos token.Position
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%v: %s: %s calls %s", v.Pos, v.Rule, v.Caller, v.Callee)
+}
+
+// sourceFunc returns the function of the source code fn stands for: the
+// generic function fn is an instance of, or fn itself. It returns nil for
+// the wrappers, bound methods and thunks, which have no source.
+func sourceFunc(fn *ssa.Function) *ssa.Function {
+	if origin := fn.Origin(); origin != nil {
+		return origin
+	}
+	for _, prefix := range []string{"wrapper ", "bound ", "thunk "} {
+		if strings.HasPrefix(fn.Synthetic, prefix) {
+			return nil
+		}
+	}
+	return fn
+}
+
+// Check returns the calls of cg violating the policy, sorted by position.
+func (p *Policy) Check(cg *callgraph.Graph) []Violation {
+	var violations []Violation
+	callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
+		caller, callee := sourceFunc(edge.Caller.Func), sourceFunc(edge.Callee.Func)
+		// skip the calls of wrappers and the implicit calls of the
+		// initializers of imported packages
+		if caller == nil || callee == nil || callee.Synthetic == "package initializer" {
+			return nil
+		}
+		if caller != edge.Caller.Func || callee != edge.Callee.Func {
+			edge = &callgraph.Edge{
+				Caller: &callgraph.Node{Func: caller, ID: edge.Caller.ID},
+				Site:   edge.Site,
+				Callee: &callgraph.Node{Func: callee, ID: edge.Callee.ID},
+			}
+		}
+		violate := func(rule string) {
+			violations = append(violations, Violation{
+				Rule:   rule,
+				Caller: caller,
+				Callee: callee,
+				Pos:    caller.Prog.Fset.Position(edge.Pos()),
+			})
+		}
+		for _, r := range p.Rules {
+			if r.matches(edge) {
+				violate(r.Name)
+			}
+		}
+		if rule := p.layerRule(edge); rule != "" {
+			violate(rule)
+		}
+		return nil
+	})
+	slices.SortFunc(violations, func(a, b Violation) int {
+		return cmp.Or(
+			cmp.Compare(a.Pos.Filename, b.Pos.Filename),
+			cmp.Compare(a.Pos.Line, b.Pos.Line),
+			cmp.Compare(a.Pos.Column, b.Pos.Column),
+			cmp.Compare(a.Rule, b.Rule),
+			cmp.Compare(a.Callee.String(), b.Callee.String()),
+		)
+	})
+	return violations
+}
@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/ofabry/go-callvis/internal/ssatest"
+	"golang.org/x/tools/go/callgraph/cha"
+)
+
+const dbSrc = `package db
+
+type Conn struct{}
+
+func Open() *Conn { return &Conn{} }
+
+func Query() {}
+
+func Get[T any]() T {
+	var t T
+	return t
+}
+`
+
+func TestCheckInitializersAndGenerics(t *testing.T) {
+	pkgs := ssatest.Build(t,
+		ssatest.Package{Path: "example.com/db", Src: dbSrc},
+		ssatest.Package{Path: "example.com/handlers", Src: `package handlers
+
+import "example.com/db"
+
+var conn = db.Open()
+
+func Handle() {
+	db.Query()
+	_ = db.Get[int]()
+}
+`})
+	cg := cha.CallGraph(pkgs[0].Prog)
+	cg.DeleteSyntheticNodes()
+	p, err := Parse([]byte(`
+rules:
+  - name: handlers must not call db
+    from: [example.com/handlers]
+    to: [example.com/db]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for _, v := range p.Check(cg) {
+		got[v.Caller.String()+" -> "+v.Callee.String()] = true
+	}
+	for _, want := range []string{
+		"example.com/handlers.init -> example.com/db.Open",
+		"example.com/handlers.Handle -> example.com/db.Query",
+		"example.com/handlers.Handle -> example.com/db.Get",
+	} {
+		if !got[want] {
+			t.Errorf("missing violation %s, got %v", want, got)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d violations, want 3: %v", len(got), got)
+	}
+}
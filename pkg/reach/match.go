@@ -0,0 +1,73 @@
+package reach
+
+import (
+	"go/types"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// anyOf matches the functions matched by any of match.
+func anyOf(match ...func(fn *ssa.Function) bool) func(fn *ssa.Function) bool {
+	return func(fn *ssa.Function) bool {
+		return slices.ContainsFunc(match, func(m func(fn *ssa.Function) bool) bool { return m(fn) })
+	}
+}
+
+// inPackages matches the functions of the packages with the given import
+// paths.
+func inPackages(paths ...string) func(fn *ssa.Function) bool {
+	return func(fn *ssa.Function) bool {
+		return fn.Pkg != nil && slices.Contains(paths, fn.Pkg.Pkg.Path())
+	}
+}
+
+// named matches the functions with the given fully qualified names, like
+// "os.StartProcess" or "(*net/http.Client).Do".
+func named(names ...string) func(fn *ssa.Function) bool {
+	return func(fn *ssa.Function) bool {
+		return slices.Contains(names, fn.String())
+	}
+}
+
+// prefixed matches the functions whose fully qualified names start with
+// one of prefixes, like "net.Dial" for net.Dial and net.DialTimeout.
+func prefixed(prefixes ...string) func(fn *ssa.Function) bool {
+	return func(fn *ssa.Function) bool {
+		name := fn.String()
+		return slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(name, p) })
+	}
+}
+
+// usesUnsafe matches the functions outside the standard library converting
+// to or from unsafe.Pointer, or calling unsafe.Add, Slice, String and the
+// like.
+func usesUnsafe(fn *ssa.Function) bool {
+	if fn.Pkg == nil || isStd(fn.Pkg.Pkg.Path()) {
+		return false
+	}
+	unsafePointer := types.Typ[types.UnsafePointer]
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			switch instr := instr.(type) {
+			case *ssa.Convert:
+				if types.Identical(instr.Type(), unsafePointer) || types.Identical(instr.X.Type(), unsafePointer) {
+					return true
+				}
+			case *ssa.Call:
+				if b, ok := instr.Call.Value.(*ssa.Builtin); ok && b.Object().Pkg() == types.Unsafe {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isStd reports whether path belongs to the standard library, whose import
+// paths have no dot in their first element.
+func isStd(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".") && path != "command-line-arguments"
+}
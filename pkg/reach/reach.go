@@ -0,0 +1,234 @@
+// Package reach finds the security sensitive functions, like running
+// commands or dialing the network, that the entry points of a program can
+// reach in its call graph, for security reviews.
+package reach
+
+import (
+	"cmp"
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// A Set is a named set of sinks, the functions a review looks for.
+type Set struct {
+	Name string
+	Doc  string
+	// Match reports whether fn is a sink of the set.
+	Match func(fn *ssa.Function) bool
+}
+
+// Sets are the built-in sink sets, by name.
+var Sets = []*Set{
+	{
+		Name:  "exec",
+		Doc:   "running commands: os/exec, os.StartProcess and the exec functions of syscall",
+		Match: anyOf(inPackages("os/exec"), named("os.StartProcess", "syscall.Exec", "syscall.ForkExec", "syscall.StartProcess")),
+	},
+	{
+		Name:  "unsafe",
+		Doc:   "functions outside the standard library converting pointers with unsafe.Pointer or calling the unsafe functions",
+		Match: usesUnsafe,
+	},
+	{
+		Name: "reflect",
+		Doc:  "calling and reaching methods and memory by reflection",
+		Match: named(
+			"(reflect.Value).Call", "(reflect.Value).CallSlice",
+			"(reflect.Value).Method", "(reflect.Value).MethodByName",
+			"(reflect.Value).UnsafeAddr", "(reflect.Value).UnsafePointer",
+			"reflect.NewAt", "reflect.MakeFunc",
+		),
+	},
+	{
+		Name: "net",
+		Doc:  "dialing the network: net, crypto/tls and the net/http client",
+		Match: anyOf(
+			prefixed("net.Dial", "(*net.Dialer).Dial", "crypto/tls.Dial", "(*crypto/tls.Dialer).Dial"),
+			named(
+				"net/http.Get", "net/http.Head", "net/http.Post", "net/http.PostForm",
+				"(*net/http.Client).Do", "(*net/http.Client).Get", "(*net/http.Client).Head",
+				"(*net/http.Client).Post", "(*net/http.Client).PostForm",
+			),
+		),
+	},
+	{
+		Name:  "crypto",
+		Doc:   "weak cryptography: MD5, SHA-1, DES, RC4 and DSA",
+		Match: inPackages("crypto/md5", "crypto/sha1", "crypto/des", "crypto/rc4", "crypto/dsa"),
+	},
+}
+
+// Lookup returns the sets named by names, all of them for none.
+func Lookup(names []string) ([]*Set, error) {
+	if len(names) == 0 {
+		return Sets, nil
+	}
+	var sets []*Set
+	for _, name := range names {
+		i := slices.IndexFunc(Sets, func(s *Set) bool { return s.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown sink set %q, expected one of %s", name, strings.Join(Names(), ", "))
+		}
+		sets = append(sets, Sets[i])
+	}
+	return sets, nil
+}
+
+// Names returns the names of the built-in sets.
+func Names() []string {
+	var names []string
+	for _, s := range Sets {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// A Finding is a sink reached from entry points.
+type Finding struct {
+	Set  *Set
+	Sink *ssa.Function
+	// Entries are the entry points calling the sink, directly or not,
+	// sorted by name.
+	Entries []*ssa.Function
+}
+
+// Result are the sinks reached in a call graph and the functions on the
+// call paths from the entry points to them.
+type Result struct {
+	Findings []Finding
+	// Paths are the functions on a call path from an entry point to a
+	// reached sink, the entry points and sinks included.
+	Paths map[*ssa.Function]bool
+}
+
+// Find returns the sinks of sets in cg reachable from the entries, sorted
+// by set and name, a function of several sets counting for the first. Only
+// the first sink on a call path is reported: exec.Command is, the calls
+// within os/exec are not. An entry may be a sink itself.
+func Find(cg *callgraph.Graph, entries []*ssa.Function, sets []*Set) *Result {
+	r := &Result{Paths: make(map[*ssa.Function]bool)}
+	sinks := make(map[*ssa.Function]*Set)
+	var sinkSet = func(fn *ssa.Function) *Set {
+		set, ok := sinks[fn]
+		if !ok {
+			i := slices.IndexFunc(sets, func(s *Set) bool { return s.Match(fn) })
+			if i >= 0 {
+				set = sets[i]
+			}
+			sinks[fn] = set
+		}
+		return set
+	}
+	reached := make(map[*Set]map[*ssa.Function]map[*ssa.Function]bool)
+	forward := make(map[*ssa.Function]bool)
+	for _, entry := range entries {
+		n := cg.Nodes[entry]
+		if n == nil {
+			continue
+		}
+		// the functions reachable from entry, stopping at the sinks
+		seen := map[*callgraph.Node]bool{n: true}
+		queue := []*callgraph.Node{n}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			forward[n.Func] = true
+			if set := sinkSet(n.Func); set != nil {
+				if reached[set] == nil {
+					reached[set] = make(map[*ssa.Function]map[*ssa.Function]bool)
+				}
+				if reached[set][n.Func] == nil {
+					reached[set][n.Func] = make(map[*ssa.Function]bool)
+				}
+				reached[set][n.Func][entry] = true
+				continue
+			}
+			for _, e := range n.Out {
+				if !seen[e.Callee] {
+					seen[e.Callee] = true
+					queue = append(queue, e.Callee)
+				}
+			}
+		}
+	}
+
+	// the functions on a path are reachable from an entry and reach a sink
+	var queue []*callgraph.Node
+	for _, set := range sets {
+		for sink, from := range reached[set] {
+			r.Findings = append(r.Findings, Finding{Set: set, Sink: sink, Entries: sortedFuncs(slices.Collect(maps.Keys(from)))})
+			r.Paths[sink] = true
+			queue = append(queue, cg.Nodes[sink])
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, e := range n.In {
+			if fn := e.Caller.Func; forward[fn] && !r.Paths[fn] && sinkSet(fn) == nil {
+				r.Paths[fn] = true
+				queue = append(queue, e.Caller)
+			}
+		}
+	}
+	order := make(map[*Set]int)
+	for i, set := range sets {
+		order[set] = i
+	}
+	slices.SortFunc(r.Findings, func(x, y Finding) int {
+		return cmp.Or(cmp.Compare(order[x.Set], order[y.Set]), cmp.Compare(x.Sink.String(), y.Sink.String()))
+	})
+	return r
+}
+
+// Entries returns the entry points of pkgs: the main functions and package
+// initializers, and for the packages without main function, which are
+// libraries, their exported functions and the exported methods of their
+// exported types.
+func Entries(pkgs []*ssa.Package) []*ssa.Function {
+	var entries []*ssa.Function
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		if init := pkg.Func("init"); init != nil {
+			entries = append(entries, init)
+		}
+		if main := pkg.Func("main"); main != nil && pkg.Pkg.Name() == "main" {
+			entries = append(entries, main)
+			continue
+		}
+		for _, m := range pkg.Members {
+			switch m := m.(type) {
+			case *ssa.Function:
+				if m.Object() != nil && m.Object().Exported() && m.TypeParams().Len() == 0 {
+					entries = append(entries, m)
+				}
+			case *ssa.Type:
+				t, ok := m.Type().(*types.Named)
+				if !ok || !m.Object().Exported() || t.TypeParams().Len() > 0 {
+					continue
+				}
+				for i := range t.NumMethods() {
+					if method := t.Method(i); method.Exported() {
+						entries = append(entries, pkg.Prog.FuncValue(method))
+					}
+				}
+			}
+		}
+	}
+	return sortedFuncs(entries)
+}
+
+// sortedFuncs returns fns sorted by name, once each.
+func sortedFuncs(fns []*ssa.Function) []*ssa.Function {
+	fns = slices.Clone(fns)
+	slices.SortFunc(fns, func(x, y *ssa.Function) int { return cmp.Compare(x.String(), y.String()) })
+	return slices.Compact(fns)
+}
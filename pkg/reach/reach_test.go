@@ -0,0 +1,52 @@
+package reach
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ofabry/go-callvis/internal/ssatest"
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/ssa"
+)
+
+func TestFind(t *testing.T) {
+	pkg := ssatest.Build(t, ssatest.Package{Path: "example.com/p", Src: `package p
+
+import "os/exec"
+
+func Run() { helper() }
+
+func helper() { exec.Command("ls").Run() }
+
+func Safe() {}
+
+func unreached() { exec.Command("rm") }
+`})[0]
+	cg := static.CallGraph(pkg.Prog)
+	sets, err := Lookup([]string{"exec"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Find(cg, []*ssa.Function{pkg.Func("Run"), pkg.Func("Safe")}, sets)
+
+	var sinks []string
+	for _, f := range r.Findings {
+		sinks = append(sinks, f.Sink.String())
+		if f.Set.Name != "exec" || len(f.Entries) != 1 || f.Entries[0] != pkg.Func("Run") {
+			t.Errorf("%s is reached by %v of %s, want example.com/p.Run of exec", f.Sink, f.Entries, f.Set.Name)
+		}
+	}
+	if want := []string{"(*os/exec.Cmd).Run", "os/exec.Command"}; !slices.Equal(sinks, want) {
+		t.Errorf("sinks = %v, want %v", sinks, want)
+	}
+
+	var paths []string
+	for fn := range r.Paths {
+		paths = append(paths, fn.String())
+	}
+	slices.Sort(paths)
+	want := []string{"(*os/exec.Cmd).Run", "example.com/p.Run", "example.com/p.helper", "os/exec.Command"}
+	if !slices.Equal(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
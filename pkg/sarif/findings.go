@@ -0,0 +1,69 @@
+package sarif
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Rule IDs of the findings other than policy violations, which are
+// identified by the name of their rule.
+const (
+	// RuleUnusedExported marks exported functions and methods never
+	// called within their module.
+	RuleUnusedExported = "unused-exported"
+	// RuleReflection marks calls reaching methods or memory by
+	// reflection.
+	RuleReflection = "reflection"
+)
+
+// UnusedResults returns the notes on the exported functions never called
+// within their module, at their declaration.
+func UnusedResults(unused []*ssa.Function) []Result {
+	var results []Result
+	for _, fn := range unused {
+		results = append(results, Result{
+			RuleID:  RuleUnusedExported,
+			Level:   LevelNote,
+			Message: fmt.Sprintf("%s is exported but never called within the module", fn),
+			Pos:     fn.Prog.Fset.Position(fn.Pos()),
+		})
+	}
+	return results
+}
+
+// ReflectionResults returns the warnings on the calls of the functions of
+// pkgs, their closures included, to the functions reflect matches, at the
+// call sites, sorted by position.
+func ReflectionResults(cg *callgraph.Graph, pkgs []*ssa.Package, reflect func(fn *ssa.Function) bool) []Result {
+	analyzed := make(map[*ssa.Package]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		analyzed[pkg] = true
+	}
+	var results []Result
+	callgraph.GraphVisitEdges(cg, func(edge *callgraph.Edge) error {
+		caller := edge.Caller.Func
+		if caller.Pkg == nil || !analyzed[caller.Pkg] || !reflect(edge.Callee.Func) {
+			return nil
+		}
+		results = append(results, Result{
+			RuleID:  RuleReflection,
+			Level:   LevelWarning,
+			Message: fmt.Sprintf("%s calls %s", caller, edge.Callee.Func),
+			Pos:     caller.Prog.Fset.Position(edge.Pos()),
+		})
+		return nil
+	})
+	slices.SortFunc(results, func(a, b Result) int {
+		return cmp.Or(
+			cmp.Compare(a.Pos.Filename, b.Pos.Filename),
+			cmp.Compare(a.Pos.Line, b.Pos.Line),
+			cmp.Compare(a.Pos.Column, b.Pos.Column),
+			cmp.Compare(a.Message, b.Message),
+		)
+	})
+	return results
+}
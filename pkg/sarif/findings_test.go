@@ -0,0 +1,76 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/ofabry/go-callvis/internal/ssatest"
+	"golang.org/x/tools/go/callgraph/static"
+	"golang.org/x/tools/go/ssa"
+)
+
+const src = `package p
+
+import "reflect"
+
+func Unused() {}
+
+func Call(f any) {
+	reflect.ValueOf(f).Call(nil)
+}
+`
+
+func TestFindingResults(t *testing.T) {
+	pkg := ssatest.Build(t, ssatest.Package{Path: "example.com/p", Src: src})[0]
+	cg := static.CallGraph(pkg.Prog)
+
+	results := append(
+		UnusedResults([]*ssa.Function{pkg.Func("Unused")}),
+		ReflectionResults(cg, []*ssa.Package{pkg}, func(fn *ssa.Function) bool {
+			return fn.String() == "(reflect.Value).Call"
+		})...,
+	)
+	var buf bytes.Buffer
+	if err := Write(&buf, "test", "/src", results); err != nil {
+		t.Fatal(err)
+	}
+	var got log
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	var rules []string
+	for _, r := range got.Runs[0].Tool.Driver.Rules {
+		rules = append(rules, r.ID)
+	}
+	if want := []string{RuleReflection, RuleUnusedExported}; !slices.Equal(rules, want) {
+		t.Errorf("rules = %v, want %v", rules, want)
+	}
+	want := []struct {
+		rule, level string
+		line, col   int
+	}{
+		{RuleUnusedExported, LevelNote, 5, 6},
+		{RuleReflection, LevelWarning, 8, 25},
+	}
+	if len(got.Runs[0].Results) != len(want) {
+		t.Fatalf("got %d results, want %d: %s", len(got.Runs[0].Results), len(want), buf.String())
+	}
+	for i, r := range got.Runs[0].Results {
+		w := want[i]
+		if r.RuleID != w.rule || r.Level != w.level {
+			t.Errorf("result %d is %s %s, want %s %s", i, r.RuleID, r.Level, w.rule, w.level)
+		}
+		if len(r.Locations) != 1 {
+			t.Errorf("result %d has %d locations, want 1", i, len(r.Locations))
+			continue
+		}
+		loc := r.Locations[0].PhysicalLocation
+		if loc.ArtifactLocation.URI != "example.com/p/p.go" || loc.Region == nil ||
+			loc.Region.StartLine != w.line || loc.Region.StartColumn != w.col {
+			t.Errorf("result %d is at %+v %+v, want example.com/p/p.go:%d:%d", i, loc.ArtifactLocation, loc.Region, w.line, w.col)
+		}
+	}
+}
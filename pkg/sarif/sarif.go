@@ -0,0 +1,162 @@
+// Package sarif writes findings about the call graph in the Static Analysis
+// Results Interchange Format (SARIF 2.1.0), which code review tools like
+// GitHub code scanning and GitLab show as annotations on the changed lines.
+package sarif
+
+import (
+	"cmp"
+	"encoding/json"
+	"go/token"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	version   = "2.1.0"
+	schemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolURI   = "https://github.com/ofabry/go-callvis"
+)
+
+// Levels of results.
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// A Result is a finding at a position of the source code, like a call
+// violating a policy rule.
+type Result struct {
+	// RuleID identifies the rule producing the result, results of the same
+	// rule are grouped by code review tools.
+	RuleID string
+	// Level is LevelError, LevelWarning or LevelNote, LevelError by
+	// default.
+	Level   string
+	Message string
+	// Pos is the position of the finding, results with an invalid position
+	// have no location.
+	Pos token.Position
+}
+
+type log struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []run  `json:"runs"`
+}
+
+type run struct {
+	Tool    tool     `json:"tool"`
+	Results []result `json:"results"`
+}
+
+type tool struct {
+	Driver driver `json:"driver"`
+}
+
+type driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri"`
+	Rules          []rule `json:"rules,omitempty"`
+}
+
+type rule struct {
+	ID               string  `json:"id"`
+	ShortDescription message `json:"shortDescription"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+type result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   message    `json:"message"`
+	Locations []location `json:"locations,omitempty"`
+}
+
+type location struct {
+	PhysicalLocation physicalLocation `json:"physicalLocation"`
+}
+
+type physicalLocation struct {
+	ArtifactLocation artifactLocation `json:"artifactLocation"`
+	Region           *region          `json:"region,omitempty"`
+}
+
+type artifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Write writes the results as a SARIF log of one go-callvis run to w. File
+// paths below root are written relative to it, which code review tools
+// resolve against the repository root; other paths are written as absolute
+// file URIs.
+func Write(w io.Writer, toolVersion, root string, results []Result) error {
+	r := run{
+		Tool: tool{Driver: driver{
+			Name:           "go-callvis",
+			Version:        toolVersion,
+			InformationURI: toolURI,
+		}},
+		Results: []result{},
+	}
+	rules := make(map[string]bool)
+	for _, res := range results {
+		if !rules[res.RuleID] {
+			rules[res.RuleID] = true
+			r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule{
+				ID:               res.RuleID,
+				ShortDescription: message{Text: res.RuleID},
+			})
+		}
+		sr := result{
+			RuleID:  res.RuleID,
+			Level:   cmp.Or(res.Level, LevelError),
+			Message: message{Text: res.Message},
+		}
+		if res.Pos.Filename != "" {
+			loc := location{PhysicalLocation: physicalLocation{
+				ArtifactLocation: artifactURI(root, res.Pos.Filename),
+			}}
+			if res.Pos.Line > 0 {
+				loc.PhysicalLocation.Region = &region{StartLine: res.Pos.Line, StartColumn: res.Pos.Column}
+			}
+			sr.Locations = []location{loc}
+		}
+		r.Results = append(r.Results, sr)
+	}
+	slices.SortFunc(r.Tool.Driver.Rules, func(a, b rule) int { return cmp.Compare(a.ID, b.ID) })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log{Version: version, Schema: schemaURI, Runs: []run{r}})
+}
+
+// artifactURI returns the location of file, relative to root if it is
+// below it.
+func artifactURI(root, file string) artifactLocation {
+	if root != "" {
+		if rel, err := filepath.Rel(root, file); err == nil && filepath.IsLocal(rel) {
+			return artifactLocation{URI: filepath.ToSlash(rel), URIBaseID: "%SRCROOT%"}
+		}
+	}
+	path := filepath.ToSlash(file)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths like C:/src/main.go
+		path = "/" + path
+	}
+	u := url.URL{Scheme: "file", Path: path}
+	return artifactLocation{URI: u.String()}
+}
@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// gcsRequest returns the request putting data as key into bucket with the
+// JSON API of Cloud Storage. It is authorized by the access token in
+// GOOGLE_OAUTH_ACCESS_TOKEN, or else of gcloud, and sent to the emulator
+// at STORAGE_EMULATOR_HOST if set, which needs none.
+func gcsRequest(ctx context.Context, bucket, key string, data []byte) (*http.Request, error) {
+	endpoint := "https://storage.googleapis.com"
+	emulator := os.Getenv("STORAGE_EMULATOR_HOST")
+	if emulator != "" {
+		endpoint = strings.TrimSuffix(emulator, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+	u := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		endpoint, url.PathEscape(bucket), url.QueryEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if emulator == "" {
+		token, err := gcsToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+func gcsToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+	if err != nil {
+		return "", fmt.Errorf("uploading to Cloud Storage needs GOOGLE_OAUTH_ACCESS_TOKEN or gcloud: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,128 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// s3Request returns the request putting data as key into bucket, signed
+// with AWS Signature Version 4. Like the AWS CLI, it takes the credentials
+// and region from the AWS_* environment variables, and the endpoint of
+// S3-compatible stores like MinIO from AWS_ENDPOINT_URL_S3 or
+// AWS_ENDPOINT_URL.
+func s3Request(ctx context.Context, bucket, key string, data, sum []byte) (*http.Request, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("uploading to S3 needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	region := firstEnv("AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// custom endpoints are addressed by path, AWS by virtual host
+	var url string
+	if endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); endpoint != "" {
+		url = strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + escapePath(key)
+	} else {
+		url = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, escapePath(key))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	payload := hex.EncodeToString(sum)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+		signed = append(signed, "x-amz-security-token")
+	}
+	signV4(req, accessKey, secretKey, region, "s3", signed, payload, time.Now())
+	return req, nil
+}
+
+// signV4 signs req for service in region at time now with AWS Signature
+// Version 4, setting its X-Amz-Date and Authorization headers. The signed
+// headers are named in lower case and sorted, payload is the hex SHA-256
+// of the body.
+func signV4(req *http.Request, accessKey, secretKey, region, service string, signed []string, payload string, now time.Time) {
+	now = now.UTC()
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+
+	canonical := canonicalRequest(req, signed, payload)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + req.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	signingKey := []byte("AWS4" + secretKey)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, s)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, strings.Join(signed, ";"), hex.EncodeToString(hmacSHA256(signingKey, toSign))))
+}
+
+// canonicalRequest returns the canonical form of req signed by Signature
+// Version 4, over the signed headers and the payload hash.
+func canonicalRequest(req *http.Request, signed []string, payload string) string {
+	var b strings.Builder
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	query := strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20")
+	b.WriteString(req.Method + "\n" + path + "\n" + query + "\n")
+	for _, h := range signed {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		b.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	b.WriteString("\n" + strings.Join(signed, ";") + "\n" + payload)
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath escapes the elements of the object key p as S3 expects in
+// signed paths: all bytes but the unreserved characters of RFC 3986.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// firstEnv returns the value of the first variable set among names.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSignV4 checks the signature against get-vanilla of the AWS Signature
+// Version 4 test suite.
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+		canonical = "GET\n" +
+			"/\n" +
+			"\n" +
+			"host:example.amazonaws.com\n" +
+			"x-amz-date:20150830T123600Z\n" +
+			"\n" +
+			"host;x-amz-date\n" +
+			emptyHash
+		canonicalHash = "bb579772317eb040ac9ed261061d46c1f17a8133879d6129b6e1c25292927e63"
+		authorization = "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+			"SignedHeaders=host;x-amz-date, " +
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	)
+	signed := []string{"host", "x-amz-date"}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signV4(req, "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "service", signed, emptyHash, now)
+
+	got := canonicalRequest(req, signed, emptyHash)
+	if got != canonical {
+		t.Errorf("canonical request:\n%s\nwant:\n%s", got, canonical)
+	}
+	if sum := sha256.Sum256([]byte(got)); hex.EncodeToString(sum[:]) != canonicalHash {
+		t.Errorf("canonical request hash %x, want %s", sum, canonicalHash)
+	}
+	if got := req.Header.Get("Authorization"); got != authorization {
+		t.Errorf("Authorization:\n%s\nwant:\n%s", got, authorization)
+	}
+}
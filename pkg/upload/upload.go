@@ -0,0 +1,98 @@
+// Package upload publishes rendered files to object storage, Amazon S3 or
+// Google Cloud Storage, under content-addressed names, so that publishing
+// the same file again is a no-op and published links never go stale.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// contentTypes are the media types of the text formats, which the system
+// may map to other media types, like .dot to Word templates.
+var contentTypes = map[string]string{
+	".dot":     "text/vnd.graphviz",
+	".gv":      "text/vnd.graphviz",
+	".mermaid": "text/plain; charset=utf-8",
+	".json":    "application/json",
+}
+
+// A Target is a location in a bucket files are uploaded to, given as
+// s3://bucket/prefix or gs://bucket/prefix.
+type Target struct {
+	Scheme string
+	Bucket string
+	Prefix string
+}
+
+// Parse parses the target location s.
+func Parse(s string) (*Target, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "s3" && u.Scheme != "gs" {
+		return nil, fmt.Errorf("invalid upload target %q, want s3://bucket/prefix or gs://bucket/prefix", s)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid upload target %q, missing bucket", s)
+	}
+	return &Target{
+		Scheme: u.Scheme,
+		Bucket: u.Host,
+		Prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+// Upload uploads the file at name to t, naming it by the SHA-256 of its
+// contents with its extension, and returns the location of the object.
+func (t *Target) Upload(ctx context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	ext := filepath.Ext(name)
+	key := path.Join(t.Prefix, hex.EncodeToString(sum[:])+ext)
+	contentType := contentTypes[ext]
+	if contentType == "" {
+		contentType = mime.TypeByExtension(ext)
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	var req *http.Request
+	switch t.Scheme {
+	case "s3":
+		req, err = s3Request(ctx, t.Bucket, key, data, sum[:])
+	case "gs":
+		req, err = gcsRequest(ctx, t.Bucket, key, data)
+	}
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("uploading %s: %s: %s", name, resp.Status, bytes.TrimSpace(msg))
+	}
+	return t.Scheme + "://" + t.Bucket + "/" + key, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runPostCmd runs the -postcmd command on a rendered output file, passing
+// its path as last argument. The command may rewrite the file in place.
+func runPostCmd(ctx context.Context, path string) error {
+	args := strings.Fields(*postCmd)
+	if len(args) == 0 {
+		return nil
+	}
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], path)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("postcmd %q: %v", *postCmd, err)
+	}
+	return nil
+}
+
+// postProcess runs the -postcmd command on output rendered in server mode,
+// through a temporary file with the extension of the format, and returns
+// the file contents afterwards.
+func postProcess(ctx context.Context, data []byte, format string) ([]byte, error) {
+	if *postCmd == "" {
+		return data, nil
+	}
+	f, err := os.CreateTemp("", "go-callvis-*."+format)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := runPostCmd(ctx, f.Name()); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(f.Name())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	runtimepprof "runtime/pprof"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// startProfiling starts the CPU profile of -cpuprofile. The returned
+// function stops it and writes the heap profile of -memprofile.
+func startProfiling() func() {
+	var cpu *os.File
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			logger.LogFatal(err.Error())
+		}
+		if err := runtimepprof.StartCPUProfile(f); err != nil {
+			logger.LogFatal(err.Error())
+		}
+		cpu = f
+	}
+
+	return func() {
+		if cpu != nil {
+			runtimepprof.StopCPUProfile()
+			cpu.Close()
+			logger.LogInfo("CPU profile written to %s", *cpuProfile)
+		}
+		if *memProfile != "" {
+			f, err := os.Create(*memProfile)
+			if err != nil {
+				logger.LogFatal(err.Error())
+			}
+			defer f.Close()
+			runtime.GC()
+			if err := runtimepprof.WriteHeapProfile(f); err != nil {
+				logger.LogFatal(err.Error())
+			}
+			logger.LogInfo("memory profile written to %s", *memProfile)
+		}
+	}
+}
+
+// handlePprof serves the runtime profiles under /debug/pprof/.
+func handlePprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/output"
+	"github.com/ofabry/go-callvis/pkg/reach"
+	"golang.org/x/tools/go/callgraph"
+)
+
+// cmdReach reports the security sensitive functions the entry points of
+// the package reach instead of rendering the whole graph, for security
+// reviews.
+const cmdReach = "reach"
+
+var sinksFlag = flag.String("sinks", "", fmt.Sprintf("Sink sets reported by go-callvis reach, all by default [%s] (separated by comma).", strings.Join(reach.Names(), " | ")))
+
+// loadSinks returns the sink sets of -sinks.
+func loadSinks() ([]*reach.Set, error) {
+	return reach.Lookup(analysis.SplitList(*sinksFlag))
+}
+
+// runReach prints the sinks of sets reached from the entry points of the
+// analyzed packages, each with the entry points reaching it, and renders
+// the call paths connecting them to -file, the sinks highlighted.
+func runReach(ctx context.Context, a *analysis.Analysis, sets []*reach.Set) error {
+	r := reach.Find(a.Index().Graph(), reach.Entries(a.Packages()), sets)
+	if err := printReach(os.Stdout, r); err != nil {
+		return err
+	}
+	if *outputFile == "" || len(r.Findings) == 0 {
+		return nil
+	}
+
+	var sinks []string
+	for _, f := range r.Findings {
+		sinks = append(sinks, f.Sink.String())
+	}
+	a.NodeFilters = append(a.NodeFilters, output.NodeFilterFunc(func(n *callgraph.Node) bool {
+		return r.Paths[n.Func]
+	}))
+	a.PrintOptions["highlight"] = strings.Join(sinks, ",")
+	return outputDot(ctx, a, *outputFile, *outputFormat)
+}
+
+func printReach(w io.Writer, r *reach.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range r.Findings {
+		entries := make([]string, len(f.Entries))
+		for i, fn := range f.Entries {
+			entries[i] = fn.String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Set.Name, f.Sink, funcPosition(f.Sink), strings.Join(entries, ", "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d sinks reached\n", len(r.Findings))
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/ssa"
+)
+
+var recursionReport = flag.String("recursion-report", "", "Write the directly and mutually recursive functions of the package to this file, - for stdout, instead of rendering.")
+
+// writeRecursion writes the groups of recursive functions involving the
+// analyzed packages to path, or stdout for -, one function per line: the
+// functions calling themselves as direct, those calling each other in a
+// cycle as mutual, the functions of a group following each other.
+func writeRecursion(a *analysis.Analysis, path string) error {
+	analyzed := make(map[*ssa.Package]bool)
+	for _, p := range a.Packages() {
+		if p != nil {
+			analyzed[p] = true
+		}
+	}
+	var groups [][]*ssa.Function
+	for _, g := range output.RecursiveGroups(a.Index().Graph()) {
+		if slices.ContainsFunc(g, func(fn *ssa.Function) bool { return analyzed[fn.Pkg] }) {
+			groups = append(groups, g)
+		}
+	}
+
+	if path == "-" {
+		return printRecursion(os.Stdout, groups)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := printRecursion(f, groups); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printRecursion(w io.Writer, groups [][]*ssa.Function) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, g := range groups {
+		kind := "direct"
+		if len(g) > 1 {
+			kind = fmt.Sprintf("mutual (%d)", len(g))
+		}
+		for _, fn := range g {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", kind, fn, funcPosition(fn))
+			kind = ""
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d recursive groups\n", len(groups))
+	return err
+}
+
+// funcPosition returns the file and line declaring fn, the file relative
+// to the working directory if below it.
+func funcPosition(fn *ssa.Function) string {
+	if fn.Prog == nil || !fn.Pos().IsValid() {
+		return ""
+	}
+	pos := fn.Prog.Fset.Position(fn.Pos())
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, pos.Filename); err == nil && filepath.IsLocal(rel) {
+			pos.Filename = rel
+		}
+	}
+	return fmt.Sprintf("%s:%d", pos.Filename, pos.Line)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+var remoteFlag = flag.String("remote", "", "Analyze a module without a local checkout, e.g. example.com/mod@v1.2.3, taking packages relative to its root, e.g. ./...")
+
+// remoteModule prepares the analysis of the module spec, given as
+// path@version or only path for its latest version: it is downloaded into
+// the module cache and required by a temporary module in dir, to resolve
+// its dependencies. The packages relative to the module root in args are
+// returned as import paths. remove deletes the temporary module.
+func remoteModule(ctx context.Context, spec string, args []string) (dir string, pkgs []string, remove func() error, err error) {
+	modPath, version, _ := strings.Cut(spec, "@")
+	if modPath == "" {
+		return "", nil, nil, fmt.Errorf("invalid remote module %q, want path@version", spec)
+	}
+	if version == "" {
+		version = "latest"
+	}
+
+	tmp, err := os.MkdirTemp("", "go-callvis-remote-")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	remove = func() error { return os.RemoveAll(tmp) }
+	if _, err := goCmd(ctx, tmp, "mod", "init", "go-callvis-remote"); err != nil {
+		remove()
+		return "", nil, nil, err
+	}
+	if _, err := goCmd(ctx, tmp, "get", modPath+"@"+version); err != nil {
+		remove()
+		return "", nil, nil, err
+	}
+	if out, err := goCmd(ctx, tmp, "list", "-m", "-f", "{{.Version}}", modPath); err == nil {
+		logger.LogDebug("analyzing %s@%s", modPath, strings.TrimSpace(string(out)))
+	}
+
+	for _, arg := range args {
+		if arg == "." || strings.HasPrefix(arg, "./") {
+			arg = path.Join(modPath, arg)
+		}
+		pkgs = append(pkgs, arg)
+	}
+	return tmp, pkgs, remove, nil
+}
+
+func goCmd(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("go %s: %v", args[0], err)
+	}
+	return out, nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"maps"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/hierarchy"
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"golang.org/x/tools/go/ssa"
+)
+
+var stdioFlag = flag.Bool("stdio", false, "Serve JSON-RPC 2.0 on stdin and stdout for editor extensions instead of analyzing a package (see README).")
+
+// JSON-RPC error codes, the last being specific to go-callvis.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcNotAnalyzed    = -32001
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcSession is the state of a client: the analysis of the last analyze
+// request and the packages in focus.
+type rpcSession struct {
+	a     *analysis.Analysis
+	focus []string
+}
+
+// serveStdio answers JSON-RPC requests framed by Content-Length headers,
+// as in the Language Server Protocol, until stdin is closed. Requests are
+// handled in order.
+func serveStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	s := &rpcSession{}
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp := rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null")}
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			resp.Error = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else if req.JSONRPC != "2.0" || req.Method == "" {
+			resp.Error = &rpcError{Code: rpcInvalidRequest, Message: "invalid JSON-RPC 2.0 request"}
+		} else {
+			result, err := s.handle(ctx, req.Method, req.Params)
+			if req.ID == nil {
+				// notifications get no response
+				if err != nil {
+					logger.LogError("%s: %v", req.Method, err)
+				}
+				continue
+			}
+			resp.ID = req.ID
+			resp.Result = result
+			if err != nil {
+				var rerr *rpcError
+				if !errors.As(err, &rerr) {
+					rerr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+				}
+				resp.Error = rerr
+			}
+		}
+		if err := writeMessage(out, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// readMessage reads the body of the next message, io.EOF if there is none.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading message header: %v", err)
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading message: %v", err)
+	}
+	return body, nil
+}
+
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (s *rpcSession) handle(ctx context.Context, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "analyze":
+		var p struct {
+			Packages []string `json:"packages"`
+			Dir      string   `json:"dir"`
+			Tests    *bool    `json:"tests"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.analyze(ctx, p.Dir, p.Packages, p.Tests)
+	case "focus":
+		var p struct {
+			Packages []string `json:"packages"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		s.focus = p.Packages
+		return map[string]any{"packages": s.focus}, nil
+	case "querySubgraph":
+		var p struct {
+			Symbol    string `json:"symbol"`
+			Direction string `json:"direction"`
+			Depth     int    `json:"depth"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.querySubgraph(p.Symbol, p.Direction, p.Depth)
+	case "renderSVG":
+		var p struct {
+			Params map[string]string `json:"params"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.renderSVG(ctx, p.Params)
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// analyze analyzes the packages, replacing the analysis of the session.
+func (s *rpcSession) analyze(ctx context.Context, dir string, pkgs []string, tests *bool) (any, error) {
+	if len(pkgs) == 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "no packages to analyze"}
+	}
+	a, err := newAnalysis(dir, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	withTests := *testFlag
+	if tests != nil {
+		withTests = *tests
+	}
+	if err := a.DoAnalysis(ctx, dir, withTests, pkgs); err != nil {
+		return nil, err
+	}
+	s.a = a
+
+	var paths []string
+	for _, p := range a.Packages() {
+		if p != nil {
+			paths = append(paths, p.Pkg.Path())
+		}
+	}
+	slices.Sort(paths)
+	return map[string]any{
+		"packages":  slices.Compact(paths),
+		"functions": len(a.Index().Graph().Nodes),
+	}, nil
+}
+
+type subgraphEdge struct {
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Ranges []hierarchy.Range `json:"ranges"`
+}
+
+// querySubgraph returns the functions up to depth calls away from the
+// functions named by sym, in the given direction, and the calls between
+// them.
+func (s *rpcSession) querySubgraph(sym, direction string, depth int) (any, error) {
+	if s.a == nil {
+		return nil, &rpcError{Code: rpcNotAnalyzed, Message: "no analysis, call analyze first"}
+	}
+	index := s.a.Index()
+	callers, callees := true, true
+	switch direction {
+	case "", "both":
+	case "callers":
+		callees = false
+	case "callees":
+		callers = false
+	default:
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("invalid direction %q, must be callers, callees or both", direction)}
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	fns := index.Lookup(sym)
+	if len(fns) == 0 {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: fmt.Sprintf("no function %s", sym)}
+	}
+
+	in := make(map[*ssa.Function]bool)
+	for _, fn := range fns {
+		in[fn] = true
+	}
+	frontier := fns
+	for range depth {
+		var next []*ssa.Function
+		visit := func(related []*ssa.Function) {
+			for _, fn := range related {
+				if !in[fn] {
+					in[fn] = true
+					next = append(next, fn)
+				}
+			}
+		}
+		for _, fn := range frontier {
+			if callers {
+				visit(index.Callers(fn))
+			}
+			if callees {
+				visit(index.Callees(fn))
+			}
+		}
+		frontier = next
+	}
+
+	nodes := []hierarchy.Item{}
+	edges := []subgraphEdge{}
+	for _, e := range hierarchy.Of(index.Graph(), slices.Collect(maps.Keys(in))) {
+		nodes = append(nodes, e.Item)
+		for _, c := range e.Outgoing {
+			if slices.ContainsFunc(index.Lookup(c.To.Data.Symbol), func(fn *ssa.Function) bool { return in[fn] }) {
+				edges = append(edges, subgraphEdge{From: e.Item.Data.Symbol, To: c.To.Data.Symbol, Ranges: c.FromRanges})
+			}
+		}
+	}
+	return map[string]any{"nodes": nodes, "edges": edges}, nil
+}
+
+// renderSVG renders the analysis as SVG, with the focus of the session and
+// params named like those of the interactive viewer.
+func (s *rpcSession) renderSVG(ctx context.Context, params map[string]string) (any, error) {
+	if s.a == nil {
+		return nil, &rpcError{Code: rpcNotAnalyzed, Message: "no analysis, call analyze first"}
+	}
+	a := s.a.Clone()
+	values := url.Values{}
+	if len(s.focus) > 0 {
+		values.Set("f", strings.Join(s.focus, ","))
+	}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	if err := a.OverrideByParams(values); err != nil {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRenderSize(a, g); err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	if err := g.WriteDot(&output); err != nil {
+		return nil, err
+	}
+	renderCtx, cancel := renderContext(ctx)
+	defer cancel()
+	svg, err := dot.RenderImage(renderCtx, *graphvizFlag, "svg", output.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"svg": string(svg)}, nil
+}
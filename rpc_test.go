@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestServeStdio(t *testing.T) {
+	var in bytes.Buffer
+	for _, body := range []string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "focus", "params": {"packages": ["example.com/p"]}}`,
+		`{"jsonrpc": "2.0", "method": "focus", "params": {"packages": []}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "querySubgraph", "params": {"symbol": "p.F"}}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "unknown"}`,
+		`{"jsonrpc": "1.0", "id": 4, "method": "focus"}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "focus", "params": {"packages": "p"}}`,
+		`{not json`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	var out bytes.Buffer
+	if err := serveStdio(context.Background(), &in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", 0},
+		// the notification gets no response
+		{"2", rpcNotAnalyzed},
+		{"3", rpcMethodNotFound},
+		{"null", rpcInvalidRequest},
+		{"5", rpcInvalidParams},
+		{"null", rpcParseError},
+	}
+	r := bufio.NewReader(&out)
+	for _, w := range want {
+		body, err := readMessage(r)
+		if err != nil {
+			t.Fatalf("reading the response %s: %v", w.id, err)
+		}
+		var resp struct {
+			ID     json.RawMessage `json:"id"`
+			Result json.RawMessage `json:"result"`
+			Error  *rpcError       `json:"error"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			t.Fatal(err)
+		}
+		code := 0
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		if string(resp.ID) != w.id || code != w.code {
+			t.Errorf("response %s has error %d, want %s with %d: %s", resp.ID, code, w.id, w.code, body)
+		}
+		if w.id == "1" && !strings.Contains(string(resp.Result), "example.com/p") {
+			t.Errorf("focus result %s lacks the package", resp.Result)
+		}
+	}
+	if body, err := readMessage(r); err == nil {
+		t.Errorf("unexpected response %s", body)
+	}
+}
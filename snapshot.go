@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// snapshotFlags are the flags naming files read by the options, whose
+// changes invalidate the snapshots of the graph.
+var snapshotFlags = []string{
+	"config", "presetfile", "theme", "focus-file", "overlay",
+	"heatfile", "profile", "exectrace", "findings", "otel-spans",
+}
+
+// snapshotKey returns the key of the snapshot of the graph rendered to
+// -file by the command, or "" if the graph is not snapshotted. Only plain
+// renders are, with caching enabled, of the local sources and without
+// options depending on more than the files of the snapshot, like the
+// commit and time of -footer or the revisions of -git-changed.
+func snapshotKey(a *analysis.Analysis, command, dir string, args []string) string {
+	switch {
+	case command != "" && command != cmdRender && command != cmdExport,
+		*outputFile == "", *watchFlag, *statsFlag,
+		*hierarchyFlag != "", *recursionReport != "", *unusedReport != "",
+		dir != "", footer, *gitChangedFlag != "":
+		return ""
+	}
+	cache, err := cachePath()
+	if err != nil || cache == "" {
+		return ""
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	var options []string
+	flag.VisitAll(func(f *flag.Flag) {
+		options = append(options, "-"+f.Name+"="+f.Value.String())
+	})
+	return a.SnapshotKey(Version(), wd, args, append(options, command))
+}
+
+// snapshotFiles returns the files named by the snapshotFlags.
+func snapshotFiles() []string {
+	var files []string
+	for _, name := range snapshotFlags {
+		if f := flag.Lookup(name); f != nil && f.Value.String() != "" && f.Value.String() != "-" {
+			files = append(files, f.Value.String())
+		}
+	}
+	return files
+}
+
+// renderSnapshot writes the graph snapshotted under key to -file, if
+// there is a snapshot and none of its files changed. It reports whether
+// the graph was written, sparing the analysis.
+func renderSnapshot(ctx context.Context, a *analysis.Analysis, key string) (bool, error) {
+	if key == "" {
+		return false, nil
+	}
+	g := a.LoadSnapshot(key)
+	if g == nil {
+		return false, nil
+	}
+	logger.LogDebug("using snapshot %s of the graph", key)
+	return true, writeOutput(ctx, nil, g, *outputFile, *outputFormat)
+}
+
+// renderOutput writes the graph of the analysis to -file, taking a
+// snapshot of it under key if set.
+func renderOutput(ctx context.Context, a *analysis.Analysis, key string) error {
+	if key == "" {
+		return outputDot(ctx, a, *outputFile, *outputFormat)
+	}
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return err
+	}
+	if err := a.SaveSnapshot(key, g, snapshotFiles()); err != nil {
+		logger.LogWarn("saving snapshot: %v", err)
+	}
+	return writeOutput(ctx, a, g, *outputFile, *outputFormat)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+var statsFlag = flag.Bool("stats", false, "Print the node and edge counts of the graph before and after the filters, per package, and its projected size instead of rendering it.")
+
+// pkgStats are the counts of a package before and after the filters.
+type pkgStats struct {
+	path         string
+	funcs, nodes int
+}
+
+// runStats builds the graph of the analysis without rendering it, and
+// prints its size before and after the filters to stdout, to tune the
+// filters before waiting on the render of a large graph. With -format json
+// or html, it writes the statsReport of the graph instead.
+func runStats(ctx context.Context, a *analysis.Analysis) error {
+	g, err := a.BuildGraph(ctx, a.Minlen, a.PrintOptions)
+	if err != nil {
+		return err
+	}
+	if *outputFormat == statsJSON || *outputFormat == statsHTML {
+		return writeStats(newStatsReport(a, g), *outputFormat)
+	}
+
+	pkgs := make(map[string]*pkgStats)
+	var pkgOf = func(path string) *pkgStats {
+		s, ok := pkgs[path]
+		if !ok {
+			s = &pkgStats{path: path}
+			pkgs[path] = s
+		}
+		return s
+	}
+	// output nodes are identified by their function
+	funcPkg := make(map[string]string)
+	funcs, edges := 0, 0
+	for fn, n := range a.Index().Graph().Nodes {
+		if fn == nil || fn.Pkg == nil {
+			continue
+		}
+		path := fn.Pkg.Pkg.Path()
+		funcPkg[fn.String()] = path
+		pkgOf(path).funcs++
+		funcs++
+		edges += len(n.Out)
+	}
+	nodes := 0
+	walkNodes(g, func(n *dot.DotNode) {
+		nodes++
+		path, ok := funcPkg[n.ID]
+		if !ok {
+			// nodes summarizing others, like those of -maxnodes
+			path = "(other)"
+		}
+		pkgOf(path).nodes++
+	})
+
+	// the size of the DOT source Graphviz would get
+	var size countWriter
+	if err := g.WriteDot(&size); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "\tbefore filters\tafter filters\t\n")
+	fmt.Fprintf(w, "nodes\t%d\t%d\t\n", funcs, nodes)
+	fmt.Fprintf(w, "edges\t%d\t%d\t\n", edges, len(g.Edges))
+	fmt.Fprintf(w, "packages\t%d\t%d\t\n", len(pkgs), countIf(pkgs, func(s *pkgStats) bool { return s.nodes > 0 }))
+	fmt.Fprintf(w, "dot source\t\t%s\t\n", formatBytes(int64(size)))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// the packages left by the filters come first, the largest first
+	list := slices.SortedFunc(maps.Values(pkgs), func(x, y *pkgStats) int {
+		return cmp.Or(
+			cmp.Compare(y.nodes, x.nodes),
+			cmp.Compare(y.funcs, x.funcs),
+			cmp.Compare(x.path, y.path),
+		)
+	})
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "package\tfuncs\tnodes\n")
+	for _, s := range list {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", s.path, s.funcs, s.nodes)
+	}
+	return w.Flush()
+}
+
+// writeStats writes the report in the format to -file with the format as
+// extension, or to stdout without -file or for -.
+func writeStats(r *statsReport, format string) error {
+	if *outputFile == "" || *outputFile == "-" {
+		return writeStatsReport(os.Stdout, r, format)
+	}
+	f, err := os.Create(*outputFile + "." + format)
+	if err != nil {
+		return err
+	}
+	if err := writeStatsReport(f, r, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func countIf(pkgs map[string]*pkgStats, f func(s *pkgStats) bool) int {
+	n := 0
+	for _, s := range pkgs {
+		if f(s) {
+			n++
+		}
+	}
+	return n
+}
+
+// countWriter counts the bytes written to it.
+type countWriter int64
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	*w += countWriter(len(p))
+	return len(p), nil
+}
+
+// formatBytes formats n bytes in the largest fitting unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Formats of the stats report, besides the text tables.
+const (
+	statsText = "text"
+	statsJSON = "json"
+	statsHTML = "html"
+)
+
+// statsTop is the number of functions listed by fan-in and by fan-out.
+const statsTop = 10
+
+// statsReport is the health summary of the graph written by stats -format
+// json or html, computed on the functions and calls left by the filters.
+type statsReport struct {
+	Package   string       `json:"package"`
+	Functions int          `json:"functions"`
+	Calls     int          `json:"calls"`
+	TopFanIn  []funcDegree `json:"topFanIn"`
+	TopFanOut []funcDegree `json:"topFanOut"`
+	// LongestChain is the longest chain of calls between distinct
+	// functions, leaving out the calls within groups of recursive
+	// functions.
+	LongestChain []string `json:"longestChain"`
+	// AverageDepth is the mean number of calls leading to the functions
+	// from the functions without callers, and from the groups of
+	// recursive functions without callers from outside.
+	AverageDepth float64 `json:"averageDepth"`
+	// Cycles is the number of groups of recursive functions, see
+	// output.RecursiveGroups.
+	Cycles   int          `json:"cycles"`
+	Packages []pkgDensity `json:"packages"`
+}
+
+type funcDegree struct {
+	Func  string `json:"func"`
+	Count int    `json:"count"`
+}
+
+// pkgDensity are the calls within a package, relative to the calls its
+// functions could make to each other, and the calls leaving it, recursive
+// calls of a function to itself left out.
+type pkgDensity struct {
+	Path     string  `json:"path"`
+	Funcs    int     `json:"funcs"`
+	Calls    int     `json:"calls"`
+	Outgoing int     `json:"outgoing"`
+	Density  float64 `json:"density"`
+}
+
+// newStatsReport computes the report of g, built by a.
+func newStatsReport(a *analysis.Analysis, g *dot.DotGraph) *statsReport {
+	// the call graph of the functions left by the filters, summary nodes
+	// like those of -maxnodes left out
+	sub := &callgraph.Graph{Nodes: make(map[*ssa.Function]*callgraph.Node)}
+	funcOf := make(map[*dot.DotNode]*ssa.Function)
+	for _, n := range g.AllNodes() {
+		if fns := a.Index().Lookup(n.ID); len(fns) > 0 && fns[0].Pkg != nil {
+			funcOf[n] = fns[0]
+			sub.CreateNode(fns[0])
+		}
+	}
+	calls := 0
+	for _, e := range g.Edges {
+		from, to := funcOf[e.From], funcOf[e.To]
+		if from != nil && to != nil {
+			callgraph.AddEdge(sub.CreateNode(from), nil, sub.CreateNode(to))
+			calls++
+		}
+	}
+	funcs := slices.SortedFunc(maps.Keys(sub.Nodes), func(x, y *ssa.Function) int {
+		return cmp.Compare(x.String(), y.String())
+	})
+
+	r := &statsReport{Functions: len(funcs), Calls: calls}
+	if pkg := a.Packages(); len(pkg) > 0 && pkg[0] != nil {
+		r.Package = pkg[0].Pkg.Path()
+	}
+	r.TopFanIn = topDegrees(funcs, func(fn *ssa.Function) int { return len(sub.Nodes[fn].In) })
+	r.TopFanOut = topDegrees(funcs, func(fn *ssa.Function) int { return len(sub.Nodes[fn].Out) })
+
+	groups := output.RecursiveGroups(sub)
+	r.Cycles = len(groups)
+	// dropping the calls within the groups leaves no cycle
+	group := make(map[*ssa.Function]int)
+	for i, g := range groups {
+		for _, fn := range g {
+			group[fn] = i + 1
+		}
+	}
+	acyclic := func(e *callgraph.Edge) bool {
+		gi := group[e.Caller.Func]
+		return gi == 0 || gi != group[e.Callee.Func]
+	}
+	// the entries are the functions without callers and the groups called
+	// from no other function
+	called := make(map[*ssa.Function]bool)
+	for _, fn := range funcs {
+		for _, e := range sub.Nodes[fn].Out {
+			if acyclic(e) {
+				called[e.Callee.Func] = true
+				for _, peer := range groupFuncs(groups, group[e.Callee.Func]) {
+					called[peer] = true
+				}
+			}
+		}
+	}
+	var entries []*ssa.Function
+	for _, fn := range funcs {
+		if !called[fn] {
+			entries = append(entries, fn)
+		}
+	}
+	r.LongestChain = longestChain(funcs, sub, acyclic)
+	r.AverageDepth = averageDepth(entries, sub)
+	r.Packages = packageDensities(sub)
+	return r
+}
+
+// topDegrees returns the statsTop functions with the largest degree, ties
+// broken by name.
+func topDegrees(funcs []*ssa.Function, degree func(fn *ssa.Function) int) []funcDegree {
+	sorted := slices.SortedStableFunc(slices.Values(funcs), func(x, y *ssa.Function) int {
+		return cmp.Compare(degree(y), degree(x))
+	})
+	top := []funcDegree{}
+	for _, fn := range sorted[:min(len(sorted), statsTop)] {
+		if d := degree(fn); d > 0 {
+			top = append(top, funcDegree{fn.String(), d})
+		}
+	}
+	return top
+}
+
+// longestChain returns the longest path of calls through the edges kept
+// by follow, which must leave no cycle.
+func longestChain(funcs []*ssa.Function, cg *callgraph.Graph, follow func(e *callgraph.Edge) bool) []string {
+	length := make(map[*ssa.Function]int)
+	next := make(map[*ssa.Function]*ssa.Function)
+	var visit func(fn *ssa.Function) int
+	visit = func(fn *ssa.Function) int {
+		if l, ok := length[fn]; ok {
+			return l
+		}
+		length[fn] = 1
+		for _, e := range cg.Nodes[fn].Out {
+			if !follow(e) {
+				continue
+			}
+			if l := visit(e.Callee.Func) + 1; l > length[fn] {
+				length[fn] = l
+				next[fn] = e.Callee.Func
+			}
+		}
+		return length[fn]
+	}
+	var start *ssa.Function
+	for _, fn := range funcs {
+		if start == nil || visit(fn) > length[start] {
+			start = fn
+		}
+	}
+	chain := []string{}
+	for fn := start; fn != nil; fn = next[fn] {
+		chain = append(chain, fn.String())
+	}
+	return chain
+}
+
+// groupFuncs returns the functions of the group numbered i, counting from
+// 1, none for 0.
+func groupFuncs(groups [][]*ssa.Function, i int) []*ssa.Function {
+	if i == 0 {
+		return nil
+	}
+	return groups[i-1]
+}
+
+// averageDepth returns the mean of the smallest number of calls leading to
+// each function from the entries.
+func averageDepth(entries []*ssa.Function, cg *callgraph.Graph) float64 {
+	depth := make(map[*ssa.Function]int)
+	queue := slices.Clone(entries)
+	for _, fn := range entries {
+		depth[fn] = 0
+	}
+	for len(queue) > 0 {
+		fn := queue[0]
+		queue = queue[1:]
+		for _, e := range cg.Nodes[fn].Out {
+			if _, ok := depth[e.Callee.Func]; !ok {
+				depth[e.Callee.Func] = depth[fn] + 1
+				queue = append(queue, e.Callee.Func)
+			}
+		}
+	}
+	if len(depth) == 0 {
+		return 0
+	}
+	total := 0
+	for _, d := range depth {
+		total += d
+	}
+	return float64(total) / float64(len(depth))
+}
+
+// packageDensities returns the densities of the packages of cg, the
+// packages with the most functions first.
+func packageDensities(cg *callgraph.Graph) []pkgDensity {
+	pkgs := make(map[string]*pkgDensity)
+	for fn, n := range cg.Nodes {
+		path := fn.Pkg.Pkg.Path()
+		p, ok := pkgs[path]
+		if !ok {
+			p = &pkgDensity{Path: path}
+			pkgs[path] = p
+		}
+		p.Funcs++
+		for _, e := range n.Out {
+			if e.Callee.Func == fn {
+				continue // not counted by the density
+			}
+			if e.Callee.Func.Pkg == fn.Pkg {
+				p.Calls++
+			} else {
+				p.Outgoing++
+			}
+		}
+	}
+	list := []pkgDensity{}
+	for _, p := range pkgs {
+		if p.Funcs > 1 {
+			p.Density = float64(p.Calls) / float64(p.Funcs*(p.Funcs-1))
+		}
+		list = append(list, *p)
+	}
+	slices.SortFunc(list, func(x, y pkgDensity) int {
+		return cmp.Or(cmp.Compare(y.Funcs, x.Funcs), cmp.Compare(x.Path, y.Path))
+	})
+	return list
+}
+
+// writeStatsReport writes r to w in the format, json or html.
+func writeStatsReport(w io.Writer, r *statsReport, format string) error {
+	if format == statsJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+	return statsPage.Execute(w, r)
+}
+
+var statsPage = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>go-callvis stats: {{.Package}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { padding: 0.3em 1em; text-align: left; border-bottom: 1px solid #ddd; }
+td.num { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Package}}</h1>
+<table>
+<tr><td>Functions</td><td class="num">{{.Functions}}</td></tr>
+<tr><td>Calls</td><td class="num">{{.Calls}}</td></tr>
+<tr><td>Longest call chain</td><td class="num">{{len .LongestChain}}</td></tr>
+<tr><td>Average depth</td><td class="num">{{printf "%.2f" .AverageDepth}}</td></tr>
+<tr><td>Cycles</td><td class="num">{{.Cycles}}</td></tr>
+</table>
+<h2>Top fan-in</h2>
+<table>
+<tr><th>Function</th><th>Callers</th></tr>
+{{- range .TopFanIn}}
+<tr><td>{{.Func}}</td><td class="num">{{.Count}}</td></tr>
+{{- end}}
+</table>
+<h2>Top fan-out</h2>
+<table>
+<tr><th>Function</th><th>Callees</th></tr>
+{{- range .TopFanOut}}
+<tr><td>{{.Func}}</td><td class="num">{{.Count}}</td></tr>
+{{- end}}
+</table>
+<h2>Longest call chain</h2>
+<ol>
+{{- range .LongestChain}}
+<li>{{.}}</li>
+{{- end}}
+</ol>
+<h2>Packages</h2>
+<table>
+<tr><th>Package</th><th>Functions</th><th>Calls within</th><th>Calls out</th><th>Density</th></tr>
+{{- range .Packages}}
+<tr><td>{{.Path}}</td><td class="num">{{.Funcs}}</td><td class="num">{{.Calls}}</td><td class="num">{{.Outgoing}}</td><td class="num">{{printf "%.3f" .Density}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
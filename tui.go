@@ -0,0 +1,348 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/logger"
+	"github.com/ofabry/go-callvis/pkg/output"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/ssa"
+)
+
+// cmdTUI browses the call graph in the terminal.
+const cmdTUI = "tui"
+
+// runTUI browses the call graph of the analysis in the terminal until the
+// user quits. Logs are discarded meanwhile, they would garble the screen.
+func runTUI(ctx context.Context, a *analysis.Analysis) error {
+	logger.SetOutput(io.Discard)
+	defer logger.SetOutput(os.Stderr)
+
+	m := newTUIModel(ctx, a)
+	_, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
+
+var (
+	tuiTitle    = lipgloss.NewStyle().Bold(true)
+	tuiSelected = lipgloss.NewStyle().Reverse(true)
+	tuiFaint    = lipgloss.NewStyle().Faint(true)
+)
+
+// tuiRow is a row of the call tree: a caller or callee of the function of
+// its parent row, or of the root.
+type tuiRow struct {
+	fn       *ssa.Function
+	depth    int
+	callers  bool
+	expanded bool
+}
+
+// tuiModel is the state of the terminal UI: searching a function, or
+// browsing the call tree of the chosen one.
+type tuiModel struct {
+	ctx   context.Context
+	a     *analysis.Analysis
+	funcs []*ssa.Function
+
+	searching bool
+	query     string
+	matches   []*ssa.Function
+
+	root *ssa.Function
+	rows []tuiRow
+
+	cursor, offset int
+	height         int
+	status         string
+}
+
+func newTUIModel(ctx context.Context, a *analysis.Analysis) *tuiModel {
+	var funcs []*ssa.Function
+	for fn := range a.Index().Graph().Nodes {
+		if fn != nil && fn.Pkg != nil {
+			funcs = append(funcs, fn)
+		}
+	}
+	slices.SortFunc(funcs, func(x, y *ssa.Function) int { return cmp.Compare(x.String(), y.String()) })
+	m := &tuiModel{ctx: ctx, a: a, funcs: funcs, searching: true, height: 24}
+	m.search()
+	return m
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		m.status = ""
+		if m.searching {
+			return m, m.updateSearch(msg)
+		}
+		return m, m.updateTree(msg)
+	}
+	return m, nil
+}
+
+func (m *tuiModel) updateSearch(msg tea.KeyMsg) tea.Cmd {
+	switch msg.Type {
+	case tea.KeyEsc:
+		return tea.Quit
+	case tea.KeyUp:
+		m.move(-1, len(m.matches))
+	case tea.KeyDown:
+		m.move(1, len(m.matches))
+	case tea.KeyEnter:
+		if len(m.matches) > 0 {
+			m.choose(m.matches[m.cursor])
+		}
+	case tea.KeyBackspace:
+		if m.query != "" {
+			r := []rune(m.query)
+			m.query = string(r[:len(r)-1])
+			m.search()
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.query += string(msg.Runes)
+		m.search()
+	}
+	return nil
+}
+
+func (m *tuiModel) updateTree(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q":
+		return tea.Quit
+	case "esc", "/":
+		m.searching = true
+		m.cursor, m.offset = 0, 0
+		m.search()
+	case "up", "k":
+		m.move(-1, len(m.rows))
+	case "down", "j":
+		m.move(1, len(m.rows))
+	case "right", "l", " ":
+		m.expand(m.cursor)
+	case "left", "h":
+		m.collapse(m.cursor)
+	case "enter":
+		if len(m.rows) > 0 {
+			m.choose(m.rows[m.cursor].fn)
+		}
+	case "e":
+		m.export()
+	}
+	return nil
+}
+
+// search lists the functions matching the query, best matches first.
+func (m *tuiModel) search() {
+	type match struct {
+		fn    *ssa.Function
+		score int
+	}
+	var matches []match
+	for _, fn := range m.funcs {
+		if score, ok := fuzzyMatch(fn.String(), m.query); ok {
+			matches = append(matches, match{fn, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(x, y match) int { return cmp.Compare(x.score, y.score) })
+	m.matches = m.matches[:0]
+	for _, mt := range matches {
+		m.matches = append(m.matches, mt.fn)
+	}
+	m.cursor, m.offset = 0, 0
+}
+
+// fuzzyMatch reports whether the letters of query appear in order in s,
+// ignoring case, scoring the spread of the match and the length of s,
+// lower being better.
+func fuzzyMatch(s, query string) (int, bool) {
+	s, query = strings.ToLower(s), strings.ToLower(query)
+	start, pos := -1, 0
+	for _, r := range query {
+		i := strings.IndexRune(s[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if start < 0 {
+			start = pos + i
+		}
+		pos += i + len(string(r))
+	}
+	if start < 0 {
+		start = 0
+	}
+	return (pos-start)*4 + len(s), true
+}
+
+// choose makes fn the root of the call tree, showing its direct callers
+// and callees.
+func (m *tuiModel) choose(fn *ssa.Function) {
+	m.searching = false
+	m.root = fn
+	m.rows = nil
+	for _, caller := range m.a.Index().Callers(fn) {
+		m.rows = append(m.rows, tuiRow{fn: caller, callers: true})
+	}
+	for _, callee := range m.a.Index().Callees(fn) {
+		m.rows = append(m.rows, tuiRow{fn: callee})
+	}
+	m.cursor, m.offset = 0, 0
+}
+
+// expand shows the callers or callees of the function of row i below it.
+func (m *tuiModel) expand(i int) {
+	if i >= len(m.rows) || m.rows[i].expanded {
+		return
+	}
+	row := &m.rows[i]
+	row.expanded = true
+	next := m.a.Index().Callees(row.fn)
+	if row.callers {
+		next = m.a.Index().Callers(row.fn)
+	}
+	children := make([]tuiRow, len(next))
+	for j, fn := range next {
+		children[j] = tuiRow{fn: fn, depth: row.depth + 1, callers: row.callers}
+	}
+	m.rows = slices.Insert(m.rows, i+1, children...)
+}
+
+// collapse hides the rows below row i, or moves to its parent row if it is
+// not expanded.
+func (m *tuiModel) collapse(i int) {
+	if i >= len(m.rows) {
+		return
+	}
+	row := m.rows[i]
+	if !row.expanded {
+		for j := i - 1; j >= 0; j-- {
+			if m.rows[j].depth < row.depth {
+				m.cursor = j
+				m.scroll()
+				break
+			}
+		}
+		return
+	}
+	m.rows[i].expanded = false
+	end := i + 1
+	for end < len(m.rows) && m.rows[end].depth > row.depth {
+		end++
+	}
+	m.rows = slices.Delete(m.rows, i+1, end)
+}
+
+// export renders the functions shown in the call tree to -file, the root
+// highlighted.
+func (m *tuiModel) export() {
+	keep := map[*ssa.Function]bool{m.root: true}
+	for _, row := range m.rows {
+		keep[row.fn] = true
+	}
+	c := m.a.Clone()
+	opts := c.Options()
+	opts.Focus = nil
+	if err := c.SetOptions(opts); err != nil {
+		m.status = err.Error()
+		return
+	}
+	c.NodeFilters = append(slices.Clone(c.NodeFilters), output.NodeFilterFunc(func(n *callgraph.Node) bool {
+		return keep[n.Func]
+	}))
+	c.PrintOptions["highlight"] = m.root.String()
+
+	name := *outputFile
+	if name == "" || name == "-" {
+		name = "callvis"
+	}
+	if err := outputDot(m.ctx, c, name, *outputFormat); err != nil {
+		m.status = "export failed: " + err.Error()
+		return
+	}
+	m.status = fmt.Sprintf("exported %d functions to %s.%s", len(keep), name, *outputFormat)
+}
+
+func (m *tuiModel) move(delta, n int) {
+	m.cursor = max(0, min(n-1, m.cursor+delta))
+	m.scroll()
+}
+
+// scroll keeps the cursor row in the visible lines.
+func (m *tuiModel) scroll() {
+	lines := m.lines()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	} else if m.cursor >= m.offset+lines {
+		m.offset = m.cursor - lines + 1
+	}
+}
+
+// lines returns the number of list lines fitting the screen, besides the
+// header and the footer.
+func (m *tuiModel) lines() int {
+	return max(1, m.height-4)
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	var items []string
+	var help string
+	if m.searching {
+		b.WriteString(tuiTitle.Render("search: ") + m.query + "█\n\n")
+		for _, fn := range m.matches {
+			items = append(items, fn.String())
+		}
+		help = fmt.Sprintf("%d functions · ↑↓ move · enter browse · esc quit", len(m.matches))
+	} else {
+		b.WriteString(tuiTitle.Render(m.root.String()) + "\n\n")
+		for _, row := range m.rows {
+			marker := "→ "
+			if row.callers {
+				marker = "← "
+			}
+			toggle := "+ "
+			if row.expanded {
+				toggle = "- "
+			}
+			items = append(items, strings.Repeat("  ", row.depth)+toggle+marker+row.fn.String())
+		}
+		help = "← callers → callees · ↑↓ move · space/→ expand · ← collapse · enter focus · e export · / search · q quit"
+	}
+
+	end := min(len(items), m.offset+m.lines())
+	for i := m.offset; i < end; i++ {
+		if i == m.cursor {
+			b.WriteString(tuiSelected.Render(items[i]))
+		} else {
+			b.WriteString(items[i])
+		}
+		b.WriteByte('\n')
+	}
+	for i := end - m.offset; i < m.lines(); i++ {
+		b.WriteByte('\n')
+	}
+	if m.status != "" {
+		help = m.status
+	}
+	b.WriteString(tuiFaint.Render(help))
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+
+	"github.com/ofabry/go-callvis/analysis"
+)
+
+var unusedReport = flag.String("unused-report", "", "Write the exported functions and methods never called within the module as JSON to this file, - for stdout, instead of rendering.")
+
+// unusedFunc is an entry of the unused report.
+type unusedFunc struct {
+	Func     string `json:"func"`
+	Package  string `json:"package"`
+	Position string `json:"position,omitempty"`
+}
+
+// writeUnused writes the exported API of the module never called within
+// it to path, or stdout for -, as a JSON array sorted by function.
+func writeUnused(a *analysis.Analysis, path string) error {
+	list := []unusedFunc{}
+	for _, fn := range a.UnusedExported() {
+		list = append(list, unusedFunc{
+			Func:     fn.String(),
+			Package:  fn.Pkg.Pkg.Path(),
+			Position: funcPosition(fn),
+		})
+	}
+
+	if path == "-" {
+		return printUnused(os.Stdout, list)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := printUnused(f, list); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printUnused(w io.Writer, list []unusedFunc) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(list)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+	"github.com/ofabry/go-callvis/pkg/upload"
+)
+
+var uploadFlag = flag.String("upload", "", "Upload the rendered files under content-addressed names to object storage, e.g. s3://bucket/prefix or gs://bucket/prefix.")
+
+// uploadTarget is the parsed -upload location, nil if unset.
+var uploadTarget *upload.Target
+
+// publishOutput runs the -postcmd command on the output file rendering g,
+// uploads it to -upload and records it for -webhook.
+func publishOutput(ctx context.Context, path string, g *dot.DotGraph) error {
+	if err := runPostCmd(ctx, path); err != nil {
+		return err
+	}
+	out := webhookOutput{File: path, Nodes: countNodes(g), Edges: len(g.Edges)}
+	if uploadTarget != nil {
+		loc, err := uploadTarget.Upload(ctx, path)
+		if err != nil {
+			return err
+		}
+		log.Printf("uploaded %s to %s", path, loc)
+		out.Location = loc
+	}
+	recordOutput(out)
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/ofabry/go-callvis/pkg/dot"
+)
+
+// version and commit may be set by the linker, like the Makefile does for
+// commit, else they are taken from the build info, which go install
+// records.
+var (
+	version = ""
+	commit  = ""
+)
+
+func init() {
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if version == "" {
+			version = bi.Main.Version
+		}
+		if commit == "" {
+			commit = vcsRevision(bi)
+		}
+	}
+	if version == "" {
+		version = "(devel)"
+	}
+	if commit == "" {
+		commit = "(unknown)"
+	}
+}
+
+// vcsRevision returns the short revision of the sources the binary was
+// built from, marked dirty if they had local changes.
+func vcsRevision(bi *debug.BuildInfo) string {
+	var rev string
+	var modified bool
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+			if len(rev) > 12 {
+				rev = rev[:12]
+			}
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if rev != "" && modified {
+		rev += "-dirty"
+	}
+	return rev
+}
+
+func Version() string {
+	return fmt.Sprintf("%s built from git %s", version, commit)
+}
+
+// printVersion writes the version of the binary to w, with what it can
+// render: by the embedded Graphviz or the dot program, and the text
+// formats compiled in.
+func printVersion(w io.Writer) {
+	graphviz := "embedded (cgo)"
+	if !dot.EmbeddedGraphviz {
+		graphviz = "not embedded"
+	}
+	program := "not found"
+	if path, err := exec.LookPath("dot"); err == nil {
+		program = path
+	}
+	fmt.Fprintf(w, "go-callvis %s\n", Version())
+	fmt.Fprintf(w, "go:       %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(w, "graphviz: %s, dot program %s\n", graphviz, program)
+	fmt.Fprintf(w, "formats:  %s\n", strings.Join(dot.RendererNames(), " "))
+}
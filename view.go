@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfig is the config file read if -config is not set.
+const defaultConfig = ".go-callvis.yaml"
+
+var (
+	configFlag = flag.String("config", "", fmt.Sprintf("YAML config file defining the named views of -view (default %s if present).", defaultConfig))
+	viewFlag   = flag.String("view", "", "Apply the options of a named view defined in the config file, e.g. overview, overridden by the flags set on the command line.")
+)
+
+// config is the config file, holding named views: bundles of flags by
+// name without dash, like
+//
+//	views:
+//	  overview:
+//	    nostd: true
+//	    group: [pkg, type]
+type config struct {
+	Views map[string]map[string]any `yaml:"views"`
+}
+
+// applyView sets the flags of -view which are not set on the command line.
+func applyView() error {
+	if *viewFlag == "" {
+		return nil
+	}
+	path := *configFlag
+	if path == "" {
+		path = defaultConfig
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && *configFlag == "" {
+		return fmt.Errorf("-view needs a config file, %s not found", defaultConfig)
+	} else if err != nil {
+		return err
+	}
+	var cfg config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	view, ok := cfg.Views[*viewFlag]
+	if !ok {
+		names := make([]string, 0, len(cfg.Views))
+		for name := range cfg.Views {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("no view %q in %s, want one of %v", *viewFlag, path, names)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, value := range view {
+		if flag.Lookup(name) == nil || name == "view" || name == "config" {
+			return fmt.Errorf("invalid option %q of view %q in %s", name, *viewFlag, path)
+		}
+		if set[name] {
+			continue
+		}
+		if err := flag.Set(name, flagValue(value)); err != nil {
+			return fmt.Errorf("invalid option %q of view %q in %s: %v", name, *viewFlag, path, err)
+		}
+	}
+	return nil
+}
+
+// flagValue formats a YAML value as flag value, joining lists by comma.
+func flagValue(v any) string {
+	if v == nil {
+		return ""
+	}
+	if list, ok := v.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = fmt.Sprint(item)
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,82 @@
+//go:build js && wasm
+
+// Command wasm runs go-callvis in the browser. It analyzes in-memory
+// sources and returns the DOT output, to be rendered with viz.js:
+//
+//	const {dot, error} = goCallvis(
+//		{"example.com/app/main.go": "package main\n..."},
+//		{focus: "main", group: "pkg,type"},
+//	);
+//
+// Build it with make wasm.
+package main
+
+import (
+	"context"
+	"strings"
+	"syscall/js"
+
+	"github.com/ofabry/go-callvis/pkg/callvis"
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+func main() {
+	logger.InitializeLogger(logger.ErrorLevel)
+	js.Global().Set("goCallvis", js.FuncOf(analyze))
+	// keep the exported function alive
+	select {}
+}
+
+// analyze takes an object mapping file paths to sources and an optional
+// object of options, returning an object with either the dot output or an
+// error.
+func analyze(this js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeObject {
+		return map[string]any{"error": "missing sources object"}
+	}
+	sources := make(map[string][]byte)
+	names := js.Global().Get("Object").Call("keys", args[0])
+	for i := 0; i < names.Length(); i++ {
+		name := names.Index(i).String()
+		sources[name] = []byte(args[0].Get(name).String())
+	}
+
+	var options js.Value
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		options = args[1]
+	}
+	str := func(key string) string {
+		if options.IsUndefined() || options.Get(key).IsUndefined() {
+			return ""
+		}
+		return options.Get(key).String()
+	}
+	list := func(key string) []string {
+		if s := str(key); s != "" {
+			return strings.Split(s, ",")
+		}
+		return nil
+	}
+
+	g, err := callvis.Analyze(context.Background(), callvis.Options{
+		Sources:      sources,
+		Algo:         callvis.Algorithm(str("algo")),
+		Focus:        list("focus"),
+		Group:        list("group"),
+		Limit:        list("limit"),
+		Ignore:       list("ignore"),
+		Include:      list("include"),
+		Filter:       str("filter"),
+		NoInter:      str("nointer") == "true",
+		ExportedOnly: str("exportedonly") == "true",
+		CrossPkg:     str("crosspkg") == "true",
+	})
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	var dot strings.Builder
+	if err := g.RenderDOT(&dot); err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	return map[string]any{"dot": dot.String()}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ofabry/go-callvis/analysis"
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+// reloadScript makes served SVG images reload once the sources were
+// analyzed again.
+const reloadScript = `<script type="text/javascript"><![CDATA[
+new EventSource("/events").onmessage = function() { location.reload(); };
+]]></script>
+`
+
+// reloadHub notifies the open viewers about new analyses using server-sent
+// events.
+type reloadHub struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func newReloadHub() *reloadHub {
+	return &reloadHub{clients: make(map[chan struct{}]bool)}
+}
+
+func (h *reloadHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.clients[ch] = true
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, ch)
+		h.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// broadcast asks all open viewers to reload.
+func (h *reloadHub) broadcast() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watchSources analyzes the sources again whenever they change, replacing
+// the current analysis and reloading the viewers.
+func watchSources(current *atomic.Pointer[analysis.Analysis], hub *reloadHub, tests bool, args []string) {
+	w := analysis.NewSourceWatcher(current.Load().SourceFiles())
+	for {
+		changed, _ := w.Wait(context.Background(), time.Second)
+		logger.LogInfo("%d files changed, analyzing again..", len(changed))
+		next, err := current.Load().Reanalyze(context.Background(), "", tests, args)
+		if err != nil {
+			logger.LogError("analysis failed, keeping the previous one: %v", err)
+			continue
+		}
+		current.Store(next)
+		w.Watch(next.SourceFiles())
+		hub.broadcast()
+	}
+}
+
+// freshAnalysis returns a function returning the current analysis, which
+// first analyzes the sources again if they changed since, so neither stale
+// graphs nor images cached for older sources are served without -watch.
+func freshAnalysis(current *atomic.Pointer[analysis.Analysis], tests bool, args []string) func() *analysis.Analysis {
+	var mu sync.Mutex
+	w := analysis.NewSourceWatcher(current.Load().SourceFiles())
+	return func() *analysis.Analysis {
+		mu.Lock()
+		defer mu.Unlock()
+		a := current.Load()
+		changed := w.Changed()
+		if len(changed) == 0 {
+			return a
+		}
+		logger.LogInfo("%d files changed, analyzing again..", len(changed))
+		next, err := a.Reanalyze(context.Background(), "", tests, args)
+		if err != nil {
+			logger.LogError("analysis failed, keeping the previous one: %v", err)
+			return a
+		}
+		current.Store(next)
+		w.Watch(next.SourceFiles())
+		return next
+	}
+}
+
+// watchOutput writes the output file again whenever the sources change,
+// until ctx is done, so viewers reloading the file follow the changes.
+// Failed analyses and renders are logged, keeping the previous output.
+func watchOutput(ctx context.Context, a *analysis.Analysis, tests bool, args []string) {
+	w := analysis.NewSourceWatcher(a.SourceFiles())
+	for {
+		changed, err := w.Wait(ctx, time.Second)
+		if err != nil {
+			return
+		}
+		logger.LogInfo("%d files changed, analyzing again..", len(changed))
+		next, err := a.Reanalyze(ctx, "", tests, args)
+		if err != nil {
+			logger.LogError("analysis failed, keeping the previous output: %v", err)
+			continue
+		}
+		a = next
+		w.Watch(a.SourceFiles())
+		if err := outputDot(ctx, a, *outputFile, *outputFormat); err != nil {
+			logger.LogError("writing output failed: %v", err)
+		}
+	}
+}
+
+// serveImage serves the rendered image, adding the reload script to SVG
+// images when watching the sources.
+func serveImage(w http.ResponseWriter, r *http.Request, data []byte) {
+	if *watchFlag && *outputFormat == "svg" {
+		if i := bytes.LastIndex(data, []byte("</svg>")); i >= 0 {
+			data = append(data[:i:i], append([]byte(reloadScript), data[i:]...)...)
+		}
+	}
+	ctype := mime.TypeByExtension("." + *outputFormat)
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Write(data)
+}
+
+// serveCachedImage serves the cached image file img.
+func serveCachedImage(w http.ResponseWriter, r *http.Request, img string) {
+	data, err := os.ReadFile(img)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveImage(w, r, data)
+}
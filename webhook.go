@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/ofabry/go-callvis/pkg/logger"
+)
+
+var (
+	webhookFlag     = flag.String("webhook", "", "URL notified by a POST request once the output files are written or the run failed, e.g. for CI pipelines or chatbots.")
+	webhookTmplFlag = flag.String("webhook-template", "", "Template file of the -webhook payload, given the fields of the default JSON payload (see README).")
+)
+
+// webhookOutput describes an output file of the run.
+type webhookOutput struct {
+	File     string `json:"file"`
+	Location string `json:"location,omitempty"`
+	Nodes    int    `json:"nodes"`
+	Edges    int    `json:"edges"`
+}
+
+// webhookPayload is the payload posted to -webhook, as JSON by default.
+type webhookPayload struct {
+	Args     []string        `json:"args"`
+	Outputs  []webhookOutput `json:"outputs"`
+	Duration string          `json:"duration"`
+	Error    string          `json:"error,omitempty"`
+}
+
+var (
+	webhookStart   = time.Now()
+	webhookMu      sync.Mutex
+	webhookOutputs = []webhookOutput{}
+	webhookOnce    sync.Once
+)
+
+// webhookTmpl is the parsed -webhook-template, nil if unset.
+var webhookTmpl *template.Template
+
+// loadWebhookTemplate parses -webhook-template, providing the json function
+// to quote values in JSON payloads.
+func loadWebhookTemplate() error {
+	if *webhookTmplFlag == "" {
+		return nil
+	}
+	data, err := os.ReadFile(*webhookTmplFlag)
+	if err != nil {
+		return err
+	}
+	webhookTmpl, err = template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(string(data))
+	return err
+}
+
+// recordOutput adds an output file to the -webhook payload.
+func recordOutput(out webhookOutput) {
+	webhookMu.Lock()
+	webhookOutputs = append(webhookOutputs, out)
+	webhookMu.Unlock()
+}
+
+// notifyWebhook posts the outcome of the run to -webhook, once. Failing
+// to deliver it does not fail the run.
+func notifyWebhook(ctx context.Context, runErr error) {
+	if *webhookFlag == "" {
+		return
+	}
+	webhookOnce.Do(func() {
+		webhookMu.Lock()
+		payload := webhookPayload{
+			Args:     os.Args[1:],
+			Outputs:  webhookOutputs,
+			Duration: time.Since(webhookStart).Round(time.Millisecond).String(),
+		}
+		webhookMu.Unlock()
+		if runErr != nil {
+			payload.Error = runErr.Error()
+		}
+		if err := postWebhook(ctx, *webhookFlag, payload); err != nil {
+			logger.LogWarn("webhook: %v", err)
+		}
+	})
+}
+
+func postWebhook(ctx context.Context, url string, payload webhookPayload) error {
+	var body bytes.Buffer
+	if webhookTmpl != nil {
+		if err := webhookTmpl.Execute(&body, payload); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(&body).Encode(payload); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s answered %s", url, resp.Status)
+	}
+	return nil
+}